@@ -0,0 +1,56 @@
+package activity
+
+import (
+	"sync"
+	"todoproject/api/util"
+)
+
+const subscriberBuffer = 64
+
+// Broker fans out committed activities to the subscribers of this instance.
+// A subscriber that cannot keep up is dropped by closing its channel; stream
+// clients then reconnect and resume from the last activity id they received.
+type Broker struct {
+	// instance tells the activities of this instance apart from the ones
+	// other instances notify through Postgres, see Listener.
+	instance    string
+	mu          sync.Mutex
+	subscribers map[chan Activity]struct{}
+}
+
+func NewBroker() *Broker {
+	instance, _ := util.GenerateRandomToken(8)
+	return &Broker{instance: instance, subscribers: make(map[chan Activity]struct{})}
+}
+
+func (b *Broker) Subscribe() chan Activity {
+	ch := make(chan Activity, subscriberBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *Broker) Unsubscribe(ch chan Activity) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *Broker) Publish(activities ...Activity) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, a := range activities {
+		for ch := range b.subscribers {
+			select {
+			case ch <- a:
+			default:
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
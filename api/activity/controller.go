@@ -0,0 +1,86 @@
+package activity
+
+import (
+	"net/http"
+	"strconv"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Cursor  = "cursor"
+	Limit   = "limit"
+	ListId  = "list_id"
+	ActorId = "actor_id"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var RelativeActivityUrl = "/activity"
+
+type Handler struct {
+	Storage     *Storage
+	broker      *Broker
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, broker *Broker, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, broker: broker, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitActivityHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeActivityUrl, h.GetFeed)
+		api.GET(TodoStreamUrl, h.StreamTodos)
+	}
+}
+
+func (h *Handler) GetFeed(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	query := FeedQuery{UserId: user.Id, WorkspaceId: user.WorkspaceId, Limit: DefaultLimit}
+
+	if cursor, ok := ctx.GetQuery(Cursor); ok {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid cursor"))
+			return
+		}
+		query.Cursor = &id
+	}
+	if limit, ok := ctx.GetQuery(Limit); ok {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > MaxLimit {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid limit"))
+			return
+		}
+		query.Limit = n
+	}
+	if listId, ok := ctx.GetQuery(ListId); ok {
+		query.ListId = &listId
+	}
+	if actorId, ok := ctx.GetQuery(ActorId); ok {
+		query.ActorId = &actorId
+	}
+
+	activities, err := h.Storage.GetFeed(ctx, query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get activity"))
+		return
+	}
+
+	feed := Feed{Items: activities}
+	if len(activities) == query.Limit {
+		next := strconv.FormatInt(activities[len(activities)-1].Id, 10)
+		feed.NextCursor = &next
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", feed))
+}
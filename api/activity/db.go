@@ -0,0 +1,214 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/db"
+)
+
+const activityColumns = `a.id, a.workspace_id, a.list_id, a.todo_id, a.actor_id, a.kind, a.payload, a.created_at,
+	a.origin, a.instance, a.xact_id`
+
+// settled keeps the activities of the transactions older than every one still
+// in progress. No activity can show up before them anymore: those committing
+// later, or not started yet, have a higher transaction id. See Position.
+const settled = `a.xact_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint`
+
+// visible keeps the activities the user $1 can see, see activity_visible().
+const visible = `activity_visible(a.list_id, a.todo_id, a.workspace_id, a.actor_id, a.payload, $1)`
+
+// QueryGetFeed returns the activities of the workspace the user can see: those
+// on lists the user can see, plus the user's own activity on todos without a
+// list or on deleted lists.
+var QueryGetFeed = `SELECT ` + activityColumns + `
+	FROM activity a
+	WHERE a.workspace_id IS NOT DISTINCT FROM $2
+	AND ` + visible + `
+	AND ($3::uuid IS NULL OR a.list_id = $3)
+	AND ($4::uuid IS NULL OR a.actor_id = $4)
+	AND ($5::bigint IS NULL OR a.id < $5)
+	ORDER BY a.id DESC LIMIT $6`
+var QueryGetTodoEventsSince = `SELECT ` + activityColumns + `
+	FROM activity a
+	WHERE a.workspace_id IS NOT DISTINCT FROM $2 AND a.todo_id IS NOT NULL
+	AND (a.xact_id, a.id) > ($3, $4) AND ` + settled + `
+	AND ` + visible + `
+	ORDER BY a.xact_id, a.id LIMIT $5`
+var QueryGetSince = `SELECT ` + activityColumns + `
+	FROM activity a WHERE (a.xact_id, a.id) > ($1, $2) AND ` + settled + ` ORDER BY a.xact_id, a.id LIMIT $3`
+var QueryGetStart = `SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint`
+
+// QueryGetPosition finds the position of an activity by id, or of the one
+// before it when it was deleted since.
+var QueryGetPosition = `SELECT a.xact_id, a.id FROM activity a WHERE a.id <= $1 ORDER BY a.id DESC LIMIT 1`
+var QueryNotify = `SELECT pg_notify($1, $2)`
+var QueryCanSee = `SELECT activity_visible($2, $3, $4, $5, $6, $1)`
+var QueryCreate = `INSERT INTO activity (workspace_id, list_id, todo_id, actor_id, kind, payload, origin, instance)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8) RETURNING id, created_at, xact_id`
+
+type Storage struct {
+	db     db.Client
+	broker *Broker
+	log    *logrus.Logger
+}
+
+func NewStorage(db db.Client, broker *Broker, log *logrus.Logger) *Storage {
+	return &Storage{db: db, broker: broker, log: log}
+}
+
+// WithTx returns a storage that runs its queries inside the given transaction,
+// so an activity is only persisted together with the change it describes.
+func (s *Storage) WithTx(tx db.Client) *Storage {
+	return &Storage{db: tx, broker: s.broker, log: s.log}
+}
+
+// Publish hands activities to the live subscribers. It must only be called
+// once the transaction that created them has been committed.
+func (s *Storage) Publish(activities ...Activity) {
+	s.broker.Publish(activities...)
+}
+
+// Subscribe registers a live subscriber for the activities published on this instance.
+func (s *Storage) Subscribe() chan Activity {
+	return s.broker.Subscribe()
+}
+
+func (s *Storage) Unsubscribe(ch chan Activity) {
+	s.broker.Unsubscribe(ch)
+}
+
+func (s *Storage) GetFeed(ctx context.Context, query FeedQuery) ([]Activity, error) {
+	return s.queryActivities(ctx, QueryGetFeed, query.UserId, query.WorkspaceId, query.ListId, query.ActorId, query.Cursor, query.Limit)
+}
+
+// GetTodoEventsSince returns the todo activities the user can see after the
+// position, in the order of Position. It lets stream clients resume.
+func (s *Storage) GetTodoEventsSince(ctx context.Context, userId string, workspaceId *string, after Position, limit int) ([]Activity, error) {
+	return s.queryActivities(ctx, QueryGetTodoEventsSince, userId, workspaceId, after.XactId, after.Id, limit)
+}
+
+// CanSee reports whether the user is allowed to see the activity.
+func (s *Storage) CanSee(ctx context.Context, a Activity, userId string, workspaceId *string) (bool, error) {
+	if !sameWorkspace(a.WorkspaceId, workspaceId) {
+		return false, nil
+	}
+	if a.ActorId == userId && a.ListId == nil {
+		return true, nil
+	}
+	var visible bool
+	err := s.db.QueryRow(ctx, QueryCanSee, userId, a.ListId, a.TodoId, a.WorkspaceId, a.ActorId, a.Payload).Scan(&visible)
+	if err != nil {
+		s.TraceQueryError(err)
+		return false, err
+	}
+	return visible, nil
+}
+
+func (s *Storage) queryActivities(ctx context.Context, query string, args ...interface{}) ([]Activity, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query activity. due to error: %v", err)
+		return nil, err
+	}
+	activities := make([]Activity, 0)
+	for rows.Next() {
+		var a Activity
+		var origin *string
+		errScan := rows.Scan(&a.Id, &a.WorkspaceId, &a.ListId, &a.TodoId, &a.ActorId, &a.Kind, &a.Payload, &a.CreatedAt,
+			&origin, &a.instance, &a.xactId)
+		if errScan != nil {
+			s.log.Errorf("failed to scan activity. due to error: %v", errScan)
+			return nil, errScan
+		}
+		if origin != nil {
+			a.Origin = *origin
+		}
+		activities = append(activities, a)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return activities, nil
+}
+
+// Create records the activity. It must run in the transaction of the change
+// it describes, the other instances are notified once it commits.
+func (s *Storage) Create(ctx context.Context, activity *Activity) error {
+	if activity.Payload == nil {
+		activity.Payload = []byte("{}")
+	}
+	err := s.db.QueryRow(ctx, QueryCreate, activity.WorkspaceId, activity.ListId, activity.TodoId,
+		activity.ActorId, activity.Kind, activity.Payload, activity.Origin, s.broker.instance).
+		Scan(&activity.Id, &activity.CreatedAt, &activity.xactId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create %s activity. due to error: %v", activity.Kind, err)
+		return err
+	}
+	activity.instance = s.broker.instance
+
+	// inside a transaction the notification is only delivered on commit
+	event, err := json.Marshal(notifyEvent{Instance: s.broker.instance, Id: activity.Id})
+	if err != nil {
+		return err
+	}
+	if _, err = s.db.Exec(ctx, QueryNotify, NotifyChannel, string(event)); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to notify activity id=(%d). due to error: %v", activity.Id, err)
+		return err
+	}
+	return nil
+}
+
+// GetSince returns the activities of every instance after the position, in
+// the order of Position.
+func (s *Storage) GetSince(ctx context.Context, after Position, limit int) ([]Activity, error) {
+	return s.queryActivities(ctx, QueryGetSince, after.XactId, after.Id, limit)
+}
+
+// GetStart returns the position of the activities settled by now, reading on
+// from it skips them without missing the ones still to commit.
+func (s *Storage) GetStart(ctx context.Context) (start Position, err error) {
+	if err = s.db.QueryRow(ctx, QueryGetStart).Scan(&start.XactId); err != nil {
+		s.TraceQueryError(err)
+		return Position{}, err
+	}
+	return start, nil
+}
+
+// GetPosition returns the position of the activity of the given id, which
+// clients use to resume. The position of a deleted activity is the one of the
+// activity before it.
+func (s *Storage) GetPosition(ctx context.Context, id int64) (position Position, err error) {
+	err = s.db.QueryRow(ctx, QueryGetPosition, id).Scan(&position.XactId, &position.Id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Position{}, nil
+	}
+	if err != nil {
+		s.TraceQueryError(err)
+		return Position{}, err
+	}
+	return position, nil
+}
+
+func sameWorkspace(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
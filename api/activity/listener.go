@@ -0,0 +1,144 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"todoproject/db"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+)
+
+// NotifyChannel is the Postgres channel every instance notifies the
+// activities it records on.
+const NotifyChannel = "activity"
+
+const (
+	resyncLimit       = 500
+	minReconnectDelay = time.Second
+	maxReconnectDelay = time.Minute
+	// settleInterval is how often the listener reads on without being
+	// notified, for activities held back by an older transaction that
+	// recorded none, see Position.
+	settleInterval = 10 * time.Second
+)
+
+// notifyEvent is the payload of a notification. It only carries the id, the
+// activity itself could exceed the 8000 bytes a notification is limited to.
+type notifyEvent struct {
+	Instance string `json:"instance"`
+	Id       int64  `json:"id"`
+}
+
+// Listener redistributes the activities recorded by the other instances to the
+// subscribers of this one, so SSE and WebSocket clients get every change
+// whichever replica handled it. It holds a dedicated connection, LISTEN does
+// not survive being returned to a pool.
+type Listener struct {
+	cfg     *db.Config
+	storage *Storage
+	broker  *Broker
+	log     *logrus.Logger
+	started bool
+	// cursor is the position of the latest activity the listener has handled.
+	cursor Position
+}
+
+func NewListener(cfg *db.Config, storage *Storage, broker *Broker, log *logrus.Logger) *Listener {
+	return &Listener{cfg: cfg, storage: storage, broker: broker, log: log}
+}
+
+// Run listens until ctx is done, reconnecting with an exponential backoff
+// whenever the connection is lost.
+func (l *Listener) Run(ctx context.Context) {
+	delay := minReconnectDelay
+	for {
+		connected, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = minReconnectDelay
+		}
+		l.log.Errorf("activity listener disconnected, reconnecting in %v. due to error: %v", delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (l *Listener) listen(ctx context.Context) (bool, error) {
+	conn, err := db.NewConn(ctx, l.cfg)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+NotifyChannel); err != nil {
+		return false, err
+	}
+	if err = l.resync(ctx); err != nil {
+		return true, err
+	}
+
+	for {
+		waitCtx, cancel := context.WithTimeout(ctx, settleInterval)
+		notification, err := conn.WaitForNotification(waitCtx)
+		cancel()
+		if err != nil && !(pgconn.Timeout(err) && ctx.Err() == nil) {
+			return true, err
+		}
+		if notification != nil {
+			var event notifyEvent
+			if err = json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+				l.log.Errorf("failed to decode activity notification %q. due to error: %v", notification.Payload, err)
+				continue
+			}
+			// published when they were committed
+			if event.Instance == l.broker.instance {
+				continue
+			}
+		}
+		if err = l.resync(ctx); err != nil {
+			return true, err
+		}
+	}
+}
+
+// resync publishes the activities the other instances recorded after the
+// cursor. The activities of this instance were published when they were
+// committed. The first connection starts from the activities settled by then,
+// clients connecting later catch up through their own resume mechanisms.
+func (l *Listener) resync(ctx context.Context) error {
+	if !l.started {
+		start, err := l.storage.GetStart(ctx)
+		if err != nil {
+			return err
+		}
+		l.cursor, l.started = start, true
+		return nil
+	}
+
+	for {
+		activities, err := l.storage.GetSince(ctx, l.cursor, resyncLimit)
+		if err != nil {
+			return err
+		}
+		for _, a := range activities {
+			if a.instance != l.broker.instance {
+				l.broker.Publish(a)
+			}
+			l.cursor = a.Position()
+		}
+		if len(activities) < resyncLimit {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package activity
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	KindCreated    = "created"
+	KindUpdated    = "updated"
+	KindDeleted    = "deleted"
+	KindAssigned   = "assigned"
+	KindUnassigned = "unassigned"
+	KindShared     = "shared"
+	KindCompleted  = "completed"
+	KindReopened   = "reopened"
+	KindMoved      = "moved"
+)
+
+type Activity struct {
+	Id          int64           `json:"id"`
+	WorkspaceId *string         `json:"workspace_id"`
+	ListId      *string         `json:"list_id"`
+	TodoId      *string         `json:"todo_id"`
+	ActorId     string          `json:"actor_id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	// Origin identifies the live session that caused the activity, so that the
+	// session does not get its own changes echoed back.
+	Origin string `json:"-"`
+	// instance recorded the activity within the transaction xactId.
+	instance string
+	xactId   int64
+}
+
+// Position orders the activities the way they become visible: by the
+// transaction that recorded them, then by id. Ids alone are not enough, a
+// transaction can commit after one that took a higher id. Readers only go as
+// far as the transactions that are settled, so nothing commits behind them.
+type Position struct {
+	XactId int64
+	Id     int64
+}
+
+func (a Activity) Position() Position {
+	return Position{XactId: a.xactId, Id: a.Id}
+}
+
+// After reports whether p comes after other.
+func (p Position) After(other Position) bool {
+	return p.XactId > other.XactId || (p.XactId == other.XactId && p.Id > other.Id)
+}
+
+// FeedQuery selects a page of the feed. Cursor is the id of the last activity
+// of the previous page, the feed is ordered from the newest activity.
+type FeedQuery struct {
+	UserId      string
+	WorkspaceId *string
+	ListId      *string
+	ActorId     *string
+	Cursor      *int64
+	Limit       int
+}
+
+type Feed struct {
+	Items      []Activity `json:"items"`
+	NextCursor *string    `json:"next_cursor"`
+}
+
+type originKey struct{}
+
+// WithOrigin marks the changes made with ctx as coming from the given session.
+func WithOrigin(ctx context.Context, origin string) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+func OriginFromContext(ctx context.Context) string {
+	origin, _ := ctx.Value(originKey{}).(string)
+	return origin
+}
@@ -0,0 +1,26 @@
+package activity
+
+import "testing"
+
+func TestPositionAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		p, other Position
+		want     bool
+	}{
+		{name: "same", p: Position{XactId: 10, Id: 5}, other: Position{XactId: 10, Id: 5}, want: false},
+		{name: "later in the transaction", p: Position{XactId: 10, Id: 6}, other: Position{XactId: 10, Id: 5}, want: true},
+		{name: "later transaction", p: Position{XactId: 11, Id: 1}, other: Position{XactId: 10, Id: 5}, want: true},
+		// a transaction that took its id first but committed last
+		{name: "lower id in a later transaction", p: Position{XactId: 12, Id: 3}, other: Position{XactId: 11, Id: 7}, want: true},
+		{name: "higher id in an earlier transaction", p: Position{XactId: 11, Id: 7}, other: Position{XactId: 12, Id: 3}, want: false},
+		{name: "after the start", p: Position{XactId: 10, Id: 1}, other: Position{XactId: 10}, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.p.After(test.other); got != test.want {
+				t.Errorf("After = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package activity
+
+import "context"
+
+type Repository interface {
+	GetFeed(ctx context.Context, query FeedQuery) ([]Activity, error)
+	GetTodoEventsSince(ctx context.Context, userId string, workspaceId *string, after Position, limit int) ([]Activity, error)
+	CanSee(ctx context.Context, a Activity, userId string, workspaceId *string) (bool, error)
+	Create(ctx context.Context, activity *Activity) error
+}
@@ -0,0 +1,128 @@
+package activity
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"todoproject/api/users"
+	"todoproject/apperror"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LastEventIdHeader = "Last-Event-ID"
+	LastEventId       = "last_event_id"
+)
+
+const (
+	replayLimit       = 500
+	heartbeatInterval = 30 * time.Second
+)
+
+var TodoStreamUrl = "/todos/stream"
+
+// StreamTodos pushes the todo changes the user can see as Server-Sent Events.
+// Event ids are activity ids, so a client reconnecting with Last-Event-ID first
+// gets every change it missed and then the live ones.
+func (h *Handler) StreamTodos(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+
+	lastEventId := ctx.GetHeader(LastEventIdHeader)
+	if lastEventId == "" {
+		lastEventId = ctx.Query(LastEventId)
+	}
+	var lastId int64
+	if lastEventId != "" {
+		id, err := strconv.ParseInt(lastEventId, 10, 64)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid last event id"))
+			return
+		}
+		lastId = id
+	}
+
+	// Subscribe before reading the starting point so that nothing committed in
+	// between is lost. Live activities only signal that there is something to
+	// read: they reach this instance in no particular order, the events are
+	// read in the order of Position.
+	live := h.broker.Subscribe()
+	defer h.broker.Unsubscribe(live)
+
+	var position Position
+	var err error
+	if lastEventId == "" {
+		position, err = h.Storage.GetStart(ctx)
+	} else {
+		position, err = h.Storage.GetPosition(ctx, lastId)
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to open stream"))
+		return
+	}
+
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	// catchUp writes the todo events the user can see after the position.
+	catchUp := func(w io.Writer) bool {
+		for {
+			activities, err := h.Storage.GetTodoEventsSince(ctx, user.Id, user.WorkspaceId, position, replayLimit)
+			if err != nil {
+				h.Log.Errorf("failed to read todo events for user_id=(%s). due to error: %v", user.Id, err)
+				return false
+			}
+			for _, a := range activities {
+				if err = writeTodoEvent(w, a); err != nil {
+					return false
+				}
+				position = a.Position()
+			}
+			if len(activities) < replayLimit {
+				return true
+			}
+		}
+	}
+	if !catchUp(ctx.Writer) {
+		return
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			// also writes the events held back by a transaction that
+			// recorded no activity, see Position
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil {
+				return false
+			}
+			return catchUp(w)
+		case a, ok := <-live:
+			if !ok {
+				return false
+			}
+			if a.TodoId == nil || !a.Position().After(position) {
+				return true
+			}
+			return catchUp(w)
+		}
+	})
+}
+
+func writeTodoEvent(w io.Writer, a Activity) error {
+	return sse.Encode(w, sse.Event{
+		Id:    strconv.FormatInt(a.Id, 10),
+		Event: "todo." + a.Kind,
+		Data:  a,
+	})
+}
@@ -0,0 +1,276 @@
+package automation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id    = "id"
+	Limit = "limit"
+)
+
+// MaxRules bounds the rules of a user in a workspace, every change to a todo
+// is matched against them.
+const MaxRules = 50
+
+const (
+	MaxConditions    = 10
+	MaxActions       = 5
+	MaxDueInDays     = 365
+	maxNameLength    = 100
+	maxValueLength   = 100
+	maxTagLength     = 50
+	defaultExecLimit = 50
+	maxExecLimit     = 200
+)
+
+var (
+	RelativeRuleUrl = "/rules"
+	GetByIdUrl      = fmt.Sprintf("/rules/:%s", Id)
+	ExecutionsUrl   = fmt.Sprintf("/rules/:%s/executions", Id)
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitRuleHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeRuleUrl, h.GetAll)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeRuleUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.GET(ExecutionsUrl, h.GetExecutions)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	rules, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get rules"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rules))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	rule, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get rule")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rule))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	rule, ok := h.bind(ctx, user)
+	if !ok {
+		return
+	}
+
+	if err := h.Storage.Create(ctx, &rule); err != nil {
+		message := "failed to create rule"
+		if errors.Is(err, apperror.ErrConflict) {
+			message = fmt.Sprintf("at most %d rules are allowed", MaxRules)
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", rule))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	rule, ok := h.bind(ctx, user)
+	if !ok {
+		return
+	}
+
+	rule.Id = ctx.Param(Id)
+	if err := h.Storage.Update(ctx, &rule); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update rule")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rule))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete rule")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// GetExecutions returns the most recent executions of the rule, newest first.
+func (h *Handler) GetExecutions(ctx *gin.Context) {
+	limit := defaultExecLimit
+	if value, ok := ctx.GetQuery(Limit); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid limit"))
+			return
+		}
+		limit = parsed
+		if limit > maxExecLimit {
+			limit = maxExecLimit
+		}
+	}
+
+	user := users.GetCurrentUser(ctx)
+	rule, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get rule")
+		return
+	}
+	executions, err := h.Storage.GetExecutions(ctx, rule.Id, limit)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get executions"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", executions))
+}
+
+// bind reads and validates the rule of the request. Rules are active unless
+// active is false.
+func (h *Handler) bind(ctx *gin.Context, user users.User) (Rule, bool) {
+	var ruleDto RuleDto
+	if err := ctx.ShouldBindJSON(&ruleDto); err != nil {
+		h.Log.Errorf("failed to bind rule. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return Rule{}, false
+	}
+
+	rule := Rule{UserId: user.Id, WorkspaceId: user.WorkspaceId, ListId: ruleDto.ListId, Trigger: ruleDto.Trigger,
+		Name: strings.TrimSpace(ruleDto.Name), Active: ruleDto.Active == nil || *ruleDto.Active}
+	message := h.validate(ctx, user, &rule, ruleDto)
+	if message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return Rule{}, false
+	}
+	return rule, true
+}
+
+// validate checks the rule and fills in its trigger tag, conditions and
+// actions with only the parameters they use. It returns the problem found,
+// if any.
+func (h *Handler) validate(ctx *gin.Context, user users.User, rule *Rule, ruleDto RuleDto) string {
+	if rule.Name == "" || len([]rune(rule.Name)) > maxNameLength {
+		return fmt.Sprintf("name must be 1 to %d characters", maxNameLength)
+	}
+	if !Triggers[rule.Trigger] {
+		return "invalid trigger"
+	}
+	if rule.Trigger == TriggerTagged {
+		tag, ok := cleanTag(ruleDto.TriggerTag)
+		if !ok {
+			return "trigger_tag is required for the tagged trigger"
+		}
+		rule.TriggerTag = &tag
+	}
+	if rule.ListId != nil {
+		if _, err := h.listStorage.GetRole(ctx, *rule.ListId, user.Id, user.WorkspaceId); err != nil {
+			return "list not found"
+		}
+	}
+
+	if len(ruleDto.Conditions) > MaxConditions {
+		return fmt.Sprintf("at most %d conditions are allowed", MaxConditions)
+	}
+	rule.Conditions = make([]Condition, 0, len(ruleDto.Conditions))
+	for _, condition := range ruleDto.Conditions {
+		if !fieldOperators[condition.Field][condition.Op] {
+			return fmt.Sprintf("invalid operator %q for field %q", condition.Op, condition.Field)
+		}
+		if condition.Op == OpSet || condition.Op == OpUnset {
+			condition.Value = nil
+		} else if condition.Value == nil || *condition.Value == "" || len([]rune(*condition.Value)) > maxValueLength {
+			return fmt.Sprintf("value of field %q must be 1 to %d characters", condition.Field, maxValueLength)
+		}
+		rule.Conditions = append(rule.Conditions, condition)
+	}
+
+	if len(ruleDto.Actions) == 0 || len(ruleDto.Actions) > MaxActions {
+		return fmt.Sprintf("rules must have 1 to %d actions", MaxActions)
+	}
+	rule.Actions = make([]Action, 0, len(ruleDto.Actions))
+	for _, action := range ruleDto.Actions {
+		cleaned := Action{Type: action.Type}
+		switch action.Type {
+		case ActionAddTag:
+			tag, ok := cleanTag(action.Tag)
+			if !ok {
+				return fmt.Sprintf("tag of %s must be 1 to %d characters", action.Type, maxTagLength)
+			}
+			cleaned.Tag = &tag
+		case ActionSetPriority:
+			if action.Priority == nil || !todo.Priorities[*action.Priority] {
+				return "invalid priority"
+			}
+			cleaned.Priority = action.Priority
+		case ActionMoveList:
+			if action.ListId == nil {
+				return "list_id is required for move_list"
+			}
+			role, err := h.listStorage.GetRole(ctx, *action.ListId, user.Id, user.WorkspaceId)
+			if err != nil || !role.CanEdit() {
+				return "target list not found or not editable"
+			}
+			cleaned.ListId = action.ListId
+		case ActionAssign:
+			if action.UserId == nil || *action.UserId == "" {
+				return "user_id is required for assign"
+			}
+			cleaned.UserId = action.UserId
+		case ActionCreateFollowUp:
+			if action.Title == nil {
+				return "title is required for create_follow_up"
+			}
+			title := strings.TrimSpace(*action.Title)
+			if title == "" || len([]rune(title)) > maxNameLength {
+				return fmt.Sprintf("title of create_follow_up must be 1 to %d characters", maxNameLength)
+			}
+			if action.DueInDays != nil && (*action.DueInDays < 0 || *action.DueInDays > MaxDueInDays) {
+				return fmt.Sprintf("due_in_days must be between 0 and %d", MaxDueInDays)
+			}
+			cleaned.Title, cleaned.DueInDays = &title, action.DueInDays
+		default:
+			return fmt.Sprintf("invalid action %q", action.Type)
+		}
+		rule.Actions = append(rule.Actions, cleaned)
+	}
+	return ""
+}
+
+func cleanTag(tag *string) (string, bool) {
+	if tag == nil {
+		return "", false
+	}
+	cleaned := strings.TrimSpace(*tag)
+	return cleaned, cleaned != "" && len([]rune(cleaned)) <= maxTagLength
+}
@@ -0,0 +1,271 @@
+package automation
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const ruleColumns = `r.id, r.user_id, r.workspace_id, r.list_id, r.name, r.trigger, r.trigger_tag, r.conditions, r.actions,
+	r.active, r.created_at, r.updated_at`
+const executionColumns = `e.id, e.rule_id, e.todo_id, e.activity_id, e.status, e.message, e.created_at`
+
+var QueryGetAll = `SELECT ` + ruleColumns + ` FROM automation_rules r
+	WHERE r.user_id = $1 AND r.workspace_id IS NOT DISTINCT FROM $2 ORDER BY r.created_at`
+var QueryGetById = `SELECT ` + ruleColumns + ` FROM automation_rules r
+	WHERE r.id = $1 AND r.user_id = $2 AND r.workspace_id IS NOT DISTINCT FROM $3`
+var QueryGetRule = `SELECT ` + ruleColumns + ` FROM automation_rules r WHERE r.id = $1`
+var QueryLockOwner = `SELECT pg_advisory_xact_lock(hashtext('automation_rules:' || $1::text))`
+var QueryCount = `SELECT count(*) FROM automation_rules WHERE user_id = $1 AND workspace_id IS NOT DISTINCT FROM $2`
+var QueryCreate = `INSERT INTO automation_rules (user_id, workspace_id, list_id, name, trigger, trigger_tag, conditions, actions, active)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at, updated_at`
+var QueryUpdate = `UPDATE automation_rules r SET list_id = $4, name = $5, trigger = $6, trigger_tag = $7, conditions = $8,
+	actions = $9, active = $10, updated_at = now()
+	WHERE r.id = $1 AND r.user_id = $2 AND r.workspace_id IS NOT DISTINCT FROM $3 RETURNING ` + ruleColumns
+var QueryDelete = `DELETE FROM automation_rules WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+
+// QueryGetMatching selects the active rules of the workspace on the triggers
+// $3 that cover the list $2.
+var QueryGetMatching = `SELECT ` + ruleColumns + ` FROM automation_rules r
+	WHERE r.active AND r.workspace_id IS NOT DISTINCT FROM $1 AND (r.list_id IS NULL OR r.list_id = $2)
+	AND r.trigger = ANY($3) ORDER BY r.created_at, r.id`
+
+// QueryGetPreviousTags reads the tags of the todo from the activity before
+// $2, whose payload is a snapshot of the todo.
+var QueryGetPreviousTags = `SELECT COALESCE(a.payload -> 'tags', '[]') FROM activity a
+	WHERE a.todo_id = $1 AND a.id < $2 ORDER BY a.id DESC LIMIT 1`
+var QueryRecordExecution = `INSERT INTO automation_executions (rule_id, todo_id, activity_id, status, message)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+var QueryGetExecutions = `SELECT ` + executionColumns + ` FROM automation_executions e
+	WHERE e.rule_id = $1 ORDER BY e.id DESC LIMIT $2`
+
+// QueryClaimOverdue claims the open todos whose due date passed for the
+// overdue rules whose owner can see them. Todos due before the rule was
+// created are left alone, so a new rule does not fire on the backlog.
+var QueryClaimOverdue = `INSERT INTO automation_overdue (rule_id, todo_id, due_at)
+	SELECT r.id, t.id, t.due_at FROM automation_rules r
+	JOIN todo t ON t.workspace_id IS NOT DISTINCT FROM r.workspace_id AND (r.list_id IS NULL OR t.list_id = r.list_id)
+	WHERE r.active AND r.trigger = 'overdue' AND t.completed_at IS NULL AND t.due_at < now() AND t.due_at > r.created_at
+	AND todo_role(t.list_id, t.user_id, r.user_id) IS NOT NULL
+	AND NOT EXISTS (SELECT 1 FROM automation_overdue o WHERE o.rule_id = r.id AND o.todo_id = t.id AND o.due_at = t.due_at)
+	ORDER BY t.due_at LIMIT $1
+	ON CONFLICT DO NOTHING RETURNING rule_id, todo_id`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Rule, error) {
+	return s.queryRules(ctx, QueryGetAll, userId, workspaceId)
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (rule Rule, err error) {
+	if err = scanRule(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId), &rule); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Rule{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get rule by id=(%s), due to error: %v", id, err)
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// getRule reads a rule for the engine, whatever its owner.
+func (s *Storage) getRule(ctx context.Context, id string) (rule Rule, err error) {
+	if err = scanRule(s.db.QueryRow(ctx, QueryGetRule, id), &rule); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Rule{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get rule by id=(%s), due to error: %v", id, err)
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Create saves the rule unless its owner already has MaxRules of them in the
+// workspace, in which case apperror.ErrConflict is returned.
+func (s *Storage) Create(ctx context.Context, rule *Rule) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// serializes the creations of the owner so the limit cannot be raced
+	if _, err = tx.Exec(ctx, QueryLockOwner, rule.UserId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	var count int
+	if err = tx.QueryRow(ctx, QueryCount, rule.UserId, rule.WorkspaceId).Scan(&count); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if count >= MaxRules {
+		return apperror.ErrConflict
+	}
+	err = tx.QueryRow(ctx, QueryCreate, rule.UserId, rule.WorkspaceId, rule.ListId, rule.Name, rule.Trigger, rule.TriggerTag,
+		rule.Conditions, rule.Actions, rule.Active).Scan(&rule.Id, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create rule. due to error: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Storage) Update(ctx context.Context, rule *Rule) error {
+	err := scanRule(s.db.QueryRow(ctx, QueryUpdate, rule.Id, rule.UserId, rule.WorkspaceId, rule.ListId, rule.Name, rule.Trigger,
+		rule.TriggerTag, rule.Conditions, rule.Actions, rule.Active), rule)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update rule id=(%s). due to error: %v", rule.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete rule id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// GetMatching returns the active rules of the workspace on the triggers that
+// cover the list, all lists for a nil one.
+func (s *Storage) GetMatching(ctx context.Context, workspaceId, listId *string, triggers []string) ([]Rule, error) {
+	return s.queryRules(ctx, QueryGetMatching, workspaceId, listId, triggers)
+}
+
+// GetPreviousTags returns the tags the todo had before the activity, none
+// when the activity is its first.
+func (s *Storage) GetPreviousTags(ctx context.Context, todoId string, activityId int64) ([]string, error) {
+	var tags []string
+	err := s.db.QueryRow(ctx, QueryGetPreviousTags, todoId, activityId).Scan(&tags)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get previous tags of todo id=(%s). due to error: %v", todoId, err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *Storage) RecordExecution(ctx context.Context, execution *Execution) error {
+	err := s.db.QueryRow(ctx, QueryRecordExecution, execution.RuleId, execution.TodoId, execution.ActivityId,
+		execution.Status, execution.Message).Scan(&execution.Id, &execution.CreatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to record execution of rule id=(%s). due to error: %v", execution.RuleId, err)
+		return err
+	}
+	return nil
+}
+
+// GetExecutions returns the most recent executions of the rule, newest first.
+func (s *Storage) GetExecutions(ctx context.Context, ruleId string, limit int) ([]Execution, error) {
+	rows, err := s.db.Query(ctx, QueryGetExecutions, ruleId, limit)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query executions of rule id=(%s). due to error: %v", ruleId, err)
+		return nil, err
+	}
+	executions := make([]Execution, 0)
+	for rows.Next() {
+		var e Execution
+		errScan := rows.Scan(&e.Id, &e.RuleId, &e.TodoId, &e.ActivityId, &e.Status, &e.Message, &e.CreatedAt)
+		if errScan != nil {
+			s.log.Errorf("failed to scan execution. due to error: %v", errScan)
+			return nil, errScan
+		}
+		executions = append(executions, e)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return executions, nil
+}
+
+// ClaimOverdue claims up to limit overdue todos for the overdue rules, each
+// todo and due date being claimed once per rule across instances.
+func (s *Storage) ClaimOverdue(ctx context.Context, limit int) ([]Overdue, error) {
+	rows, err := s.db.Query(ctx, QueryClaimOverdue, limit)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to claim overdue todos. due to error: %v", err)
+		return nil, err
+	}
+	claimed := make([]Overdue, 0)
+	for rows.Next() {
+		var overdue Overdue
+		if errScan := rows.Scan(&overdue.RuleId, &overdue.TodoId); errScan != nil {
+			s.log.Errorf("failed to scan overdue todo. due to error: %v", errScan)
+			return nil, errScan
+		}
+		claimed = append(claimed, overdue)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (s *Storage) queryRules(ctx context.Context, query string, args ...interface{}) ([]Rule, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query rules. due to error: %v", err)
+		return nil, err
+	}
+	rules := make([]Rule, 0)
+	for rows.Next() {
+		var rule Rule
+		if errScan := scanRule(rows, &rule); errScan != nil {
+			s.log.Errorf("failed to scan rule. due to error: %v", errScan)
+			return nil, errScan
+		}
+		rules = append(rules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return rules, nil
+}
+
+func scanRule(row pgx.Row, rule *Rule) error {
+	return row.Scan(&rule.Id, &rule.UserId, &rule.WorkspaceId, &rule.ListId, &rule.Name, &rule.Trigger, &rule.TriggerTag,
+		&rule.Conditions, &rule.Actions, &rule.Active, &rule.CreatedAt, &rule.UpdatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
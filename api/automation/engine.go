@@ -0,0 +1,305 @@
+package automation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"todoproject/api/activity"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/apperror"
+
+	"github.com/sirupsen/logrus"
+)
+
+// MaxDepth is how many rules can fire in a row off a single change, each one
+// on the changes of the one before.
+const MaxDepth = 3
+
+const (
+	overdueInterval = time.Minute
+	overdueBatch    = 50
+)
+
+// Engine runs the rules on the changes of todo.Storage, which it observes,
+// and on the todos that become overdue. Rules run as their owner, in the
+// goroutine that made the change.
+//
+// A rule runs at most once per todo off a single change and rules fire at
+// most MaxDepth deep, so rules triggering each other cannot loop. Every run
+// of a rule whose conditions hold is recorded in its execution log.
+type Engine struct {
+	storage     *Storage
+	todoStorage *todo.Storage
+	listStorage *lists.Storage
+	log         *logrus.Logger
+}
+
+func NewEngine(storage *Storage, todoStorage *todo.Storage, listStorage *lists.Storage, log *logrus.Logger) *Engine {
+	return &Engine{storage: storage, todoStorage: todoStorage, listStorage: listStorage, log: log}
+}
+
+// chain tracks the rules fired off a single change through the context.
+type chain struct {
+	depth int
+	fired map[string]bool
+}
+
+type chainKey struct{}
+
+func chainFromContext(ctx context.Context) *chain {
+	if c, ok := ctx.Value(chainKey{}).(*chain); ok {
+		return c
+	}
+	return &chain{fired: make(map[string]bool)}
+}
+
+// TodoChanged fires the created, completed and tagged rules on the changes.
+func (e *Engine) TodoChanged(ctx context.Context, activities []activity.Activity) {
+	for _, a := range activities {
+		if a.TodoId == nil {
+			continue
+		}
+		var snapshot todo.Todo
+		if err := json.Unmarshal(a.Payload, &snapshot); err != nil {
+			e.log.Errorf("failed to read activity id=(%d). due to error: %v", a.Id, err)
+			continue
+		}
+
+		var triggers, added []string
+		switch a.Kind {
+		case activity.KindCreated:
+			triggers, added = []string{TriggerCreated}, snapshot.Tags
+		case activity.KindCompleted:
+			triggers = []string{TriggerCompleted}
+		case activity.KindUpdated:
+			previous, err := e.storage.GetPreviousTags(ctx, *a.TodoId, a.Id)
+			if err != nil {
+				continue
+			}
+			added = addedTags(previous, snapshot.Tags)
+		}
+		if len(added) > 0 {
+			triggers = append(triggers, TriggerTagged)
+		}
+		if len(triggers) == 0 {
+			continue
+		}
+
+		rules, err := e.storage.GetMatching(ctx, a.WorkspaceId, a.ListId, triggers)
+		if err != nil {
+			continue
+		}
+		for _, rule := range rules {
+			if rule.Trigger == TriggerTagged && !containsTag(added, *rule.TriggerTag) {
+				continue
+			}
+			activityId := a.Id
+			e.fire(ctx, rule, *a.TodoId, &activityId)
+		}
+	}
+}
+
+// Run fires the overdue rules on the todos whose due date passed until ctx
+// is done. Overdue todos are claimed in Postgres, so every instance can run one.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(overdueInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			claimed, err := e.storage.ClaimOverdue(ctx, overdueBatch)
+			if err != nil || len(claimed) == 0 {
+				break
+			}
+			for _, overdue := range claimed {
+				rule, err := e.storage.getRule(ctx, overdue.RuleId)
+				if err != nil {
+					continue
+				}
+				e.fire(ctx, rule, overdue.TodoId, nil)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fire runs the rule on the todo when its conditions hold and records the
+// outcome. The todo is read as the owner of the rule, todos the owner cannot
+// see are left alone.
+func (e *Engine) fire(ctx context.Context, rule Rule, todoId string, activityId *int64) {
+	t, role, err := e.todoStorage.GetTodoById(ctx, todoId, rule.UserId, rule.WorkspaceId)
+	if err != nil || !matches(t, rule.Conditions) {
+		return
+	}
+
+	execution := Execution{RuleId: rule.Id, TodoId: &todoId, ActivityId: activityId, Status: StatusSucceeded}
+	c := chainFromContext(ctx)
+	key := rule.Id + ":" + todoId
+	switch {
+	case c.fired[key]:
+		execution.Status, execution.Message = StatusSkipped, message("the rule already ran on this todo")
+	case c.depth >= MaxDepth:
+		execution.Status, execution.Message = StatusSkipped, message(fmt.Sprintf("more than %d rules fired in a row", MaxDepth))
+	case !role.CanEdit():
+		execution.Status, execution.Message = StatusFailed, message("the owner of the rule cannot edit the todo")
+	default:
+		c.fired[key] = true
+		// the changes of the rule are seen by every session, the one that
+		// triggered it included
+		next := context.WithValue(activity.WithOrigin(ctx, ""), chainKey{}, &chain{depth: c.depth + 1, fired: c.fired})
+		for _, action := range rule.Actions {
+			if err = e.run(next, rule, todoId, action); err != nil {
+				execution.Status = StatusFailed
+				var appErr *apperror.AppError
+				if errors.As(err, &appErr) {
+					execution.Message = message(fmt.Sprintf("%s: %s", action.Type, appErr.Message))
+				} else {
+					execution.Message = message(fmt.Sprintf("%s: failed to run the action", action.Type))
+				}
+				break
+			}
+		}
+	}
+	_ = e.storage.RecordExecution(ctx, &execution)
+}
+
+// run applies the action to the current state of the todo, which the rules
+// fired by the previous actions may have changed. Tags and priority are
+// changed column by column, so edits made to the todo while the rule runs
+// are not overwritten.
+func (e *Engine) run(ctx context.Context, rule Rule, todoId string, action Action) error {
+	t, _, err := e.todoStorage.GetTodoById(ctx, todoId, rule.UserId, rule.WorkspaceId)
+	if err != nil {
+		return err
+	}
+
+	switch action.Type {
+	case ActionAddTag:
+		return e.todoStorage.AddTag(ctx, &t, *action.Tag, rule.UserId)
+	case ActionSetPriority:
+		return e.todoStorage.SetPriority(ctx, &t, action.Priority, rule.UserId)
+	case ActionMoveList:
+		if t.ParentId != nil {
+			return apperror.NewAppError("subtasks move with their parent")
+		}
+		if t.ListId != nil && *t.ListId == *action.ListId {
+			return nil
+		}
+		role, err := e.listStorage.GetRole(ctx, *action.ListId, rule.UserId, t.WorkspaceId)
+		if err != nil || !role.CanEdit() {
+			return apperror.NewAppError("the owner of the rule cannot edit the target list")
+		}
+		return e.todoStorage.MoveToList(ctx, &t, *action.ListId, rule.UserId)
+	case ActionAssign:
+		if t.ListId == nil {
+			return apperror.NewAppError("only todos on a list can be assigned")
+		}
+		if t.AssigneeId != nil && *t.AssigneeId == *action.UserId {
+			return nil
+		}
+		if _, err = e.listStorage.GetRole(ctx, *t.ListId, *action.UserId, t.WorkspaceId); err != nil {
+			return apperror.NewAppError("assignee has no access to the list")
+		}
+		return e.todoStorage.Assign(ctx, &t, action.UserId, rule.UserId)
+	case ActionCreateFollowUp:
+		followUp := todo.Todo{Title: *action.Title, UserId: rule.UserId, ListId: t.ListId, WorkspaceId: t.WorkspaceId}
+		if action.DueInDays != nil {
+			dueAt := time.Now().AddDate(0, 0, *action.DueInDays)
+			followUp.DueAt = &dueAt
+		}
+		return e.todoStorage.Create(ctx, &followUp)
+	}
+	return apperror.NewAppError("unknown action")
+}
+
+// matches reports whether the todo satisfies all the conditions.
+func matches(t todo.Todo, conditions []Condition) bool {
+	for _, condition := range conditions {
+		if !matchCondition(t, condition) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCondition(t todo.Todo, condition Condition) bool {
+	var value string
+	if condition.Value != nil {
+		value = *condition.Value
+	}
+
+	switch condition.Field {
+	case FieldTitle:
+		title, value := strings.ToLower(t.Title), strings.ToLower(value)
+		switch condition.Op {
+		case OpEq:
+			return title == value
+		case OpNeq:
+			return title != value
+		case OpContains:
+			return strings.Contains(title, value)
+		case OpNotContains:
+			return !strings.Contains(title, value)
+		}
+	case FieldTag, FieldContext:
+		elements := t.Tags
+		if condition.Field == FieldContext {
+			elements = t.Contexts
+		}
+		return containsTag(elements, value) == (condition.Op == OpContains)
+	case FieldPriority:
+		return matchOptional(t.Priority, condition.Op, value)
+	case FieldListId:
+		return matchOptional(t.ListId, condition.Op, value)
+	case FieldAssigneeId:
+		return matchOptional(t.AssigneeId, condition.Op, value)
+	case FieldDueAt:
+		return (t.DueAt != nil) == (condition.Op == OpSet)
+	}
+	return false
+}
+
+func matchOptional(field *string, op, value string) bool {
+	switch op {
+	case OpEq:
+		return field != nil && *field == value
+	case OpNeq:
+		return field == nil || *field != value
+	case OpSet:
+		return field != nil
+	case OpUnset:
+		return field == nil
+	}
+	return false
+}
+
+// addedTags returns the tags of current missing from previous.
+func addedTags(previous, current []string) []string {
+	var added []string
+	for _, tag := range current {
+		if !containsTag(previous, tag) {
+			added = append(added, tag)
+		}
+	}
+	return added
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func message(text string) *string {
+	return &text
+}
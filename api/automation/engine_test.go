@@ -0,0 +1,97 @@
+package automation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"todoproject/api/todo"
+)
+
+func value(s string) *string {
+	return &s
+}
+
+func TestMatches(t *testing.T) {
+	due := time.Date(2025, time.June, 11, 9, 0, 0, 0, time.UTC)
+	todos := map[string]todo.Todo{
+		"full": {
+			Title: "Pay the Rent", Tags: []string{"home", "bills"}, Contexts: []string{"@computer"},
+			Priority: value("high"), ListId: value("list-1"), AssigneeId: value("user-1"), DueAt: &due,
+		},
+		"bare": {Title: "Call mom"},
+	}
+	tests := []struct {
+		name       string
+		todo       string
+		conditions []Condition
+		want       bool
+	}{
+		{name: "no conditions", todo: "bare", want: true},
+		{name: "title eq ignores case", todo: "full", conditions: []Condition{{Field: FieldTitle, Op: OpEq, Value: value("pay the rent")}}, want: true},
+		{name: "title neq", todo: "full", conditions: []Condition{{Field: FieldTitle, Op: OpNeq, Value: value("pay the rent")}}, want: false},
+		{name: "title contains", todo: "full", conditions: []Condition{{Field: FieldTitle, Op: OpContains, Value: value("RENT")}}, want: true},
+		{name: "title not contains", todo: "full", conditions: []Condition{{Field: FieldTitle, Op: OpNotContains, Value: value("rent")}}, want: false},
+		{name: "tag contains", todo: "full", conditions: []Condition{{Field: FieldTag, Op: OpContains, Value: value("bills")}}, want: true},
+		{name: "tag contains is exact", todo: "full", conditions: []Condition{{Field: FieldTag, Op: OpContains, Value: value("bill")}}, want: false},
+		{name: "tag not contains", todo: "bare", conditions: []Condition{{Field: FieldTag, Op: OpNotContains, Value: value("home")}}, want: true},
+		{name: "context contains", todo: "full", conditions: []Condition{{Field: FieldContext, Op: OpContains, Value: value("@computer")}}, want: true},
+		{name: "context is not a tag", todo: "full", conditions: []Condition{{Field: FieldContext, Op: OpContains, Value: value("home")}}, want: false},
+		{name: "priority eq", todo: "full", conditions: []Condition{{Field: FieldPriority, Op: OpEq, Value: value("high")}}, want: true},
+		{name: "priority eq unset", todo: "bare", conditions: []Condition{{Field: FieldPriority, Op: OpEq, Value: value("high")}}, want: false},
+		{name: "priority neq unset", todo: "bare", conditions: []Condition{{Field: FieldPriority, Op: OpNeq, Value: value("high")}}, want: true},
+		{name: "list set", todo: "full", conditions: []Condition{{Field: FieldListId, Op: OpSet}}, want: true},
+		{name: "list unset", todo: "full", conditions: []Condition{{Field: FieldListId, Op: OpUnset}}, want: false},
+		{name: "assignee eq", todo: "full", conditions: []Condition{{Field: FieldAssigneeId, Op: OpEq, Value: value("user-2")}}, want: false},
+		{name: "due set", todo: "full", conditions: []Condition{{Field: FieldDueAt, Op: OpSet}}, want: true},
+		{name: "due unset", todo: "bare", conditions: []Condition{{Field: FieldDueAt, Op: OpUnset}}, want: true},
+		{name: "unknown field", todo: "full", conditions: []Condition{{Field: "estimate", Op: OpSet}}, want: false},
+		{
+			name: "all conditions hold", todo: "full",
+			conditions: []Condition{
+				{Field: FieldTag, Op: OpContains, Value: value("home")},
+				{Field: FieldPriority, Op: OpEq, Value: value("high")},
+			},
+			want: true,
+		},
+		{
+			name: "one condition fails", todo: "full",
+			conditions: []Condition{
+				{Field: FieldTag, Op: OpContains, Value: value("home")},
+				{Field: FieldPriority, Op: OpEq, Value: value("low")},
+			},
+			want: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := matches(todos[test.todo], test.conditions); got != test.want {
+				t.Errorf("matches = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAddedTags(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous []string
+		current  []string
+		want     []string
+	}{
+		{name: "no tags", previous: nil, current: nil, want: nil},
+		{name: "first tags", previous: nil, current: []string{"home", "bills"}, want: []string{"home", "bills"}},
+		{name: "unchanged", previous: []string{"home"}, current: []string{"home"}, want: nil},
+		{name: "one added", previous: []string{"home"}, current: []string{"home", "urgent"}, want: []string{"urgent"}},
+		{name: "reordered", previous: []string{"home", "bills"}, current: []string{"bills", "home"}, want: nil},
+		{name: "removed", previous: []string{"home", "bills"}, current: []string{"bills"}, want: nil},
+		{name: "replaced", previous: []string{"home"}, current: []string{"work"}, want: []string{"work"}},
+		{name: "case sensitive", previous: []string{"home"}, current: []string{"Home"}, want: []string{"Home"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := addedTags(test.previous, test.current); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("addedTags = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package automation
+
+import "time"
+
+// Triggers of a rule. Tagged fires when its tag is added to a todo, overdue
+// once per due date when it passes on an open todo.
+const (
+	TriggerCreated   = "created"
+	TriggerCompleted = "completed"
+	TriggerOverdue   = "overdue"
+	TriggerTagged    = "tagged"
+)
+
+var Triggers = map[string]bool{TriggerCreated: true, TriggerCompleted: true, TriggerOverdue: true, TriggerTagged: true}
+
+// Fields a condition can match, tags and contexts being matched by element.
+const (
+	FieldTitle      = "title"
+	FieldTag        = "tag"
+	FieldContext    = "context"
+	FieldPriority   = "priority"
+	FieldListId     = "list_id"
+	FieldAssigneeId = "assignee_id"
+	FieldDueAt      = "due_at"
+)
+
+// Operators of a condition. Set and unset take no value.
+const (
+	OpEq          = "eq"
+	OpNeq         = "neq"
+	OpContains    = "contains"
+	OpNotContains = "not_contains"
+	OpSet         = "set"
+	OpUnset       = "unset"
+)
+
+// fieldOperators are the operators each field accepts.
+var fieldOperators = map[string]map[string]bool{
+	FieldTitle:      {OpEq: true, OpNeq: true, OpContains: true, OpNotContains: true},
+	FieldTag:        {OpContains: true, OpNotContains: true},
+	FieldContext:    {OpContains: true, OpNotContains: true},
+	FieldPriority:   {OpEq: true, OpNeq: true, OpSet: true, OpUnset: true},
+	FieldListId:     {OpEq: true, OpNeq: true, OpSet: true, OpUnset: true},
+	FieldAssigneeId: {OpEq: true, OpNeq: true, OpSet: true, OpUnset: true},
+	FieldDueAt:      {OpSet: true, OpUnset: true},
+}
+
+// Actions of a rule, run in order.
+const (
+	ActionAddTag         = "add_tag"
+	ActionSetPriority    = "set_priority"
+	ActionMoveList       = "move_list"
+	ActionAssign         = "assign"
+	ActionCreateFollowUp = "create_follow_up"
+)
+
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+)
+
+type Rule struct {
+	Id          string  `json:"id"`
+	UserId      string  `json:"user_id"`
+	WorkspaceId *string `json:"workspace_id"`
+	// ListId limits the rule to the todos of a list.
+	ListId     *string     `json:"list_id"`
+	Name       string      `json:"name"`
+	Trigger    string      `json:"trigger"`
+	TriggerTag *string     `json:"trigger_tag"`
+	Conditions []Condition `json:"conditions"`
+	Actions    []Action    `json:"actions"`
+	Active     bool        `json:"active"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// Condition matches a field of the todo, all conditions of a rule must hold.
+type Condition struct {
+	Field string  `json:"field"`
+	Op    string  `json:"op"`
+	Value *string `json:"value,omitempty"`
+}
+
+// Action is a change made to the todo, or a follow-up todo created on its
+// list. Only the parameters of its type are set.
+type Action struct {
+	Type     string  `json:"type"`
+	Tag      *string `json:"tag,omitempty"`
+	Priority *string `json:"priority,omitempty"`
+	ListId   *string `json:"list_id,omitempty"`
+	UserId   *string `json:"user_id,omitempty"`
+	Title    *string `json:"title,omitempty"`
+	// DueInDays gives the follow-up a due date that many days after it is
+	// created.
+	DueInDays *int `json:"due_in_days,omitempty"`
+}
+
+// Execution is an entry of the log of a rule: it ran on the todo, failed on
+// one of its actions, or was skipped by the loop protection.
+type Execution struct {
+	Id         int64     `json:"id"`
+	RuleId     string    `json:"rule_id"`
+	TodoId     *string   `json:"todo_id"`
+	ActivityId *int64    `json:"activity_id"`
+	Status     string    `json:"status"`
+	Message    *string   `json:"message"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Overdue is a todo whose due date passed, claimed for an overdue rule.
+type Overdue struct {
+	RuleId string
+	TodoId string
+}
+
+type RuleDto struct {
+	Name       string      `json:"name" binding:"required"`
+	ListId     *string     `json:"list_id"`
+	Trigger    string      `json:"trigger" binding:"required"`
+	TriggerTag *string     `json:"trigger_tag"`
+	Conditions []Condition `json:"conditions"`
+	Actions    []Action    `json:"actions" binding:"required"`
+	Active     *bool       `json:"active"`
+}
@@ -0,0 +1,16 @@
+package automation
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Rule, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Rule, error)
+	Create(ctx context.Context, rule *Rule) error
+	Update(ctx context.Context, rule *Rule) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	GetMatching(ctx context.Context, workspaceId, listId *string, triggers []string) ([]Rule, error)
+	GetPreviousTags(ctx context.Context, todoId string, activityId int64) ([]string, error)
+	RecordExecution(ctx context.Context, execution *Execution) error
+	GetExecutions(ctx context.Context, ruleId string, limit int) ([]Execution, error)
+	ClaimOverdue(ctx context.Context, limit int) ([]Overdue, error)
+}
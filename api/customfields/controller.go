@@ -0,0 +1,339 @@
+package customfields
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id      = "id"
+	FieldId = "field_id"
+)
+
+const (
+	DateLayout = "2006-01-02"
+	// MaxFields bounds the fields of a list.
+	MaxFields       = 50
+	MaxNameLength   = 50
+	MaxOptions      = 100
+	MaxTextLength   = 1000
+	MaxOptionLength = 50
+)
+
+var (
+	FieldsUrl       = fmt.Sprintf("/lists/:%s/fields", Id)
+	FieldUrl        = fmt.Sprintf("/lists/:%s/fields/:%s", Id, FieldId)
+	CustomFieldsUrl = fmt.Sprintf("/todo/:%s/custom-fields", Id)
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitCustomFieldHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(FieldsUrl, h.GetAll)
+		api.POST(FieldsUrl, h.Create)
+		api.PUT(FieldUrl, h.Update)
+		api.DELETE(FieldUrl, h.Delete)
+		api.PUT(CustomFieldsUrl, h.SetValues)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	listId, ok := h.authorize(ctx, func(role lists.Role) bool { return true })
+	if !ok {
+		return
+	}
+	fields, err := h.Storage.GetByList(ctx, listId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get fields"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", fields))
+}
+
+// Create adds a field to the list, it needs the manage permission.
+func (h *Handler) Create(ctx *gin.Context) {
+	var fieldDto CreateFieldDto
+	if err := ctx.ShouldBindJSON(&fieldDto); err != nil {
+		h.Log.Errorf("failed to bind field. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !Types[fieldDto.Type] {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid field type"))
+		return
+	}
+	if message := validateField(fieldDto.Name, fieldDto.Type, fieldDto.Options); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	listId, ok := h.authorize(ctx, lists.Role.CanManage)
+	if !ok {
+		return
+	}
+	field := Field{ListId: listId, Name: fieldDto.Name, Type: fieldDto.Type, Options: options(fieldDto.Options)}
+	if err := h.Storage.Create(ctx, &field); err != nil {
+		h.abortConflict(ctx, err, "failed to create field")
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", field))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var fieldDto UpdateFieldDto
+	if err := ctx.ShouldBindJSON(&fieldDto); err != nil {
+		h.Log.Errorf("failed to bind field. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	listId, ok := h.authorize(ctx, lists.Role.CanManage)
+	if !ok {
+		return
+	}
+	field, ok := h.field(ctx, listId)
+	if !ok {
+		return
+	}
+	if message := validateField(fieldDto.Name, field.Type, fieldDto.Options); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	field.Name, field.Options = fieldDto.Name, options(fieldDto.Options)
+	if err := h.Storage.Update(ctx, &field, fieldDto.Position); err != nil {
+		h.abortConflict(ctx, err, "failed to update field")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", field))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	listId, ok := h.authorize(ctx, lists.Role.CanManage)
+	if !ok {
+		return
+	}
+	if err := h.Storage.Delete(ctx, ctx.Param(FieldId), listId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete field")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// SetValues fills in custom fields of the todo from an object of values by
+// field id, null clearing a field. Values are checked against the type of
+// their field, which must belong to the todo's list.
+func (h *Handler) SetValues(ctx *gin.Context) {
+	var values map[string]json.RawMessage
+	if err := ctx.ShouldBindJSON(&values); err != nil {
+		h.Log.Errorf("failed to bind custom fields. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	t, role, err := h.todoStorage.GetTodoById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if !role.CanEdit() {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return
+	}
+	if t.ListId == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "only todos on a list have custom fields"))
+		return
+	}
+
+	fields, err := h.Storage.GetByList(ctx, *t.ListId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get fields"))
+		return
+	}
+	byId := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byId[field.Id] = field
+	}
+	for id, value := range values {
+		field, ok := byId[id]
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", fmt.Sprintf("unknown field %q", id)))
+			return
+		}
+		if string(value) == "null" {
+			continue
+		}
+		if message := validateValue(field, value); message != "" {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", field.Name+": "+message))
+			return
+		}
+	}
+
+	if err = h.todoStorage.SetCustomFields(ctx, &t, values, user.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to set custom fields")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", t))
+}
+
+// authorize checks the user's role on the list of the request. Lists the user
+// cannot see are 404.
+func (h *Handler) authorize(ctx *gin.Context, allowed func(lists.Role) bool) (string, bool) {
+	user := users.GetCurrentUser(ctx)
+	listId := ctx.Param(Id)
+	role, err := h.listStorage.GetRole(ctx, listId, user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return "", false
+	}
+	if !allowed(role) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return "", false
+	}
+	return listId, true
+}
+
+func (h *Handler) field(ctx *gin.Context, listId string) (Field, bool) {
+	fields, err := h.Storage.GetByList(ctx, listId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get fields"))
+		return Field{}, false
+	}
+	for _, field := range fields {
+		if field.Id == ctx.Param(FieldId) {
+			return field, true
+		}
+	}
+	ctx.AbortWithStatusJSON(http.StatusNotFound, apperror.NewJsonMessage("fail", "field not found"))
+	return Field{}, false
+}
+
+func (h *Handler) abortConflict(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, apperror.ErrConflict) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail",
+			fmt.Sprintf("field names must be unique and a list has at most %d fields", MaxFields)))
+		return
+	}
+	apperror.AbortWithError(ctx, err, message)
+}
+
+func options(options []string) []string {
+	if options == nil {
+		return make([]string, 0)
+	}
+	return options
+}
+
+// validateField returns why a field cannot be saved, or an empty string. Only
+// select fields have options, at least one of them.
+func validateField(name, fieldType string, options []string) string {
+	if len([]rune(name)) > MaxNameLength {
+		return fmt.Sprintf("field names have at most %d characters", MaxNameLength)
+	}
+	isSelect := fieldType == TypeSingleSelect || fieldType == TypeMultiSelect
+	if !isSelect {
+		if len(options) > 0 {
+			return "only select fields have options"
+		}
+		return ""
+	}
+	if len(options) == 0 || len(options) > MaxOptions {
+		return fmt.Sprintf("select fields need between 1 and %d options", MaxOptions)
+	}
+	seen := make(map[string]bool, len(options))
+	for _, option := range options {
+		if option == "" || len([]rune(option)) > MaxOptionLength {
+			return fmt.Sprintf("options must have between 1 and %d characters", MaxOptionLength)
+		}
+		if seen[option] {
+			return "options must be unique"
+		}
+		seen[option] = true
+	}
+	return ""
+}
+
+// validateValue returns why the value does not fit the field, or an empty
+// string.
+func validateValue(field Field, value json.RawMessage) string {
+	switch field.Type {
+	case TypeText:
+		var text string
+		if json.Unmarshal(value, &text) != nil {
+			return "must be a string"
+		}
+		if len([]rune(text)) > MaxTextLength {
+			return fmt.Sprintf("must have at most %d characters", MaxTextLength)
+		}
+	case TypeNumber:
+		var number float64
+		if json.Unmarshal(value, &number) != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return "must be a number"
+		}
+	case TypeDate:
+		var date string
+		if json.Unmarshal(value, &date) != nil {
+			return "must be a date as " + DateLayout
+		}
+		if _, err := time.Parse(DateLayout, date); err != nil {
+			return "must be a date as " + DateLayout
+		}
+	case TypeSingleSelect:
+		var option string
+		if json.Unmarshal(value, &option) != nil || !contains(field.Options, option) {
+			return "must be one of the options"
+		}
+	case TypeMultiSelect:
+		var selected []string
+		if json.Unmarshal(value, &selected) != nil || selected == nil {
+			return "must be an array of options"
+		}
+		seen := make(map[string]bool, len(selected))
+		for _, option := range selected {
+			if !contains(field.Options, option) || seen[option] {
+				return "must list distinct options"
+			}
+			seen[option] = true
+		}
+	case TypeCheckbox:
+		var checked bool
+		if json.Unmarshal(value, &checked) != nil {
+			return "must be a boolean"
+		}
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package customfields
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate key, raised for a
+// second field of the same name on a list.
+const uniqueViolation = "23505"
+
+const fieldColumns = `id, list_id, name, type, options, position, created_at`
+
+var QueryGetByList = `SELECT ` + fieldColumns + ` FROM list_fields WHERE list_id = $1 ORDER BY position, created_at`
+var QueryLockList = `SELECT id FROM lists WHERE id = $1 FOR UPDATE`
+var QueryCountByList = `SELECT count(*) FROM list_fields WHERE list_id = $1`
+var QueryCreate = `INSERT INTO list_fields (list_id, name, type, options, position)
+	VALUES ($1, $2, $3, $4, (SELECT COALESCE(max(position) + 1, 0) FROM list_fields WHERE list_id = $1))
+	RETURNING ` + fieldColumns
+var QueryUpdate = `UPDATE list_fields SET name = $3, options = $4, position = COALESCE($5, position)
+	WHERE id = $1 AND list_id = $2 RETURNING ` + fieldColumns
+
+// QueryDropSingleOptions and QueryDropMultiOptions remove the values of
+// options that no longer exist, an emptied multi-select being cleared.
+var QueryDropSingleOptions = `DELETE FROM todo_field_values WHERE field_id = $1 AND NOT (value #>> '{}') = ANY($2)`
+var QueryDropMultiOptions = `UPDATE todo_field_values SET value = (SELECT COALESCE(jsonb_agg(e), '[]')
+	FROM jsonb_array_elements(value) e WHERE e #>> '{}' = ANY($2))
+	WHERE field_id = $1 AND EXISTS (SELECT 1 FROM jsonb_array_elements(value) e WHERE NOT (e #>> '{}') = ANY($2))`
+var QueryClearEmptyMulti = `DELETE FROM todo_field_values WHERE field_id = $1 AND value = '[]'`
+
+// QueryTouchTodos rewrites the todos having a value for the field unchanged,
+// so that the sync triggers see the values change with the field.
+var QueryTouchTodos = `UPDATE todo SET title = title WHERE id IN (SELECT todo_id FROM todo_field_values WHERE field_id = $1)`
+var QueryDelete = `DELETE FROM list_fields WHERE id = $1 AND list_id = $2`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetByList(ctx context.Context, listId string) ([]Field, error) {
+	rows, err := s.db.Query(ctx, QueryGetByList, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query fields of list id=(%s). due to error: %v", listId, err)
+		return nil, err
+	}
+	fields := make([]Field, 0)
+	for rows.Next() {
+		var field Field
+		if errScan := scanField(rows, &field); errScan != nil {
+			s.log.Errorf("failed to scan field. due to error: %v", errScan)
+			return nil, errScan
+		}
+		fields = append(fields, field)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return fields, nil
+}
+
+// Create adds the field at the end of the list's fields. A duplicate name or a
+// list already having MaxFields fields is apperror.ErrConflict.
+func (s *Storage) Create(ctx context.Context, field *Field) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// serializes the creations on the list, for the positions and the limit
+	if _, err = tx.Exec(ctx, QueryLockList, field.ListId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	var count int
+	if err = tx.QueryRow(ctx, QueryCountByList, field.ListId).Scan(&count); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if count >= MaxFields {
+		return apperror.ErrConflict
+	}
+	if err = scanField(tx.QueryRow(ctx, QueryCreate, field.ListId, field.Name, field.Type, field.Options), field); err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create field on list id=(%s). due to error: %v", field.ListId, err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update renames the field, moves it to the position when one is given and
+// replaces its options, dropping the values of the options removed from a
+// select field.
+func (s *Storage) Update(ctx context.Context, field *Field, position *int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = scanField(tx.QueryRow(ctx, QueryUpdate, field.Id, field.ListId, field.Name, field.Options, position), field)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update field id=(%s). due to error: %v", field.Id, err)
+		return err
+	}
+
+	var queries []string
+	switch field.Type {
+	case TypeSingleSelect:
+		queries = []string{QueryDropSingleOptions}
+	case TypeMultiSelect:
+		queries = []string{QueryDropMultiOptions, QueryClearEmptyMulti}
+	}
+	if len(queries) > 0 {
+		if _, err = tx.Exec(ctx, QueryTouchTodos, field.Id); err != nil {
+			s.TraceQueryError(err)
+			return err
+		}
+	}
+	for _, query := range queries {
+		if _, err = tx.Exec(ctx, query, field.Id, field.Options); err != nil {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to drop removed options of field id=(%s). due to error: %v", field.Id, err)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// Delete removes the field with its values.
+func (s *Storage) Delete(ctx context.Context, id, listId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryTouchTodos, id); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	tag, err := tx.Exec(ctx, QueryDelete, id, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete field id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func scanField(row pgx.Row, field *Field) error {
+	return row.Scan(&field.Id, &field.ListId, &field.Name, &field.Type, &field.Options, &field.Position, &field.CreatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
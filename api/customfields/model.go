@@ -0,0 +1,44 @@
+package customfields
+
+import "time"
+
+// Types of custom fields.
+const (
+	TypeText         = "text"
+	TypeNumber       = "number"
+	TypeDate         = "date"
+	TypeSingleSelect = "single_select"
+	TypeMultiSelect  = "multi_select"
+	TypeCheckbox     = "checkbox"
+)
+
+var Types = map[string]bool{
+	TypeText: true, TypeNumber: true, TypeDate: true,
+	TypeSingleSelect: true, TypeMultiSelect: true, TypeCheckbox: true,
+}
+
+// Field is a typed field defined on a list and filled in on its todos. Options
+// are the choices of select fields.
+type Field struct {
+	Id        string    `json:"id"`
+	ListId    string    `json:"list_id"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Options   []string  `json:"options"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateFieldDto struct {
+	Name    string   `json:"name" binding:"required"`
+	Type    string   `json:"type" binding:"required"`
+	Options []string `json:"options"`
+}
+
+// UpdateFieldDto renames a field or changes its options, the type of a field
+// cannot change. Values of removed options are dropped from the todos.
+type UpdateFieldDto struct {
+	Name     string   `json:"name" binding:"required"`
+	Options  []string `json:"options"`
+	Position *int     `json:"position"`
+}
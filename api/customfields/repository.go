@@ -0,0 +1,10 @@
+package customfields
+
+import "context"
+
+type Repository interface {
+	GetByList(ctx context.Context, listId string) ([]Field, error)
+	Create(ctx context.Context, field *Field) error
+	Update(ctx context.Context, field *Field, position *int) error
+	Delete(ctx context.Context, id, listId string) error
+}
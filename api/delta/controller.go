@@ -0,0 +1,396 @@
+package delta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/api/workspaces"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const Since = "since"
+
+const (
+	changesLimit   = 1000
+	mutationsLimit = 500
+)
+
+var SyncUrl = "/sync"
+
+type Handler struct {
+	Storage     *Storage
+	todoStorage *todo.Storage
+	listStorage *lists.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, todoStorage *todo.Storage, listStorage *lists.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, todoStorage: todoStorage, listStorage: listStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitSyncHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(SyncUrl, h.Pull)
+		api.POST(SyncUrl, h.Push)
+	}
+}
+
+// Pull returns the todos and lists of the active workspace changed or
+// tombstoned since the token of the request. Without a token it returns the
+// full state. While has_more is set the client should pull again right away.
+func (h *Handler) Pull(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+
+	since, ok := ctx.GetQuery(Since)
+	if !ok || since == "" {
+		h.snapshot(ctx, user)
+		return
+	}
+	token, err := strconv.ParseInt(since, 10, 64)
+	if err != nil || token < 0 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid sync token"))
+		return
+	}
+
+	changes, err := h.Storage.GetChanges(ctx, user.Id, user.WorkspaceId, token, changesLimit+1)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get changes"))
+		return
+	}
+	delta := Delta{Todos: make([]todo.Todo, 0), Lists: make([]lists.List, 0), Tombstones: make([]Tombstone, 0)}
+	if len(changes) > changesLimit {
+		changes, delta.HasMore = changes[:changesLimit], true
+	}
+
+	var todoIds, listIds []string
+	for _, change := range changes {
+		switch {
+		case change.Deleted:
+			delta.Tombstones = append(delta.Tombstones, Tombstone{Entity: change.Entity, Id: change.EntityId})
+		case change.Entity == EntityTodo:
+			todoIds = append(todoIds, change.EntityId)
+		case change.Entity == EntityList:
+			listIds = append(listIds, change.EntityId)
+		}
+		token = change.Seq
+	}
+
+	// an entity may have been deleted after its change was read, its tombstone
+	// will follow on the next pull but the client can drop it already
+	if len(todoIds) > 0 {
+		if delta.Todos, err = h.todoStorage.GetByIds(ctx, todoIds, user.Id); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+			return
+		}
+		delta.Tombstones = appendMissing(delta.Tombstones, EntityTodo, todoIds, len(delta.Todos), func(i int) string { return delta.Todos[i].Id })
+	}
+	if len(listIds) > 0 {
+		if delta.Lists, err = h.listStorage.GetByIds(ctx, listIds, user.Id); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get lists"))
+			return
+		}
+		delta.Tombstones = appendMissing(delta.Tombstones, EntityList, listIds, len(delta.Lists), func(i int) string { return delta.Lists[i].Id })
+	}
+
+	delta.Token = strconv.FormatInt(token, 10)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", delta))
+}
+
+// snapshot answers a first sync. The token is read before the state, so a
+// change committed in between is sent again on the next pull rather than lost.
+func (h *Handler) snapshot(ctx *gin.Context, user users.User) {
+	token, err := h.Storage.GetSequence(ctx, user.Id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get sync token"))
+		return
+	}
+	todos, err := h.todoStorage.GetAllTodoByUserId(ctx, user.Id, user.WorkspaceId, nil)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+		return
+	}
+	allLists, err := h.listStorage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get lists"))
+		return
+	}
+	delta := Delta{Token: strconv.FormatInt(token, 10), Todos: todos, Lists: allLists, Tombstones: make([]Tombstone, 0)}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", delta))
+}
+
+// Push applies a batch of offline mutations in order. Each mutation succeeds
+// or fails on its own and the results come back in the same order.
+func (h *Handler) Push(ctx *gin.Context) {
+	var pushDto PushDto
+	if err := ctx.ShouldBindJSON(&pushDto); err != nil {
+		h.Log.Errorf("failed to bind mutations. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if len(pushDto.Mutations) > mutationsLimit {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "too many mutations"))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	results := make([]Result, 0, len(pushDto.Mutations))
+	for _, mutation := range pushDto.Mutations {
+		result := Result{Op: mutation.Op, Entity: mutation.Entity, Id: mutation.Id, Status: StatusApplied}
+		switch mutation.Entity {
+		case EntityTodo:
+			h.applyTodo(ctx, user, mutation, &result)
+		case EntityList:
+			h.applyList(ctx, user, mutation, &result)
+		default:
+			result.Status, result.Message = StatusFailed, "unknown entity"
+		}
+		results = append(results, result)
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", results))
+}
+
+func (h *Handler) applyTodo(ctx context.Context, user users.User, mutation Mutation, result *Result) {
+	if mutation.Op == OpCreate {
+		created := todo.Todo{Id: mutation.Id, UserId: user.Id, WorkspaceId: user.WorkspaceId}
+		fields := todoPointers(&created)
+		fields[FieldListId] = &created.ListId
+		if !bindFields(mutation, fields, result) {
+			return
+		}
+		if message := validTodo(&created); message != "" {
+			result.Status, result.Message = StatusFailed, message
+			return
+		}
+		err := h.todoStorage.Create(ctx, &created)
+		if errors.Is(err, apperror.ErrConflict) {
+			// the mutation was already applied by an earlier push, the id of a
+			// todo of someone else must not reveal that it exists
+			created, _, err = h.todoStorage.GetTodoById(ctx, mutation.Id, user.Id, user.WorkspaceId)
+			if err == nil && created.UserId != user.Id {
+				err = apperror.ErrNotFound
+			}
+		}
+		if err != nil {
+			failed(result, err, "failed to create todo")
+			return
+		}
+		result.Id, result.Todo = created.Id, &created
+		return
+	}
+	if name := unknownField(mutation, todoPointers(&todo.Todo{})); name != "" {
+		result.Status, result.Message = StatusFailed, fmt.Sprintf("%s cannot be synced on %s", name, mutation.Op)
+		return
+	}
+
+	current, role, err := h.todoStorage.GetTodoById(ctx, mutation.Id, user.Id, user.WorkspaceId)
+	if errors.Is(err, apperror.ErrNotFound) {
+		if mutation.Op != OpDelete {
+			result.Status, result.Message = StatusConflict, "deleted on the server"
+		}
+		return
+	}
+	if err != nil {
+		failed(result, err, "failed to get todo")
+		return
+	}
+	if !role.CanEdit() {
+		failed(result, apperror.ErrForbidden, "")
+		return
+	}
+
+	switch mutation.Op {
+	case OpUpdate:
+		merged := current
+		conflicts, changed, err := mergeFields(mutation, todoPointers(&merged))
+		if err != nil {
+			result.Status, result.Message = StatusFailed, err.Error()
+			return
+		}
+		result.Conflicts = conflicts
+		if changed {
+			if message := validTodo(&merged); message != "" {
+				result.Status, result.Message = StatusFailed, message
+				return
+			}
+			if err = h.todoStorage.Update(ctx, &merged, user.Id); err != nil {
+				failed(result, err, "failed to update todo")
+				return
+			}
+		}
+		result.Todo = &merged
+	case OpDelete:
+		conflicts, err := staleFields(mutation.Base, todoPointers(&current))
+		if err != nil {
+			result.Status, result.Message = StatusFailed, err.Error()
+			return
+		}
+		result.Conflicts = conflicts
+		if len(result.Conflicts) == 0 {
+			if err = h.todoStorage.Delete(ctx, current.Id, user.Id, user.WorkspaceId); err != nil {
+				failed(result, err, "failed to delete todo")
+			}
+			return
+		}
+		result.Todo = &current
+	default:
+		result.Status, result.Message = StatusFailed, "unknown op"
+		return
+	}
+	if len(result.Conflicts) > 0 {
+		result.Status = StatusConflict
+	}
+}
+
+func (h *Handler) applyList(ctx context.Context, user users.User, mutation Mutation, result *Result) {
+	if mutation.Op == OpCreate {
+		if user.WorkspaceRole == string(workspaces.RoleGuest) {
+			result.Status, result.Message = StatusFailed, "guests cannot create lists"
+			return
+		}
+		created := lists.List{Id: mutation.Id, OwnerId: user.Id, WorkspaceId: user.WorkspaceId}
+		if !bindFields(mutation, listPointers(&created), result) {
+			return
+		}
+		if message := validList(created, user); message != "" {
+			result.Status, result.Message = StatusFailed, message
+			return
+		}
+		err := h.listStorage.Create(ctx, &created)
+		if errors.Is(err, apperror.ErrConflict) {
+			// the mutation was already applied by an earlier push, the id of a
+			// list of someone else must not reveal that it exists
+			created, err = h.listStorage.GetById(ctx, mutation.Id, user.Id, user.WorkspaceId)
+			if err == nil && created.OwnerId != user.Id {
+				err = apperror.ErrNotFound
+			}
+		}
+		if err != nil {
+			failed(result, err, "failed to create list")
+			return
+		}
+		result.Id, result.List = created.Id, &created
+		return
+	}
+	if name := unknownField(mutation, listPointers(&lists.List{})); name != "" {
+		result.Status, result.Message = StatusFailed, fmt.Sprintf("%s cannot be synced on %s", name, mutation.Op)
+		return
+	}
+
+	current, err := h.listStorage.GetById(ctx, mutation.Id, user.Id, user.WorkspaceId)
+	if errors.Is(err, apperror.ErrNotFound) {
+		if mutation.Op != OpDelete {
+			result.Status, result.Message = StatusConflict, "deleted on the server"
+		}
+		return
+	}
+	if err != nil {
+		failed(result, err, "failed to get list")
+		return
+	}
+
+	switch mutation.Op {
+	case OpUpdate:
+		if !current.Role.CanManage() {
+			failed(result, apperror.ErrForbidden, "")
+			return
+		}
+		merged := current
+		conflicts, changed, err := mergeFields(mutation, listPointers(&merged))
+		if err != nil {
+			result.Status, result.Message = StatusFailed, err.Error()
+			return
+		}
+		result.Conflicts = conflicts
+		if changed {
+			if message := validList(merged, user); message != "" {
+				result.Status, result.Message = StatusFailed, message
+				return
+			}
+			if err = h.listStorage.Update(ctx, &merged, user.Id); err != nil {
+				failed(result, err, "failed to update list")
+				return
+			}
+		}
+		result.List = &merged
+	case OpDelete:
+		if current.Role != lists.RoleOwner {
+			failed(result, apperror.ErrForbidden, "")
+			return
+		}
+		conflicts, err := staleFields(mutation.Base, listPointers(&current))
+		if err != nil {
+			result.Status, result.Message = StatusFailed, err.Error()
+			return
+		}
+		result.Conflicts = conflicts
+		if len(result.Conflicts) == 0 {
+			if err = h.listStorage.Delete(ctx, current.Id, user.Id); err != nil {
+				failed(result, err, "failed to delete list")
+			}
+			return
+		}
+		result.List = &current
+	default:
+		result.Status, result.Message = StatusFailed, "unknown op"
+		return
+	}
+	if len(result.Conflicts) > 0 {
+		result.Status = StatusConflict
+	}
+}
+
+// bindFields writes the fields of a create into the new entity. A field the
+// entity does not have or an invalid value fails the mutation.
+func bindFields(mutation Mutation, fields map[string]interface{}, result *Result) bool {
+	if name := unknownField(mutation, fields); name != "" {
+		result.Status, result.Message = StatusFailed, fmt.Sprintf("%s cannot be synced on %s", name, mutation.Op)
+		return false
+	}
+	if err := setFields(mutation.Fields, fields); err != nil {
+		result.Status, result.Message = StatusFailed, err.Error()
+		return false
+	}
+	return true
+}
+
+func validList(list lists.List, user users.User) string {
+	if list.Title == "" {
+		return "title is required"
+	}
+	if !lists.ValidVisibility(list.Visibility, user.WorkspaceId) {
+		return "invalid visibility"
+	}
+	return ""
+}
+
+func failed(result *Result, err error, message string) {
+	result.Status, result.Message = StatusFailed, message
+	if apperror.StatusCode(err) != http.StatusBadRequest {
+		result.Message = err.Error()
+	}
+}
+
+// appendMissing tombstones the requested ids the user could not be given.
+func appendMissing(tombstones []Tombstone, entity string, ids []string, found int, idAt func(int) string) []Tombstone {
+	present := make(map[string]bool, found)
+	for i := 0; i < found; i++ {
+		present[idAt(i)] = true
+	}
+	for _, id := range ids {
+		if !present[id] {
+			tombstones = append(tombstones, Tombstone{Entity: entity, Id: id})
+		}
+	}
+	return tombstones
+}
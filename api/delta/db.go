@@ -0,0 +1,68 @@
+package delta
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/db"
+)
+
+// sync_changes is filled by triggers, see pgconsole/create_sync_triggers.sql.
+var QueryGetChanges = `SELECT entity, entity_id, seq, deleted FROM sync_changes
+	WHERE user_id = $1 AND workspace_id IS NOT DISTINCT FROM $2 AND seq > $3
+	ORDER BY seq LIMIT $4`
+var QueryGetSequence = `SELECT COALESCE((SELECT seq FROM sync_sequences WHERE user_id = $1), 0)`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+// GetChanges returns, in sequence order, the changes of the user in the
+// workspace that come after since.
+func (s *Storage) GetChanges(ctx context.Context, userId string, workspaceId *string, since int64, limit int) ([]Change, error) {
+	rows, err := s.db.Query(ctx, QueryGetChanges, userId, workspaceId, since, limit)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query changes of user_id=(%s). due to error: %v", userId, err)
+		return nil, err
+	}
+	changes := make([]Change, 0)
+	for rows.Next() {
+		var change Change
+		errScan := rows.Scan(&change.Entity, &change.EntityId, &change.Seq, &change.Deleted)
+		if errScan != nil {
+			s.log.Errorf("failed to scan change. due to error: %v", errScan)
+			return nil, errScan
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return changes, nil
+}
+
+// GetSequence returns the sequence of the latest change of the user.
+func (s *Storage) GetSequence(ctx context.Context, userId string) (seq int64, err error) {
+	if err = s.db.QueryRow(ctx, QueryGetSequence, userId).Scan(&seq); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get sequence of user_id=(%s). due to error: %v", userId, err)
+		return 0, err
+	}
+	return seq, nil
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,195 @@
+package delta
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+)
+
+// FieldListId sets the list of a todo on create. Todos change lists through
+// their own endpoint, so it is refused on other ops.
+const FieldListId = "list_id"
+
+// todoFields are the fields of a todo a mutation can carry, the ones
+// todo.Storage.Update writes. Each returns a pointer to its field.
+var todoFields = map[string]func(t *todo.Todo) interface{}{
+	"title":             func(t *todo.Todo) interface{} { return &t.Title },
+	"due_at":            func(t *todo.Todo) interface{} { return &t.DueAt },
+	"tags":              func(t *todo.Todo) interface{} { return &t.Tags },
+	"priority":          func(t *todo.Todo) interface{} { return &t.Priority },
+	"recurrence":        func(t *todo.Todo) interface{} { return &t.Recurrence },
+	"estimated_minutes": func(t *todo.Todo) interface{} { return &t.EstimatedMinutes },
+	"contexts":          func(t *todo.Todo) interface{} { return &t.Contexts },
+	"next_action":       func(t *todo.Todo) interface{} { return &t.NextAction },
+}
+
+// listFields are the fields of a list a mutation can carry.
+var listFields = map[string]func(l *lists.List) interface{}{
+	"title":      func(l *lists.List) interface{} { return &l.Title },
+	"visibility": func(l *lists.List) interface{} { return &l.Visibility },
+}
+
+func todoPointers(t *todo.Todo) map[string]interface{} {
+	pointers := make(map[string]interface{}, len(todoFields))
+	for name, field := range todoFields {
+		pointers[name] = field(t)
+	}
+	return pointers
+}
+
+func listPointers(l *lists.List) map[string]interface{} {
+	pointers := make(map[string]interface{}, len(listFields))
+	for name, field := range listFields {
+		pointers[name] = field(l)
+	}
+	return pointers
+}
+
+// unknownField returns a field of the mutation that is not in fields, or an
+// empty string. Such a mutation is refused rather than partly applied.
+func unknownField(mutation Mutation, fields map[string]interface{}) string {
+	for _, values := range []Fields{mutation.Fields, mutation.Base} {
+		for _, name := range sortedNames(values) {
+			if _, ok := fields[name]; !ok {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// setFields writes the values into the fields, as on create.
+func setFields(values Fields, fields map[string]interface{}) error {
+	for _, name := range sortedNames(values) {
+		if err := assign(values[name], fields[name]); err != nil {
+			return fmt.Errorf("invalid %s", name)
+		}
+	}
+	return nil
+}
+
+// mergeFields merges the mutation field by field into the server values the
+// fields point to. A field is applied unless the server value moved away from
+// the base the client edited, then the server value is kept and a conflict is
+// reported. Fields sent without a base value are last-writer-wins. It reports
+// whether a value changed.
+func mergeFields(mutation Mutation, fields map[string]interface{}) ([]FieldConflict, bool, error) {
+	var conflicts []FieldConflict
+	changed := false
+	for _, name := range sortedNames(mutation.Fields) {
+		field := fields[name]
+		server, err := canonical(field)
+		if err != nil {
+			return nil, false, err
+		}
+		client, err := decodeCanonical(mutation.Fields[name], field)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %s", name)
+		}
+		if string(client) == string(server) {
+			continue
+		}
+		if raw, ok := mutation.Base[name]; ok {
+			base, err := decodeCanonical(raw, field)
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid base %s", name)
+			}
+			if string(base) != string(server) {
+				conflicts = append(conflicts, FieldConflict{Field: name, Base: base, Client: client, Server: server})
+				continue
+			}
+		}
+		if err = assign(client, field); err != nil {
+			return nil, false, fmt.Errorf("invalid %s", name)
+		}
+		changed = true
+	}
+	return conflicts, changed, nil
+}
+
+// staleFields reports the fields changed on the server since the client saw
+// them. A delete made against stale values is not applied.
+func staleFields(base Fields, fields map[string]interface{}) ([]FieldConflict, error) {
+	var conflicts []FieldConflict
+	for _, name := range sortedNames(base) {
+		server, err := canonical(fields[name])
+		if err != nil {
+			return nil, err
+		}
+		seen, err := decodeCanonical(base[name], fields[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid base %s", name)
+		}
+		if string(seen) != string(server) {
+			conflicts = append(conflicts, FieldConflict{Field: name, Base: seen, Server: server})
+		}
+	}
+	return conflicts, nil
+}
+
+// assign decodes the value into the field. The field is cleared first: the
+// entity may be a copy sharing its pointers with the server state.
+func assign(raw json.RawMessage, pointer interface{}) error {
+	field := reflect.ValueOf(pointer).Elem()
+	field.Set(reflect.Zero(field.Type()))
+	return json.Unmarshal(raw, pointer)
+}
+
+// canonical encodes the value the pointer points to so that equal values
+// encode the same, times being written in UTC.
+func canonical(pointer interface{}) (json.RawMessage, error) {
+	switch value := pointer.(type) {
+	case **time.Time:
+		if *value != nil {
+			return json.Marshal((*value).UTC())
+		}
+	case *[]string:
+		if *value == nil {
+			return json.Marshal([]string{})
+		}
+	}
+	return json.Marshal(pointer)
+}
+
+// decodeCanonical decodes a client value as the type of the field and
+// returns its canonical encoding.
+func decodeCanonical(raw json.RawMessage, pointer interface{}) (json.RawMessage, error) {
+	value := reflect.New(reflect.TypeOf(pointer).Elem()).Interface()
+	if err := json.Unmarshal(raw, value); err != nil {
+		return nil, err
+	}
+	return canonical(value)
+}
+
+func sortedNames(values Fields) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validTodo checks the synced fields of the todo as the todo endpoints do and
+// normalizes its contexts. It returns the problem found, if any.
+func validTodo(t *todo.Todo) string {
+	if t.Title == "" {
+		return "title is required"
+	}
+	if t.Priority != nil && !todo.Priorities[*t.Priority] {
+		return "invalid priority"
+	}
+	if t.EstimatedMinutes != nil && *t.EstimatedMinutes <= 0 {
+		return "invalid estimated_minutes"
+	}
+	contexts, message := todo.NormalizeContexts(t.Contexts)
+	if message != "" {
+		return message
+	}
+	t.Contexts = contexts
+	return ""
+}
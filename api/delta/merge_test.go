@@ -0,0 +1,140 @@
+package delta
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+	"todoproject/api/todo"
+)
+
+func TestMergeFields(t *testing.T) {
+	due := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	high := "high"
+	server := todo.Todo{Title: "server", DueAt: &due, Tags: []string{"a"}, Priority: &high}
+
+	tests := []struct {
+		name          string
+		base          Fields
+		fields        Fields
+		wantConflicts []string
+		wantChanged   bool
+		check         func(t *testing.T, merged todo.Todo)
+	}{
+		{
+			name:        "applies fields the server did not change",
+			base:        Fields{"tags": json.RawMessage(`["a"]`), "priority": json.RawMessage(`"high"`)},
+			fields:      Fields{"tags": json.RawMessage(`["a","b"]`), "priority": json.RawMessage(`null`)},
+			wantChanged: true,
+			check: func(t *testing.T, merged todo.Todo) {
+				if len(merged.Tags) != 2 || merged.Priority != nil {
+					t.Errorf("merged = %v %v, want tags a b without priority", merged.Tags, merged.Priority)
+				}
+			},
+		},
+		{
+			name:          "keeps server values changed since the base",
+			base:          Fields{"title": json.RawMessage(`"old"`), "due_at": json.RawMessage(`null`)},
+			fields:        Fields{"title": json.RawMessage(`"client"`), "due_at": json.RawMessage(`"2026-10-20T09:00:00Z"`)},
+			wantConflicts: []string{"due_at", "title"},
+			check: func(t *testing.T, merged todo.Todo) {
+				if merged.Title != "server" || !merged.DueAt.Equal(due) {
+					t.Errorf("merged = %q %v, want the server values", merged.Title, merged.DueAt)
+				}
+			},
+		},
+		{
+			name: "compares times as instants",
+			base: Fields{"due_at": json.RawMessage(`"2026-10-19T11:00:00+02:00"`)},
+			fields: Fields{"due_at": json.RawMessage(`"2026-10-21T09:00:00Z"`),
+				"next_action": json.RawMessage(`true`)},
+			wantChanged: true,
+			check: func(t *testing.T, merged todo.Todo) {
+				if !merged.DueAt.Equal(due.AddDate(0, 0, 2)) || !merged.NextAction {
+					t.Errorf("merged = %v %v, want the client values", merged.DueAt, merged.NextAction)
+				}
+			},
+		},
+		{
+			name:   "ignores values equal to the server",
+			fields: Fields{"title": json.RawMessage(`"server"`), "tags": json.RawMessage(`["a"]`)},
+		},
+		{
+			name:        "applies fields without base",
+			fields:      Fields{"estimated_minutes": json.RawMessage(`30`)},
+			wantChanged: true,
+			check: func(t *testing.T, merged todo.Todo) {
+				if merged.EstimatedMinutes == nil || *merged.EstimatedMinutes != 30 {
+					t.Errorf("estimated_minutes = %v, want 30", merged.EstimatedMinutes)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := server
+			conflicts, changed, err := mergeFields(Mutation{Base: tt.base, Fields: tt.fields}, todoPointers(&merged))
+			if err != nil {
+				t.Fatalf("mergeFields() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("mergeFields() changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if len(conflicts) != len(tt.wantConflicts) {
+				t.Fatalf("mergeFields() conflicts = %+v, want %v", conflicts, tt.wantConflicts)
+			}
+			for i, conflict := range conflicts {
+				if conflict.Field != tt.wantConflicts[i] {
+					t.Errorf("conflict %d on %q, want %q", i, conflict.Field, tt.wantConflicts[i])
+				}
+			}
+			if tt.check != nil {
+				tt.check(t, merged)
+			}
+		})
+	}
+}
+
+func TestUnknownField(t *testing.T) {
+	fields := todoPointers(&todo.Todo{})
+	if name := unknownField(Mutation{Fields: Fields{"title": nil, "tags": nil}}, fields); name != "" {
+		t.Errorf("unknownField() = %q, want none", name)
+	}
+	if name := unknownField(Mutation{Fields: Fields{"title": nil}, Base: Fields{"notes": nil}}, fields); name != "notes" {
+		t.Errorf("unknownField() = %q, want notes", name)
+	}
+	if name := unknownField(Mutation{Fields: Fields{FieldListId: nil}}, fields); name != FieldListId {
+		t.Errorf("unknownField() = %q, want %s outside of create", name, FieldListId)
+	}
+}
+
+func TestStaleFields(t *testing.T) {
+	current := todo.Todo{Title: "server", Tags: []string{}}
+	conflicts, err := staleFields(Fields{"title": json.RawMessage(`"old"`), "tags": json.RawMessage(`null`)}, todoPointers(&current))
+	if err != nil {
+		t.Fatalf("staleFields() error = %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Field != "title" || string(conflicts[0].Server) != `"server"` {
+		t.Errorf("staleFields() = %+v, want a conflict on title only", conflicts)
+	}
+}
+
+func TestValidTodo(t *testing.T) {
+	zero, thirty, urgent := 0, 30, "urgent"
+	tests := []struct {
+		name string
+		todo todo.Todo
+		want string
+	}{
+		{name: "valid", todo: todo.Todo{Title: "a", EstimatedMinutes: &thirty}, want: ""},
+		{name: "no title", todo: todo.Todo{}, want: "title is required"},
+		{name: "unknown priority", todo: todo.Todo{Title: "a", Priority: &urgent}, want: "invalid priority"},
+		{name: "zero estimate", todo: todo.Todo{Title: "a", EstimatedMinutes: &zero}, want: "invalid estimated_minutes"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := validTodo(&test.todo); got != test.want {
+				t.Errorf("validTodo = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
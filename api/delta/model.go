@@ -0,0 +1,87 @@
+package delta
+
+import (
+	"encoding/json"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+)
+
+const (
+	EntityTodo = "todo"
+	EntityList = "list"
+)
+
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+const (
+	StatusApplied  = "applied"
+	StatusConflict = "conflict"
+	StatusFailed   = "failed"
+)
+
+// Change is the latest change of an entity for a user, Seq being its
+// position in the user's change sequence.
+type Change struct {
+	Entity   string
+	EntityId string
+	Seq      int64
+	Deleted  bool
+}
+
+type Tombstone struct {
+	Entity string `json:"entity"`
+	Id     string `json:"id"`
+}
+
+// Delta is the answer to a sync: the current state of every entity changed
+// since the token of the request, and the token to send next time.
+type Delta struct {
+	Token      string       `json:"token"`
+	HasMore    bool         `json:"has_more"`
+	Todos      []todo.Todo  `json:"todos"`
+	Lists      []lists.List `json:"lists"`
+	Tombstones []Tombstone  `json:"tombstones"`
+}
+
+// Fields holds the fields of a mutation as JSON by name, see todoFields and
+// listFields. Only the fields that are present take part in the mutation, a
+// null clears the field.
+type Fields map[string]json.RawMessage
+
+// Mutation is a change made while offline. Base holds the values the client
+// saw before editing, it is what conflicts are detected against.
+type Mutation struct {
+	Op     string `json:"op"`
+	Entity string `json:"entity"`
+	Id     string `json:"id"`
+	Base   Fields `json:"base"`
+	Fields Fields `json:"fields"`
+}
+
+type PushDto struct {
+	Mutations []Mutation `json:"mutations" binding:"required"`
+}
+
+type FieldConflict struct {
+	Field  string          `json:"field"`
+	Base   json.RawMessage `json:"base"`
+	Client json.RawMessage `json:"client"`
+	Server json.RawMessage `json:"server"`
+}
+
+// Result reports the outcome of a mutation together with the server state of
+// the entity, which the client should keep.
+type Result struct {
+	Op        string          `json:"op"`
+	Entity    string          `json:"entity"`
+	Id        string          `json:"id"`
+	Status    string          `json:"status"`
+	Message   string          `json:"message,omitempty"`
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+	Todo      *todo.Todo      `json:"todo,omitempty"`
+	List      *lists.List     `json:"list,omitempty"`
+}
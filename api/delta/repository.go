@@ -0,0 +1,8 @@
+package delta
+
+import "context"
+
+type Repository interface {
+	GetChanges(ctx context.Context, userId string, workspaceId *string, since int64, limit int) ([]Change, error)
+	GetSequence(ctx context.Context, userId string) (int64, error)
+}
@@ -0,0 +1,303 @@
+package goals
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id     = "id"
+	LinkId = "link_id"
+)
+
+const (
+	MaxTitleLength       = 100
+	MaxDescriptionLength = 2000
+	MaxWeight            = 1000
+)
+
+var (
+	RelativeGoalUrl = "/goals"
+	TreeUrl         = "/goals/tree"
+	GetByIdUrl      = fmt.Sprintf("/goals/:%s", Id)
+	LinksUrl        = fmt.Sprintf("/goals/:%s/links", Id)
+	LinkUrl         = fmt.Sprintf("/goals/:%s/links/:%s", Id, LinkId)
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitGoalHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeGoalUrl, h.GetAll)
+		api.GET(TreeUrl, h.GetTree)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeGoalUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.POST(LinksUrl, h.AddLink)
+		api.PUT(LinkUrl, h.UpdateLink)
+		api.DELETE(LinkUrl, h.RemoveLink)
+	}
+}
+
+// GetAll returns the goals the user can see with their progress.
+func (h *Handler) GetAll(ctx *gin.Context) {
+	goals, _, err := h.tree(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get goals"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", goals))
+}
+
+// GetTree returns the top-level goals with their links and sub-goals, each
+// with its progress.
+func (h *Handler) GetTree(ctx *gin.Context) {
+	_, tree, err := h.tree(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get goals"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", tree))
+}
+
+// GetById returns the goal with its links and sub-goals.
+func (h *Handler) GetById(ctx *gin.Context) {
+	h.respond(ctx, http.StatusOK, ctx.Param(Id))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var goalDto GoalDto
+	if !h.bind(ctx, &goalDto) {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	goal := Goal{OwnerId: user.Id, WorkspaceId: user.WorkspaceId}
+	fill(&goal, goalDto)
+	if err := h.Storage.Create(ctx, &goal); err != nil {
+		h.abort(ctx, err, "failed to create goal")
+		return
+	}
+	h.respond(ctx, http.StatusCreated, goal.Id)
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var goalDto GoalDto
+	if !h.bind(ctx, &goalDto) {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	goal := Goal{Id: ctx.Param(Id), OwnerId: user.Id, WorkspaceId: user.WorkspaceId}
+	fill(&goal, goalDto)
+	if err := h.Storage.Update(ctx, &goal); err != nil {
+		h.abort(ctx, err, "failed to update goal")
+		return
+	}
+	h.respond(ctx, http.StatusOK, goal.Id)
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete goal")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// AddLink links a todo or a list the user can see to a goal they own, with a
+// weight of 1 by default.
+func (h *Handler) AddLink(ctx *gin.Context) {
+	var linkDto LinkDto
+	if err := ctx.ShouldBindJSON(&linkDto); err != nil {
+		h.Log.Errorf("failed to bind goal link. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if (linkDto.TodoId == nil) == (linkDto.ListId == nil) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "either todo_id or list_id is required"))
+		return
+	}
+	link := Link{TodoId: linkDto.TodoId, ListId: linkDto.ListId, Weight: 1}
+	if linkDto.Weight != nil {
+		link.Weight = *linkDto.Weight
+	}
+	if !validWeight(ctx, link.Weight) {
+		return
+	}
+
+	goal, ok := h.owned(ctx)
+	if !ok {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	var err error
+	if link.TodoId != nil {
+		_, _, err = h.todoStorage.GetTodoById(ctx, *link.TodoId, user.Id, user.WorkspaceId)
+	} else {
+		_, err = h.listStorage.GetRole(ctx, *link.ListId, user.Id, user.WorkspaceId)
+	}
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get linked item")
+		return
+	}
+
+	link.GoalId = goal.Id
+	if err = h.Storage.AddLink(ctx, &link); err != nil {
+		if errors.Is(err, apperror.ErrConflict) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "already linked to the goal"))
+			return
+		}
+		apperror.AbortWithError(ctx, err, "failed to link goal")
+		return
+	}
+	h.respond(ctx, http.StatusCreated, goal.Id)
+}
+
+func (h *Handler) UpdateLink(ctx *gin.Context) {
+	var weightDto WeightDto
+	if err := ctx.ShouldBindJSON(&weightDto); err != nil {
+		h.Log.Errorf("failed to bind goal link. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !validWeight(ctx, weightDto.Weight) {
+		return
+	}
+	goal, ok := h.owned(ctx)
+	if !ok {
+		return
+	}
+	link := Link{Id: ctx.Param(LinkId), GoalId: goal.Id, Weight: weightDto.Weight}
+	if err := h.Storage.UpdateLink(ctx, &link); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update goal link")
+		return
+	}
+	h.respond(ctx, http.StatusOK, goal.Id)
+}
+
+func (h *Handler) RemoveLink(ctx *gin.Context) {
+	goal, ok := h.owned(ctx)
+	if !ok {
+		return
+	}
+	if err := h.Storage.RemoveLink(ctx, ctx.Param(LinkId), goal.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to remove goal link")
+		return
+	}
+	h.respond(ctx, http.StatusOK, goal.Id)
+}
+
+// tree returns the goals visible to the user with their progress, and the
+// same goals as trees.
+func (h *Handler) tree(ctx *gin.Context) ([]Goal, []Node, error) {
+	user := users.GetCurrentUser(ctx)
+	goals, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		return nil, nil, err
+	}
+	links, err := h.Storage.GetLinks(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return goals, buildTree(goals, links), nil
+}
+
+// respond sends the node of the goal, whose progress depends on its whole
+// subtree.
+func (h *Handler) respond(ctx *gin.Context, status int, id string) {
+	_, tree, err := h.tree(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get goal"))
+		return
+	}
+	node, ok := findNode(tree, id)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, apperror.NewJsonMessage("fail", "goal not found"))
+		return
+	}
+	ctx.JSON(status, apperror.NewJsonMessage("success", node))
+}
+
+// owned returns the goal of the request when the user owns it. Goals the user
+// can see but does not own are 403, others 404.
+func (h *Handler) owned(ctx *gin.Context) (Goal, bool) {
+	user := users.GetCurrentUser(ctx)
+	goal, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get goal")
+		return Goal{}, false
+	}
+	if goal.OwnerId != user.Id {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "only the owner can change a goal"))
+		return Goal{}, false
+	}
+	return goal, true
+}
+
+func (h *Handler) abort(ctx *gin.Context, err error, message string) {
+	if errors.Is(err, apperror.ErrConflict) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "a goal cannot be its own ancestor"))
+		return
+	}
+	apperror.AbortWithError(ctx, err, message)
+}
+
+func (h *Handler) bind(ctx *gin.Context, goalDto *GoalDto) bool {
+	if err := ctx.ShouldBindJSON(goalDto); err != nil {
+		h.Log.Errorf("failed to bind goal. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return false
+	}
+	if len([]rune(goalDto.Title)) > MaxTitleLength || len([]rune(goalDto.Description)) > MaxDescriptionLength {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "title or description is too long"))
+		return false
+	}
+	if goalDto.TargetDate != nil {
+		if _, err := time.Parse(todo.DateLayout, *goalDto.TargetDate); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid target date"))
+			return false
+		}
+	}
+	if goalDto.Weight != nil && !validWeight(ctx, *goalDto.Weight) {
+		return false
+	}
+	return true
+}
+
+func fill(goal *Goal, goalDto GoalDto) {
+	goal.ParentId, goal.Title, goal.Description, goal.TargetDate = goalDto.ParentId, goalDto.Title, goalDto.Description, goalDto.TargetDate
+	goal.Weight = 1
+	if goalDto.Weight != nil {
+		goal.Weight = *goalDto.Weight
+	}
+}
+
+func validWeight(ctx *gin.Context, weight float64) bool {
+	if weight <= 0 || weight > MaxWeight {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", fmt.Sprintf("weight must be above 0 and at most %d", MaxWeight)))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,277 @@
+package goals
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate key, raised when a
+// todo or list is linked twice to a goal.
+const uniqueViolation = "23505"
+
+const goalColumns = `g.id, g.owner_id, g.workspace_id, g.parent_id, g.title, g.description,
+	to_char(g.target_date, 'YYYY-MM-DD'), g.weight, g.created_at, g.updated_at`
+
+// goalVisible tells whether the goal g is visible to the user $1 in the
+// workspace $2: workspace goals are shared with its members, personal ones
+// are private. Only owners change their goals.
+const goalVisible = `g.workspace_id IS NOT DISTINCT FROM $2 AND (g.workspace_id IS NOT NULL OR g.owner_id = $1)`
+
+var QueryGetAll = `SELECT ` + goalColumns + ` FROM goals g WHERE ` + goalVisible + ` ORDER BY g.target_date NULLS LAST, g.created_at`
+var QueryGetById = `SELECT ` + goalColumns + ` FROM goals g WHERE g.id = $3 AND ` + goalVisible
+var QueryLockGoals = `SELECT pg_advisory_xact_lock(hashtext('goals'))`
+var QueryParentVisible = `SELECT EXISTS (SELECT 1 FROM goals g WHERE g.id = $3 AND ` + goalVisible + `)`
+
+// QueryCreatesCycle tells whether making $2 the parent of $1 closes a cycle,
+// that is whether $1 is $2 or one of its ancestors.
+var QueryCreatesCycle = `WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM goals WHERE id = $2
+		UNION SELECT g.id, g.parent_id FROM goals g JOIN ancestors a ON g.id = a.parent_id)
+	SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $1)`
+var QueryCreate = `INSERT INTO goals (owner_id, workspace_id, parent_id, title, description, target_date, weight)
+	VALUES ($1, $2, $3, $4, $5, $6::date, $7) RETURNING id, created_at, updated_at`
+var QueryUpdate = `UPDATE goals g SET parent_id = $4, title = $5, description = $6, target_date = $7::date,
+	weight = $8, updated_at = now() WHERE g.id = $1 AND g.owner_id = $2 AND g.workspace_id IS NOT DISTINCT FROM $3
+	RETURNING ` + goalColumns
+var QueryDelete = `DELETE FROM goals WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+
+// QueryGetLinks returns the links of the visible goals. Todos count as done
+// or not, lists as the share of their completed todos, an empty list as not
+// started.
+var QueryGetLinks = `SELECT k.id, k.goal_id, k.todo_id, k.list_id, k.weight,
+		CASE WHEN k.todo_id IS NOT NULL THEN
+			(SELECT t.title FROM todo t WHERE t.id = k.todo_id AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL)
+		ELSE (SELECT l.title FROM lists l WHERE l.id = k.list_id AND list_role(l.id, $1) IS NOT NULL) END,
+		CASE WHEN k.todo_id IS NOT NULL THEN
+			(SELECT CASE WHEN t.completed_at IS NOT NULL THEN 1 ELSE 0 END FROM todo t WHERE t.id = k.todo_id)
+		ELSE (SELECT COALESCE(avg(CASE WHEN t.completed_at IS NOT NULL THEN 1 ELSE 0 END), 0) FROM todo t
+			WHERE t.list_id = k.list_id) END::float8
+	FROM goal_links k JOIN goals g ON g.id = k.goal_id WHERE ` + goalVisible + ` ORDER BY k.created_at`
+var QueryAddLink = `INSERT INTO goal_links (goal_id, todo_id, list_id, weight) VALUES ($1, $2, $3, $4) RETURNING id`
+var QueryUpdateLink = `UPDATE goal_links SET weight = $3 WHERE id = $1 AND goal_id = $2`
+var QueryRemoveLink = `DELETE FROM goal_links WHERE id = $1 AND goal_id = $2`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Goal, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query goals. due to error: %v", err)
+		return nil, err
+	}
+	goals := make([]Goal, 0)
+	for rows.Next() {
+		var goal Goal
+		if errScan := scanGoal(rows, &goal); errScan != nil {
+			s.log.Errorf("failed to scan goal. due to error: %v", errScan)
+			return nil, errScan
+		}
+		goals = append(goals, goal)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return goals, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (goal Goal, err error) {
+	if err = scanGoal(s.db.QueryRow(ctx, QueryGetById, userId, workspaceId, id), &goal); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Goal{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get goal by id=(%s), due to error: %v", id, err)
+		return Goal{}, err
+	}
+	return goal, nil
+}
+
+// Create saves the goal under its parent, which must be visible to the owner
+// or the goal is apperror.ErrNotFound.
+func (s *Storage) Create(ctx context.Context, goal *Goal) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = s.checkParent(ctx, tx, goal); err != nil {
+		return err
+	}
+	err = tx.QueryRow(ctx, QueryCreate, goal.OwnerId, goal.WorkspaceId, goal.ParentId, goal.Title, goal.Description,
+		goal.TargetDate, goal.Weight).Scan(&goal.Id, &goal.CreatedAt, &goal.UpdatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create goal. due to error: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Update changes the goal of its owner. A parent the owner cannot see is
+// apperror.ErrNotFound, one that would make the goal its own ancestor
+// apperror.ErrConflict.
+func (s *Storage) Update(ctx context.Context, goal *Goal) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = s.checkParent(ctx, tx, goal); err != nil {
+		return err
+	}
+	if goal.ParentId != nil {
+		var cycle bool
+		if err = tx.QueryRow(ctx, QueryCreatesCycle, goal.Id, *goal.ParentId).Scan(&cycle); err != nil {
+			s.TraceQueryError(err)
+			return err
+		}
+		if cycle {
+			return apperror.ErrConflict
+		}
+	}
+	row := tx.QueryRow(ctx, QueryUpdate, goal.Id, goal.OwnerId, goal.WorkspaceId, goal.ParentId, goal.Title,
+		goal.Description, goal.TargetDate, goal.Weight)
+	if err = scanGoal(row, goal); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update goal id=(%s). due to error: %v", goal.Id, err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// checkParent serializes the changes of the goal tree and checks that the
+// parent of the goal is visible to its owner.
+func (s *Storage) checkParent(ctx context.Context, tx pgx.Tx, goal *Goal) error {
+	if _, err := tx.Exec(ctx, QueryLockGoals); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if goal.ParentId == nil {
+		return nil
+	}
+	var visible bool
+	if err := tx.QueryRow(ctx, QueryParentVisible, goal.OwnerId, goal.WorkspaceId, *goal.ParentId).Scan(&visible); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if !visible {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes the goal of its owner, its sub-goals becoming top-level goals.
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete goal id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// GetLinks returns the links of the goals visible to the user with the
+// progress of their todo or list as a fraction.
+func (s *Storage) GetLinks(ctx context.Context, userId string, workspaceId *string) ([]Link, error) {
+	rows, err := s.db.Query(ctx, QueryGetLinks, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query goal links. due to error: %v", err)
+		return nil, err
+	}
+	links := make([]Link, 0)
+	for rows.Next() {
+		var link Link
+		errScan := rows.Scan(&link.Id, &link.GoalId, &link.TodoId, &link.ListId, &link.Weight, &link.Title, &link.Progress)
+		if errScan != nil {
+			s.log.Errorf("failed to scan goal link. due to error: %v", errScan)
+			return nil, errScan
+		}
+		links = append(links, link)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return links, nil
+}
+
+// AddLink links the todo or list to the goal, linking one twice is
+// apperror.ErrConflict. Permissions are checked by the caller.
+func (s *Storage) AddLink(ctx context.Context, link *Link) error {
+	err := s.db.QueryRow(ctx, QueryAddLink, link.GoalId, link.TodoId, link.ListId, link.Weight).Scan(&link.Id)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to link goal id=(%s). due to error: %v", link.GoalId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) UpdateLink(ctx context.Context, link *Link) error {
+	tag, err := s.db.Exec(ctx, QueryUpdateLink, link.Id, link.GoalId, link.Weight)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update goal link id=(%s). due to error: %v", link.Id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Storage) RemoveLink(ctx context.Context, id, goalId string) error {
+	tag, err := s.db.Exec(ctx, QueryRemoveLink, id, goalId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to remove goal link id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func scanGoal(row pgx.Row, goal *Goal) error {
+	return row.Scan(&goal.Id, &goal.OwnerId, &goal.WorkspaceId, &goal.ParentId, &goal.Title, &goal.Description,
+		&goal.TargetDate, &goal.Weight, &goal.CreatedAt, &goal.UpdatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,59 @@
+package goals
+
+import "time"
+
+// Goal is an objective of the workspace, shared with its members, or of the
+// user's personal space. Its progress rolls up the links and sub-goals.
+type Goal struct {
+	Id          string  `json:"id"`
+	OwnerId     string  `json:"owner_id"`
+	WorkspaceId *string `json:"workspace_id"`
+	ParentId    *string `json:"parent_id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	// TargetDate is a date as "2006-01-02".
+	TargetDate *string `json:"target_date"`
+	// Weight is the share of the goal in the progress of its parent.
+	Weight    float64   `json:"weight"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Progress is a percentage, computed on read.
+	Progress float64 `json:"progress"`
+}
+
+// Link ties a goal to a todo or a list. Title is only given for todos and
+// lists the user can see, all of them counting towards the progress.
+type Link struct {
+	Id       string  `json:"id"`
+	GoalId   string  `json:"goal_id"`
+	TodoId   *string `json:"todo_id"`
+	ListId   *string `json:"list_id"`
+	Weight   float64 `json:"weight"`
+	Title    *string `json:"title"`
+	Progress float64 `json:"progress"`
+}
+
+// Node is a goal of the tree with its links and sub-goals.
+type Node struct {
+	Goal
+	Links    []Link `json:"links"`
+	Children []Node `json:"children"`
+}
+
+type GoalDto struct {
+	Title       string   `json:"title" binding:"required"`
+	Description string   `json:"description"`
+	TargetDate  *string  `json:"target_date"`
+	ParentId    *string  `json:"parent_id"`
+	Weight      *float64 `json:"weight"`
+}
+
+type LinkDto struct {
+	TodoId *string  `json:"todo_id"`
+	ListId *string  `json:"list_id"`
+	Weight *float64 `json:"weight"`
+}
+
+type WeightDto struct {
+	Weight float64 `json:"weight" binding:"required"`
+}
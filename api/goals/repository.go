@@ -0,0 +1,15 @@
+package goals
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Goal, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Goal, error)
+	Create(ctx context.Context, goal *Goal) error
+	Update(ctx context.Context, goal *Goal) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	GetLinks(ctx context.Context, userId string, workspaceId *string) ([]Link, error)
+	AddLink(ctx context.Context, link *Link) error
+	UpdateLink(ctx context.Context, link *Link) error
+	RemoveLink(ctx context.Context, id, goalId string) error
+}
@@ -0,0 +1,78 @@
+package goals
+
+import "math"
+
+// buildTree arranges the goals into trees and computes their progress: the
+// weighted average of the progress of their links and sub-goals, a goal with
+// neither being at 0. Goals whose parent is not among them are roots. The
+// progress of the goals is filled in as well.
+func buildTree(goals []Goal, links []Link) []Node {
+	byGoal := make(map[string][]Link, len(goals))
+	for _, link := range links {
+		link.Progress = percentage(link.Progress)
+		byGoal[link.GoalId] = append(byGoal[link.GoalId], link)
+	}
+	children := make(map[string][]int, len(goals))
+	ids := make(map[string]bool, len(goals))
+	for _, goal := range goals {
+		ids[goal.Id] = true
+	}
+	roots := make([]int, 0)
+	for i, goal := range goals {
+		if goal.ParentId != nil && ids[*goal.ParentId] {
+			children[*goal.ParentId] = append(children[*goal.ParentId], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	var build func(i int) Node
+	build = func(i int) Node {
+		node := Node{Goal: goals[i], Links: byGoal[goals[i].Id], Children: make([]Node, 0)}
+		if node.Links == nil {
+			node.Links = make([]Link, 0)
+		}
+		var done, total float64
+		for _, link := range node.Links {
+			done += link.Weight * link.Progress
+			total += link.Weight
+		}
+		for _, child := range children[goals[i].Id] {
+			childNode := build(child)
+			node.Children = append(node.Children, childNode)
+			done += childNode.Weight * childNode.Progress
+			total += childNode.Weight
+		}
+		if total > 0 {
+			node.Progress = math.Round(done/total*10) / 10
+		}
+		goals[i].Progress = node.Progress
+		return node
+	}
+
+	// parents are checked against cycles on write, the goals reachable from
+	// the roots are all of them
+	tree := make([]Node, 0, len(roots))
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+	return tree
+}
+
+// findNode returns the node of the goal in the tree.
+func findNode(tree []Node, id string) (Node, bool) {
+	for _, node := range tree {
+		if node.Id == id {
+			return node, true
+		}
+		if found, ok := findNode(node.Children, id); ok {
+			return found, true
+		}
+	}
+	return Node{}, false
+}
+
+// percentage turns a fraction into a percentage with one decimal.
+func percentage(fraction float64) float64 {
+	return math.Round(fraction*1000) / 10
+}
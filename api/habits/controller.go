@@ -0,0 +1,263 @@
+package habits
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id      = "id"
+	Date    = "date"
+	HabitId = "habit_id"
+	From    = "from"
+	To      = "to"
+)
+
+const (
+	// Today can be given instead of a check-in date.
+	Today         = "today"
+	MaxNameLength = 100
+	// MaxBackfillDays bounds how far back a check-in can be recorded.
+	MaxBackfillDays = 366
+	// DefaultHeatmapDays is the range of the heatmap without from, MaxDays
+	// bounds it.
+	DefaultHeatmapDays = 365
+	MaxDays            = 366
+)
+
+var (
+	RelativeHabitUrl = "/habits"
+	HeatmapUrl       = "/habits/heatmap"
+	GetByIdUrl       = fmt.Sprintf("/habits/:%s", Id)
+	CheckInUrl       = fmt.Sprintf("/habits/:%s/checkins/:%s", Id, Date)
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitHabitHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeHabitUrl, h.GetAll)
+		api.GET(HeatmapUrl, h.GetHeatmap)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeHabitUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.PUT(CheckInUrl, h.CheckIn)
+		api.DELETE(CheckInUrl, h.Uncheck)
+	}
+}
+
+// GetAll returns the user's habits with their stats.
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	habits, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err == nil {
+		err = h.withStats(ctx, user, habits, nil)
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get habits"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", habits))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	habit, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get habit")
+		return
+	}
+	h.respond(ctx, user, http.StatusOK, habit)
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var habitDto HabitDto
+	if !h.bind(ctx, &habitDto) {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	habit := Habit{UserId: user.Id, WorkspaceId: user.WorkspaceId, Name: habitDto.Name,
+		Schedule: habitDto.Schedule, TimesPerWeek: habitDto.TimesPerWeek}
+	if err := h.Storage.Create(ctx, &habit); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create habit"))
+		return
+	}
+	h.respond(ctx, user, http.StatusCreated, habit)
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var habitDto HabitDto
+	if !h.bind(ctx, &habitDto) {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	habit := Habit{Id: ctx.Param(Id), UserId: user.Id, WorkspaceId: user.WorkspaceId, Name: habitDto.Name,
+		Schedule: habitDto.Schedule, TimesPerWeek: habitDto.TimesPerWeek}
+	if err := h.Storage.Update(ctx, &habit); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update habit")
+		return
+	}
+	h.respond(ctx, user, http.StatusOK, habit)
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete habit")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// CheckIn marks a date of the user's calendar as done, "today" standing for
+// the current one. Dates in the future or more than a year back are refused.
+func (h *Handler) CheckIn(ctx *gin.Context) {
+	h.check(ctx, func(id, date string) error { return h.Storage.CheckIn(ctx, id, date) })
+}
+
+func (h *Handler) Uncheck(ctx *gin.Context) {
+	h.check(ctx, func(id, date string) error { return h.Storage.Uncheck(ctx, id, date) })
+}
+
+// GetHeatmap counts the check-ins of each day from and to, dates of the user's
+// calendar defaulting to the last year, for all habits or the one of habit_id.
+func (h *Handler) GetHeatmap(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	to := todo.StartOfDay(time.Now().In(location))
+	if date, ok := ctx.GetQuery(To); ok {
+		parsed, err := todo.ParseLocalDate(date, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid to date"))
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, 1-DefaultHeatmapDays)
+	if date, ok := ctx.GetQuery(From); ok {
+		parsed, err := todo.ParseLocalDate(date, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid from date"))
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) || from.AddDate(0, 0, MaxDays).Before(to) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid date range"))
+		return
+	}
+
+	var habitId *string
+	if id, ok := ctx.GetQuery(HabitId); ok {
+		if _, err := h.Storage.GetById(ctx, id, user.Id, user.WorkspaceId); err != nil {
+			apperror.AbortWithError(ctx, err, "failed to get habit")
+			return
+		}
+		habitId = &id
+	}
+	days, err := h.Storage.Heatmap(ctx, user.Id, user.WorkspaceId, habitId, from.Format(todo.DateLayout), to.Format(todo.DateLayout))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get heatmap"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", days))
+}
+
+func (h *Handler) check(ctx *gin.Context, change func(id, date string) error) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	today := todo.StartOfDay(time.Now().In(location))
+	day := today
+	if date := ctx.Param(Date); date != Today {
+		parsed, err := todo.ParseLocalDate(date, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid date"))
+			return
+		}
+		day = parsed
+	}
+	if day.After(today) || day.Before(today.AddDate(0, 0, -MaxBackfillDays)) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "check-ins must be in the past year"))
+		return
+	}
+
+	habit, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get habit")
+		return
+	}
+	if err = change(habit.Id, day.Format(todo.DateLayout)); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to check in habit"))
+		return
+	}
+	h.respond(ctx, user, http.StatusOK, habit)
+}
+
+// respond sends the habit with its stats.
+func (h *Handler) respond(ctx *gin.Context, user users.User, status int, habit Habit) {
+	habits := []Habit{habit}
+	if err := h.withStats(ctx, user, habits, &habit.Id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get habit stats"))
+		return
+	}
+	ctx.JSON(status, apperror.NewJsonMessage("success", habits[0]))
+}
+
+// withStats fills in the stats of the habits, all of the user's or the one of
+// habitId.
+func (h *Handler) withStats(ctx *gin.Context, user users.User, habits []Habit, habitId *string) error {
+	checkIns, err := h.Storage.GetCheckIns(ctx, user.Id, user.WorkspaceId, habitId)
+	if err != nil {
+		return err
+	}
+	location := user.Location()
+	today := todo.LocalDate(time.Now(), location)
+	for i := range habits {
+		stats := computeStats(habits[i], checkIns[habits[i].Id], today, todo.LocalDate(habits[i].CreatedAt, location))
+		habits[i].Stats = &stats
+	}
+	return nil
+}
+
+func (h *Handler) bind(ctx *gin.Context, habitDto *HabitDto) bool {
+	if err := ctx.ShouldBindJSON(habitDto); err != nil {
+		h.Log.Errorf("failed to bind habit. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return false
+	}
+	if len([]rune(habitDto.Name)) > MaxNameLength {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "name is too long"))
+		return false
+	}
+	switch habitDto.Schedule {
+	case ScheduleDaily:
+		habitDto.TimesPerWeek = 7
+	case ScheduleWeekly:
+		if habitDto.TimesPerWeek < 1 || habitDto.TimesPerWeek > 7 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "times_per_week must be between 1 and 7"))
+			return false
+		}
+	default:
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "schedule must be daily or weekly"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package habits
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const habitColumns = `h.id, h.user_id, h.workspace_id, h.name, h.schedule, h.times_per_week, h.created_at`
+
+// Habits are private to their user and scoped to the workspace they were
+// created in.
+var QueryGetAll = `SELECT ` + habitColumns + ` FROM habits h
+	WHERE h.user_id = $1 AND h.workspace_id IS NOT DISTINCT FROM $2 ORDER BY h.created_at`
+var QueryGetById = `SELECT ` + habitColumns + ` FROM habits h
+	WHERE h.id = $1 AND h.user_id = $2 AND h.workspace_id IS NOT DISTINCT FROM $3`
+var QueryCreate = `INSERT INTO habits (user_id, workspace_id, name, schedule, times_per_week)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+var QueryUpdate = `UPDATE habits h SET name = $4, schedule = $5, times_per_week = $6
+	WHERE h.id = $1 AND h.user_id = $2 AND h.workspace_id IS NOT DISTINCT FROM $3
+	RETURNING ` + habitColumns
+var QueryDelete = `DELETE FROM habits WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryGetCheckIns = `SELECT c.habit_id, to_char(c.day, 'YYYY-MM-DD') FROM habit_checkins c JOIN habits h ON h.id = c.habit_id
+	WHERE h.user_id = $1 AND h.workspace_id IS NOT DISTINCT FROM $2 AND ($3::uuid IS NULL OR h.id = $3)
+	ORDER BY c.habit_id, c.day`
+var QueryCheckIn = `INSERT INTO habit_checkins (habit_id, day) VALUES ($1, $2::date) ON CONFLICT DO NOTHING`
+var QueryUncheck = `DELETE FROM habit_checkins WHERE habit_id = $1 AND day = $2::date`
+var QueryHeatmap = `SELECT to_char(d.day, 'YYYY-MM-DD'), count(c.habit_id)
+	FROM (SELECT g::date AS day FROM generate_series($4::date::timestamp, $5::date::timestamp, interval '1 day') g) d
+	LEFT JOIN habit_checkins c ON c.day = d.day AND c.habit_id IN (SELECT h.id FROM habits h
+		WHERE h.user_id = $1 AND h.workspace_id IS NOT DISTINCT FROM $2 AND ($3::uuid IS NULL OR h.id = $3))
+	GROUP BY d.day ORDER BY d.day`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Habit, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query habits. due to error: %v", err)
+		return nil, err
+	}
+	habits := make([]Habit, 0)
+	for rows.Next() {
+		var habit Habit
+		if errScan := scanHabit(rows, &habit); errScan != nil {
+			s.log.Errorf("failed to scan habit. due to error: %v", errScan)
+			return nil, errScan
+		}
+		habits = append(habits, habit)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return habits, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (habit Habit, err error) {
+	if err = scanHabit(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId), &habit); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Habit{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get habit by id=(%s), due to error: %v", id, err)
+		return Habit{}, err
+	}
+	return habit, nil
+}
+
+func (s *Storage) Create(ctx context.Context, habit *Habit) error {
+	err := s.db.QueryRow(ctx, QueryCreate, habit.UserId, habit.WorkspaceId, habit.Name, habit.Schedule, habit.TimesPerWeek).
+		Scan(&habit.Id, &habit.CreatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create habit. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Update(ctx context.Context, habit *Habit) error {
+	row := s.db.QueryRow(ctx, QueryUpdate, habit.Id, habit.UserId, habit.WorkspaceId, habit.Name, habit.Schedule, habit.TimesPerWeek)
+	if err := scanHabit(row, habit); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update habit id=(%s). due to error: %v", habit.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete habit id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// GetCheckIns returns the ordered check-in dates of the user's habits, or of
+// the given one, by habit id.
+func (s *Storage) GetCheckIns(ctx context.Context, userId string, workspaceId, habitId *string) (map[string][]string, error) {
+	rows, err := s.db.Query(ctx, QueryGetCheckIns, userId, workspaceId, habitId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query check-ins. due to error: %v", err)
+		return nil, err
+	}
+	checkIns := make(map[string][]string)
+	for rows.Next() {
+		var id, date string
+		if errScan := rows.Scan(&id, &date); errScan != nil {
+			s.log.Errorf("failed to scan check-in. due to error: %v", errScan)
+			return nil, errScan
+		}
+		checkIns[id] = append(checkIns[id], date)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return checkIns, nil
+}
+
+// CheckIn marks the date as done, checking in twice is a no-op. Ownership of
+// the habit is checked by the caller.
+func (s *Storage) CheckIn(ctx context.Context, id, date string) error {
+	if _, err := s.db.Exec(ctx, QueryCheckIn, id, date); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to check in habit id=(%s). due to error: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Uncheck(ctx context.Context, id, date string) error {
+	if _, err := s.db.Exec(ctx, QueryUncheck, id, date); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to uncheck habit id=(%s). due to error: %v", id, err)
+		return err
+	}
+	return nil
+}
+
+// Heatmap counts the check-ins of every day from and to, which are dates as
+// "2006-01-02", of the user's habits or of the given one.
+func (s *Storage) Heatmap(ctx context.Context, userId string, workspaceId, habitId *string, from, to string) ([]HeatmapDay, error) {
+	rows, err := s.db.Query(ctx, QueryHeatmap, userId, workspaceId, habitId, from, to)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query habit heatmap. due to error: %v", err)
+		return nil, err
+	}
+	days := make([]HeatmapDay, 0)
+	for rows.Next() {
+		var day HeatmapDay
+		if errScan := rows.Scan(&day.Date, &day.Count); errScan != nil {
+			s.log.Errorf("failed to scan heatmap day. due to error: %v", errScan)
+			return nil, errScan
+		}
+		days = append(days, day)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return days, nil
+}
+
+func scanHabit(row pgx.Row, habit *Habit) error {
+	return row.Scan(&habit.Id, &habit.UserId, &habit.WorkspaceId, &habit.Name, &habit.Schedule, &habit.TimesPerWeek, &habit.CreatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
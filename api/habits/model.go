@@ -0,0 +1,55 @@
+package habits
+
+import "time"
+
+// Schedules of a habit: daily habits are due every day, weekly ones on
+// TimesPerWeek days of each week, which starts on Monday.
+const (
+	ScheduleDaily  = "daily"
+	ScheduleWeekly = "weekly"
+)
+
+type Habit struct {
+	Id           string    `json:"id"`
+	UserId       string    `json:"user_id"`
+	WorkspaceId  *string   `json:"workspace_id"`
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	TimesPerWeek int       `json:"times_per_week"`
+	CreatedAt    time.Time `json:"created_at"`
+	// Stats is filled in by the listings.
+	Stats *Stats `json:"stats,omitempty"`
+}
+
+type HabitDto struct {
+	Name     string `json:"name" binding:"required"`
+	Schedule string `json:"schedule" binding:"required"`
+	// TimesPerWeek is required for weekly habits.
+	TimesPerWeek int `json:"times_per_week"`
+}
+
+// Stats are computed over the check-ins of a habit in the user's calendar.
+// Streaks count days for daily habits and weeks meeting their target for
+// weekly ones, the current period extending a streak without breaking it.
+type Stats struct {
+	CurrentStreak int    `json:"current_streak"`
+	LongestStreak int    `json:"longest_streak"`
+	StreakUnit    string `json:"streak_unit"`
+	// CompletionRate is the share of the due check-ins done in the last
+	// RateDays days, or since the habit started when it is younger.
+	CompletionRate float64 `json:"completion_rate"`
+	RateDays       int     `json:"rate_days"`
+	CheckedToday   bool    `json:"checked_today"`
+	TotalCheckIns  int     `json:"total_check_ins"`
+}
+
+type CheckIn struct {
+	HabitId string `json:"habit_id"`
+	Date    string `json:"date"`
+}
+
+// HeatmapDay is the number of check-ins of a day of the user's calendar.
+type HeatmapDay struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,15 @@
+package habits
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Habit, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Habit, error)
+	Create(ctx context.Context, habit *Habit) error
+	Update(ctx context.Context, habit *Habit) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	GetCheckIns(ctx context.Context, userId string, workspaceId, habitId *string) (map[string][]string, error)
+	CheckIn(ctx context.Context, id, date string) error
+	Uncheck(ctx context.Context, id, date string) error
+	Heatmap(ctx context.Context, userId string, workspaceId, habitId *string, from, to string) ([]HeatmapDay, error)
+}
@@ -0,0 +1,108 @@
+package habits
+
+import (
+	"math"
+	"sort"
+	"time"
+	"todoproject/api/todo"
+)
+
+// RateDays is the window of the completion rate.
+const RateDays = 30
+
+// dayNumber numbers the dates as "2006-01-02" from 1970-01-01. Counting whole
+// days on dates keeps the arithmetic clear of time zones and DST.
+func dayNumber(date string) (int, error) {
+	t, err := time.Parse(todo.DateLayout, date)
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Unix() / 86400), nil
+}
+
+// weekNumber numbers the weeks starting on Monday, 1970-01-01 being a Thursday.
+func weekNumber(day int) int {
+	return int(math.Floor(float64(day+3) / 7))
+}
+
+// computeStats computes the stats of a habit from its ordered check-in dates.
+// Today and start, the day the habit was created, are dates of the user's
+// calendar.
+func computeStats(habit Habit, checkIns []string, today, start string) Stats {
+	stats := Stats{StreakUnit: "day", TotalCheckIns: len(checkIns)}
+	todayNumber, _ := dayNumber(today)
+	first, _ := dayNumber(start)
+
+	checked := make(map[int]bool, len(checkIns))
+	for _, date := range checkIns {
+		day, err := dayNumber(date)
+		if err != nil || day > todayNumber {
+			continue
+		}
+		checked[day] = true
+		if day < first {
+			first = day
+		}
+	}
+	stats.CheckedToday = checked[todayNumber]
+
+	// the periods of the streaks are days or weeks, met when they have enough
+	// check-ins
+	period, current, target := func(day int) int { return day }, todayNumber, 1
+	if habit.Schedule == ScheduleWeekly {
+		stats.StreakUnit = "week"
+		period, current, target = weekNumber, weekNumber(todayNumber), habit.TimesPerWeek
+	}
+	counts := make(map[int]int)
+	for day := range checked {
+		counts[period(day)]++
+	}
+	met := make([]int, 0, len(counts))
+	for p, count := range counts {
+		if count >= target {
+			met = append(met, p)
+		}
+	}
+	sort.Ints(met)
+
+	run := 0
+	for i, p := range met {
+		if i > 0 && met[i-1] == p-1 {
+			run++
+		} else {
+			run = 1
+		}
+		if run > stats.LongestStreak {
+			stats.LongestStreak = run
+		}
+		// the current period is not over, the streak may still go through it
+		if p == current || p == current-1 {
+			stats.CurrentStreak = run
+		}
+	}
+
+	from := todayNumber - RateDays + 1
+	if first > from {
+		from = first
+	}
+	stats.RateDays = todayNumber - from + 1
+	done := make(map[int]int)
+	for day := range checked {
+		if day >= from {
+			done[period(day)]++
+		}
+	}
+	achieved := 0
+	for _, count := range done {
+		if count > target {
+			count = target
+		}
+		achieved += count
+	}
+	expected := float64(stats.RateDays)
+	if habit.Schedule == ScheduleWeekly {
+		expected = float64(habit.TimesPerWeek*stats.RateDays) / 7
+	}
+	stats.CompletionRate = math.Min(1, math.Round(float64(achieved)/expected*1000)/1000)
+	return stats
+}
@@ -0,0 +1,272 @@
+package inbound
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id    = "id"
+	Token = "token"
+)
+
+const (
+	// RateLimit requests are accepted per token and RateWindow.
+	RateLimit  = 60
+	RateWindow = time.Minute
+
+	maxPayloadSize = 64 << 10
+	maxTitleLength = 100
+	tokenBytes     = 24
+)
+
+var (
+	RelativeHookUrl = "/hooks"
+	GetByIdUrl      = fmt.Sprintf("/hooks/:%s", Id)
+	ReceiveUrl      = fmt.Sprintf("/hooks/in/:%s", Token)
+)
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+type Handler struct {
+	Storage     *Storage
+	todoStorage *todo.Storage
+	listStorage *lists.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, todoStorage *todo.Storage, listStorage *lists.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, todoStorage: todoStorage, listStorage: listStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitInboundHandler(e *gin.Engine) {
+	// authenticated by the token in the url alone
+	e.POST(ReceiveUrl, h.Receive)
+
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeHookUrl, h.GetAll)
+		api.POST(RelativeHookUrl, h.Create)
+		api.GET(GetByIdUrl, h.GetById)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Revoke)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	hooks, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get hooks"))
+		return
+	}
+	for i := range hooks {
+		hooks[i].Url = HookLink(hooks[i].Token)
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", hooks))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	hook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get hook")
+		return
+	}
+	hook.Url = HookLink(hook.Token)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", hook))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var hookDto CreateHookDto
+	if err := ctx.ShouldBindJSON(&hookDto); err != nil {
+		h.Log.Errorf("failed to bind hook. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	if !h.canWriteTo(ctx, hookDto.ListId, user) {
+		return
+	}
+
+	token, err := util.GenerateRandomToken(tokenBytes)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, apperror.NewJsonMessage("fail", "failed to create hook"))
+		return
+	}
+	hook := Hook{
+		Token: token, UserId: user.Id, WorkspaceId: user.WorkspaceId, ListId: hookDto.ListId,
+		Name: hookDto.Name, Template: hookDto.Template,
+	}
+	if err = h.Storage.Create(ctx, &hook); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create hook"))
+		return
+	}
+	hook.Url = HookLink(hook.Token)
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", hook))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var hookDto UpdateHookDto
+	if err := ctx.ShouldBindJSON(&hookDto); err != nil {
+		h.Log.Errorf("failed to bind hook. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	hook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get hook")
+		return
+	}
+	if !h.canWriteTo(ctx, hookDto.ListId, user) {
+		return
+	}
+
+	hook.Name, hook.ListId, hook.Template = hookDto.Name, hookDto.ListId, hookDto.Template
+	if err = h.Storage.Update(ctx, &hook); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update hook")
+		return
+	}
+	hook.Url = HookLink(hook.Token)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", hook))
+}
+
+// Revoke disables the token for good, the hook stays listed as revoked.
+func (h *Handler) Revoke(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Revoke(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to revoke hook")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "revoked"))
+}
+
+// Receive creates a todo from a JSON or form-encoded payload posted to the
+// url of a hook. Slack slash commands get a Slack message back.
+func (h *Handler) Receive(ctx *gin.Context) {
+	hook, allowed, err := h.Storage.Use(ctx, ctx.Param(Token), RateLimit, RateWindow)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to receive payload")
+		return
+	}
+	if !allowed {
+		ctx.Header("Retry-After", fmt.Sprintf("%.0f", RateWindow.Seconds()))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, apperror.NewJsonMessage("fail", "rate limit exceeded"))
+		return
+	}
+
+	payload, slack, err := readPayload(ctx)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	title, tags, due, err := hook.Template.Render(payload, hook.Location())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if title == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "payload has no title"))
+		return
+	}
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		title = string([]rune(title)[:maxTitleLength])
+	}
+
+	created := todo.Todo{
+		Title: title, UserId: hook.UserId, ListId: hook.ListId, WorkspaceId: hook.WorkspaceId, DueAt: due, Tags: tags,
+	}
+	if err = h.todoStorage.Create(ctx, &created); err != nil {
+		h.Log.Errorf("failed to create todo from hook id=(%s). due to error: %v", hook.Id, err)
+		message := "failed to create todo"
+		if errors.Is(err, apperror.ErrNotFound) {
+			message = "the hook owner can no longer add todos to its list"
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	if slack {
+		ctx.JSON(http.StatusOK, SlackResponse{ResponseType: "ephemeral", Text: "Added todo: " + slackEscaper.Replace(created.Title)})
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", created))
+}
+
+// canWriteTo checks that the user may add todos to the target list of a hook,
+// no list meaning the user's personal todos.
+func (h *Handler) canWriteTo(ctx *gin.Context, listId *string, user users.User) bool {
+	if listId == nil {
+		return true
+	}
+	role, err := h.listStorage.GetRole(ctx, *listId, user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return false
+	}
+	if !role.CanEdit() {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return false
+	}
+	return true
+}
+
+// readPayload decodes a JSON object or a form into a map. Forms are flat,
+// fields repeated in the form become arrays. A form carrying command and
+// response_url is a Slack slash command.
+func readPayload(ctx *gin.Context) (payload map[string]interface{}, slack bool, err error) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxPayloadSize)
+
+	switch ctx.ContentType() {
+	case binding.MIMEJSON:
+		if err = json.NewDecoder(ctx.Request.Body).Decode(&payload); err != nil {
+			return nil, false, errors.New("payload must be a JSON object")
+		}
+		return payload, false, nil
+	case binding.MIMEPOSTForm:
+		err = ctx.Request.ParseForm()
+	case binding.MIMEMultipartPOSTForm:
+		err = ctx.Request.ParseMultipartForm(maxPayloadSize)
+	default:
+		return nil, false, errors.New("payload must be JSON or form-encoded")
+	}
+	if err != nil {
+		return nil, false, errors.New("invalid form payload")
+	}
+
+	payload = make(map[string]interface{}, len(ctx.Request.PostForm))
+	for key, values := range ctx.Request.PostForm {
+		if len(values) == 1 {
+			payload[key] = values[0]
+			continue
+		}
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			list = append(list, value)
+		}
+		payload[key] = list
+	}
+	_, hasCommand := payload["command"]
+	_, hasResponseUrl := payload["response_url"]
+	return payload, hasCommand && hasResponseUrl, nil
+}
+
+func HookLink(token string) string {
+	return fmt.Sprintf("https://%s/hooks/in/%s", util.DOMAIN, token)
+}
@@ -0,0 +1,151 @@
+package inbound
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"time"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const hookColumns = `h.id, h.token, h.user_id, h.workspace_id, h.list_id, h.name, h.template,
+	h.last_used_at, h.revoked_at, h.created_at`
+
+var QueryGetAll = `SELECT ` + hookColumns + ` FROM inbound_hooks h
+	WHERE h.user_id = $1 AND h.workspace_id IS NOT DISTINCT FROM $2 ORDER BY h.created_at`
+var QueryGetById = `SELECT ` + hookColumns + ` FROM inbound_hooks h
+	WHERE h.id = $1 AND h.user_id = $2 AND h.workspace_id IS NOT DISTINCT FROM $3`
+var QueryCreate = `INSERT INTO inbound_hooks (token, user_id, workspace_id, list_id, name, template)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+var QueryUpdate = `UPDATE inbound_hooks h SET name = $2, list_id = $3, template = $4
+	WHERE h.id = $1 AND h.revoked_at IS NULL RETURNING ` + hookColumns
+var QueryRevoke = `UPDATE inbound_hooks SET revoked_at = now()
+	WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3 AND revoked_at IS NULL`
+
+// QueryUse counts a request against the fixed rate limit window of the token,
+// starting a new window once the current one is over. Doing it in Postgres
+// keeps the limit shared by every instance.
+var QueryUse = `UPDATE inbound_hooks h SET
+	window_count = CASE WHEN h.window_started_at <= now() - make_interval(secs => $3) THEN 1 ELSE h.window_count + 1 END,
+	window_started_at = CASE WHEN h.window_started_at <= now() - make_interval(secs => $3) THEN now() ELSE h.window_started_at END,
+	last_used_at = now()
+	WHERE h.token = $1 AND h.revoked_at IS NULL
+	RETURNING ` + hookColumns + `, h.window_count <= $2,
+	(SELECT u.timezone FROM users u WHERE u.id = h.user_id)`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Hook, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query inbound hooks. due to error: %v", err)
+		return nil, err
+	}
+	hooks := make([]Hook, 0)
+	for rows.Next() {
+		var hook Hook
+		if errScan := rows.Scan(hookFields(&hook)...); errScan != nil {
+			s.log.Errorf("failed to scan inbound hook. due to error: %v", errScan)
+			return nil, errScan
+		}
+		hooks = append(hooks, hook)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return hooks, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (hook Hook, err error) {
+	if err = s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId).Scan(hookFields(&hook)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Hook{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get inbound hook by id=(%s), due to error: %v", id, err)
+		return Hook{}, err
+	}
+	return hook, nil
+}
+
+func (s *Storage) Create(ctx context.Context, hook *Hook) error {
+	err := s.db.QueryRow(ctx, QueryCreate, hook.Token, hook.UserId, hook.WorkspaceId, hook.ListId, hook.Name, hook.Template).
+		Scan(&hook.Id, &hook.CreatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create inbound hook. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Update changes the target list and template of a hook. Revoked hooks are
+// reported as apperror.ErrNotFound.
+func (s *Storage) Update(ctx context.Context, hook *Hook) error {
+	err := s.db.QueryRow(ctx, QueryUpdate, hook.Id, hook.Name, hook.ListId, hook.Template).Scan(hookFields(hook)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update inbound hook id=(%s). due to error: %v", hook.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Revoke(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryRevoke, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to revoke inbound hook id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// Use resolves a token and counts the request against its rate limit. It
+// reports false once more than limit requests were made in the window.
+// Unknown and revoked tokens are apperror.ErrNotFound. The hook comes with the
+// time zone of its owner.
+func (s *Storage) Use(ctx context.Context, token string, limit int, window time.Duration) (hook Hook, allowed bool, err error) {
+	err = s.db.QueryRow(ctx, QueryUse, token, limit, window.Seconds()).Scan(append(hookFields(&hook), &allowed, &hook.Timezone)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Hook{}, false, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to use inbound hook. due to error: %v", err)
+		return Hook{}, false, err
+	}
+	return hook, allowed, nil
+}
+
+func hookFields(h *Hook) []interface{} {
+	return []interface{}{&h.Id, &h.Token, &h.UserId, &h.WorkspaceId, &h.ListId, &h.Name, &h.Template,
+		&h.LastUsedAt, &h.RevokedAt, &h.CreatedAt}
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,57 @@
+package inbound
+
+import (
+	"time"
+	"todoproject/api/users"
+)
+
+// Template maps the fields of an incoming payload to the todo it creates.
+// Values may contain placeholders such as {{text}} or {{issue.title}}, a
+// dotted path into the payload. A tag made of a single placeholder that
+// resolves to an array adds every element. Due accepts RFC 3339 timestamps
+// and YYYY-MM-DD dates, midnight in the time zone of the hook owner.
+type Template struct {
+	Title string   `json:"title"`
+	Tags  []string `json:"tags"`
+	Due   string   `json:"due"`
+}
+
+type Hook struct {
+	Id          string     `json:"id"`
+	Token       string     `json:"token"`
+	Url         string     `json:"url"`
+	UserId      string     `json:"user_id"`
+	WorkspaceId *string    `json:"workspace_id"`
+	ListId      *string    `json:"list_id"`
+	Name        string     `json:"name"`
+	Template    Template   `json:"template"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// Timezone is the one of the owner, only filled in by Storage.Use.
+	Timezone string `json:"-"`
+}
+
+// Location is the time zone the dates of the hook's payloads are read in.
+func (h Hook) Location() *time.Location {
+	return users.User{Timezone: h.Timezone}.Location()
+}
+
+type CreateHookDto struct {
+	Name     string   `json:"name" binding:"required"`
+	ListId   *string  `json:"list_id"`
+	Template Template `json:"template"`
+}
+
+type UpdateHookDto struct {
+	Name     string   `json:"name" binding:"required"`
+	ListId   *string  `json:"list_id"`
+	Template Template `json:"template"`
+}
+
+// SlackResponse answers a Slack slash command with a message only the
+// invoking user sees.
+type SlackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
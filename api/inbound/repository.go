@@ -0,0 +1,15 @@
+package inbound
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Hook, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Hook, error)
+	Create(ctx context.Context, hook *Hook) error
+	Update(ctx context.Context, hook *Hook) error
+	Revoke(ctx context.Context, id, userId string, workspaceId *string) error
+	Use(ctx context.Context, token string, limit int, window time.Duration) (Hook, bool, error)
+}
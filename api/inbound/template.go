@@ -0,0 +1,108 @@
+package inbound
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxTagLength = 50
+
+var placeholder = regexp.MustCompile(`\{\{\s*([\w.\-]+)\s*\}\}`)
+
+// defaultTitles are tried in order when the template has no title, they cover
+// plain JSON payloads and Slack slash commands.
+var defaultTitles = []string{"{{title}}", "{{text}}"}
+
+// Render builds the title, tags and due date of a todo from a payload, dates
+// without a time being read in location.
+func (t Template) Render(payload map[string]interface{}, location *time.Location) (title string, tags []string, due *time.Time, err error) {
+	if t.Title != "" {
+		title = strings.TrimSpace(expand(t.Title, payload))
+	} else {
+		for _, candidate := range defaultTitles {
+			if title = strings.TrimSpace(expand(candidate, payload)); title != "" {
+				break
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, tagTemplate := range t.Tags {
+		values := []string{expand(tagTemplate, payload)}
+		if match := placeholder.FindStringSubmatch(tagTemplate); match != nil && match[0] == strings.TrimSpace(tagTemplate) {
+			if list, ok := lookup(payload, match[1]).([]interface{}); ok {
+				values = values[:0]
+				for _, item := range list {
+					values = append(values, format(item))
+				}
+			}
+		}
+		for _, tag := range values {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || len(tag) > maxTagLength || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	if t.Due != "" {
+		if value := strings.TrimSpace(expand(t.Due, payload)); value != "" {
+			parsed, errParse := parseDue(value, location)
+			if errParse != nil {
+				return "", nil, nil, errParse
+			}
+			due = &parsed
+		}
+	}
+	return title, tags, due, nil
+}
+
+func expand(template string, payload map[string]interface{}) string {
+	return placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		return format(lookup(payload, placeholder.FindStringSubmatch(match)[1]))
+	})
+}
+
+// lookup follows a dotted path through nested JSON objects.
+func lookup(payload map[string]interface{}, path string) interface{} {
+	var value interface{} = payload
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, format(item))
+		}
+		return strings.Join(parts, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseDue(value string, location *time.Location) (time.Time, error) {
+	if due, err := time.Parse(time.RFC3339, value); err == nil {
+		return due, nil
+	}
+	if due, err := time.ParseInLocation("2006-01-02", value, location); err == nil {
+		return due, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid due date %q", value)
+}
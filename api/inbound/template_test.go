@@ -0,0 +1,38 @@
+package inbound
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDue(t *testing.T) {
+	location := time.FixedZone("UTC-5", -5*60*60)
+	tests := []struct {
+		value string
+		want  time.Time
+		fails bool
+	}{
+		{value: "2025-06-11", want: time.Date(2025, time.June, 11, 0, 0, 0, 0, location)},
+		{value: "2025-06-11T09:30:00Z", want: time.Date(2025, time.June, 11, 9, 30, 0, 0, time.UTC)},
+		{value: "2025-06-11T09:30:00+02:00", want: time.Date(2025, time.June, 11, 7, 30, 0, 0, time.UTC)},
+		{value: "2025-02-30", fails: true},
+		{value: "tomorrow", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, err := parseDue(test.value, location)
+			if test.fails {
+				if err == nil {
+					t.Errorf("parseDue = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseDue failed: %v", err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("parseDue = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
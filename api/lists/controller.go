@@ -0,0 +1,282 @@
+package lists
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/api/workspaces"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id     = "id"
+	UserId = "user_id"
+)
+
+var (
+	RelativeListUrl = "/lists"
+	InvitesUrl      = "/lists/invites"
+	GetByIdUrl      = fmt.Sprintf("/lists/:%s", Id)
+	AcceptUrl       = fmt.Sprintf("/lists/:%s/accept", Id)
+	MembersUrl      = fmt.Sprintf("/lists/:%s/members", Id)
+	MemberUrl       = fmt.Sprintf("/lists/:%s/members/:%s", Id, UserId)
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitListHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeListUrl, h.GetAll)
+		api.GET(InvitesUrl, h.GetInvites)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeListUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.POST(AcceptUrl, h.Accept)
+		api.GET(MembersUrl, h.GetMembers)
+		api.POST(MembersUrl, h.Invite)
+		api.PUT(MemberUrl, h.ChangeRole)
+		api.DELETE(MemberUrl, h.Revoke)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	lists, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		h.Log.Errorf("failed to get lists. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", lists))
+}
+
+func (h *Handler) GetInvites(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	invites, err := h.Storage.GetInvites(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		h.Log.Errorf("failed to get list invites. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", invites))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	list, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", list))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var listDto CreateListDto
+	if err := ctx.ShouldBindJSON(&listDto); err != nil {
+		h.Log.Errorf("failed to bind list. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	if user.WorkspaceRole == string(workspaces.RoleGuest) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "guests cannot create lists"))
+		return
+	}
+	if !h.validVisibility(ctx, listDto.Visibility, user) {
+		return
+	}
+	list := List{Title: listDto.Title, OwnerId: user.Id, WorkspaceId: user.WorkspaceId, Visibility: listDto.Visibility}
+
+	if err := h.Storage.Create(ctx, &list); err != nil {
+		h.Log.Errorf("failed to create list. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create list"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", list))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var listDto UpdateListDto
+	if err := ctx.ShouldBindJSON(&listDto); err != nil {
+		h.Log.Errorf("failed to bind list. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	if !h.validVisibility(ctx, listDto.Visibility, user) {
+		return
+	}
+
+	id := ctx.Param(Id)
+	role, ok := h.Authorize(ctx, id, Role.CanManage)
+	if !ok {
+		return
+	}
+
+	list := List{Id: id, Title: listDto.Title, Visibility: listDto.Visibility, Role: role}
+	if err := h.Storage.Update(ctx, &list, user.Id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to update list"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", list))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.Authorize(ctx, id, func(role Role) bool { return role == RoleOwner }); !ok {
+		return
+	}
+
+	if err := h.Storage.Delete(ctx, id, users.GetCurrentUser(ctx).Id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to delete list"))
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+func (h *Handler) GetMembers(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.Authorize(ctx, id, func(Role) bool { return true }); !ok {
+		return
+	}
+
+	members, err := h.Storage.GetMembers(ctx, id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get members"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", members))
+}
+
+func (h *Handler) Invite(ctx *gin.Context) {
+	var inviteDto InviteMemberDto
+	if err := ctx.ShouldBindJSON(&inviteDto); err != nil {
+		h.Log.Errorf("failed to bind invite. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !inviteDto.Role.IsAssignable() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "role must be one of viewer, editor or admin"))
+		return
+	}
+
+	id := ctx.Param(Id)
+	if _, ok := h.Authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	member := Member{ListId: id, UserId: inviteDto.UserId, Role: inviteDto.Role}
+	if err := h.Storage.Invite(ctx, member, user.Id, user.WorkspaceId); err != nil {
+		message := "failed to invite user"
+		if errors.Is(err, apperror.ErrNotFound) {
+			message = "user is not a member of the workspace"
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", member))
+}
+
+func (h *Handler) Accept(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Accept(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to accept invite")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", "accepted"))
+}
+
+func (h *Handler) ChangeRole(ctx *gin.Context) {
+	var roleDto ChangeRoleDto
+	if err := ctx.ShouldBindJSON(&roleDto); err != nil {
+		h.Log.Errorf("failed to bind role. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !roleDto.Role.IsAssignable() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "role must be one of viewer, editor or admin"))
+		return
+	}
+
+	id := ctx.Param(Id)
+	if _, ok := h.Authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	if err := h.Storage.ChangeRole(ctx, id, ctx.Param(UserId), roleDto.Role); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to change role")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", roleDto.Role))
+}
+
+// Revoke removes a member from the list. Admins can revoke anyone but the owner,
+// every other member can only revoke themselves, which is also how an invite is declined.
+func (h *Handler) Revoke(ctx *gin.Context) {
+	id, userId := ctx.Param(Id), ctx.Param(UserId)
+
+	user := users.GetCurrentUser(ctx)
+	if user.Id != userId {
+		if _, ok := h.Authorize(ctx, id, Role.CanManage); !ok {
+			return
+		}
+	}
+
+	if err := h.Storage.Revoke(ctx, id, userId, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to revoke member")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "revoked"))
+}
+
+// Authorize checks the current user's role on the list. Users without access
+// get 404 instead of 403 so that the existence of the list is not leaked.
+func (h *Handler) Authorize(ctx *gin.Context, id string, allowed func(Role) bool) (Role, bool) {
+	user := users.GetCurrentUser(ctx)
+	role, err := h.Storage.GetRole(ctx, id, user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return "", false
+	}
+	if !allowed(role) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return role, false
+	}
+	return role, true
+}
+
+func (h *Handler) validVisibility(ctx *gin.Context, visibility string, user users.User) bool {
+	if !ValidVisibility(visibility, user.WorkspaceId) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid visibility"))
+		return false
+	}
+	return true
+}
+
+// ValidVisibility accepts an empty visibility, which falls back to the
+// workspace default, and only allows sharing with a workspace inside one.
+func ValidVisibility(visibility string, workspaceId *string) bool {
+	if visibility == "" {
+		return true
+	}
+	return workspaces.IsVisibility(visibility) && (visibility != workspaces.VisibilityWorkspace || workspaceId != nil)
+}
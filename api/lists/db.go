@@ -0,0 +1,395 @@
+package lists
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/api/activity"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate key, raised when a
+// client retries the create of a list whose id it generated itself.
+const uniqueViolation = "23505"
+
+const listColumns = `l.id, l.title, l.owner_id, l.workspace_id, l.visibility`
+
+// Access to a list is resolved by the list_role() SQL function, see
+// pgconsole/create_role_functions.sql. Every query is scoped to the
+// active workspace, a null workspace being the user's personal space.
+var QueryGetAll = `SELECT ` + listColumns + `, r.role FROM lists l
+	CROSS JOIN LATERAL (SELECT list_role(l.id, $1) AS role) r
+	WHERE l.workspace_id IS NOT DISTINCT FROM $2 AND r.role IS NOT NULL`
+var QueryGetById = `SELECT ` + listColumns + `, r.role FROM lists l
+	CROSS JOIN LATERAL (SELECT list_role(l.id, $2) AS role) r
+	WHERE l.id = $1 AND l.workspace_id IS NOT DISTINCT FROM $3 AND r.role IS NOT NULL`
+var QueryGetRole = `SELECT r.role FROM lists l
+	CROSS JOIN LATERAL (SELECT list_role(l.id, $2) AS role) r
+	WHERE l.id = $1 AND l.workspace_id IS NOT DISTINCT FROM $3 AND r.role IS NOT NULL`
+var QueryGetByIds = `SELECT ` + listColumns + `, r.role FROM lists l
+	CROSS JOIN LATERAL (SELECT list_role(l.id, $2) AS role) r
+	WHERE l.id = ANY($1) AND r.role IS NOT NULL`
+var QueryGetCounts = `SELECT t.list_id, count(*) FILTER (WHERE t.completed_at IS NULL),
+	count(*) FILTER (WHERE t.completed_at IS NULL AND t.due_at < now())
+	FROM todo t WHERE t.list_id = ANY($1) GROUP BY t.list_id`
+var QueryGetInvites = `SELECT ` + listColumns + `, m.role FROM lists l
+	JOIN list_members m ON m.list_id = l.id
+	WHERE m.user_id = $1 AND NOT m.accepted AND l.workspace_id IS NOT DISTINCT FROM $2`
+var QueryCreate = `INSERT INTO lists (id, title, owner_id, workspace_id, visibility)
+	VALUES (COALESCE($5::uuid, gen_random_uuid()), $1, $2, $3,
+	COALESCE(NULLIF($4, ''), (SELECT default_visibility FROM workspaces WHERE id = $3), 'private'))
+	RETURNING id, visibility`
+var QueryCreateOwner = `INSERT INTO list_members (list_id, user_id, role, accepted) VALUES ($1, $2, 'owner', true)`
+var QueryUpdate = `UPDATE lists SET title = $1, visibility = COALESCE(NULLIF($2, ''), visibility) WHERE id = $3
+	RETURNING owner_id, workspace_id, visibility`
+var QueryLock = `SELECT ` + listColumns + ` FROM lists l WHERE l.id = $1 FOR UPDATE`
+var QueryDelete = `DELETE FROM lists WHERE id = $1`
+var QueryGetMembers = `SELECT list_id, user_id, role, accepted FROM list_members WHERE list_id = $1`
+var QueryInvite = `INSERT INTO list_members (list_id, user_id, role) SELECT l.id, $2, $3 FROM lists l
+	WHERE l.id = $1 AND (l.workspace_id IS NULL OR EXISTS (SELECT 1 FROM workspace_members w
+		WHERE w.workspace_id = l.workspace_id AND w.user_id = $2))`
+var QueryAccept = `UPDATE list_members m SET accepted = true FROM lists l
+	WHERE l.id = m.list_id AND m.list_id = $1 AND m.user_id = $2 AND NOT m.accepted
+	AND l.workspace_id IS NOT DISTINCT FROM $3`
+var QueryChangeRole = `UPDATE list_members SET role = $3 WHERE list_id = $1 AND user_id = $2 AND role <> 'owner'`
+var QueryRevoke = `DELETE FROM list_members m USING lists l
+	WHERE l.id = m.list_id AND m.list_id = $1 AND m.user_id = $2 AND m.role <> 'owner'
+	AND l.workspace_id IS NOT DISTINCT FROM $3`
+
+type Storage struct {
+	db       db.Client
+	activity *activity.Storage
+	log      *logrus.Logger
+}
+
+func NewStorage(db db.Client, activity *activity.Storage, log *logrus.Logger) *Storage {
+	return &Storage{db: db, activity: activity, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]List, error) {
+	return s.queryLists(ctx, QueryGetAll, userId, workspaceId)
+}
+
+func (s *Storage) GetInvites(ctx context.Context, userId string, workspaceId *string) ([]List, error) {
+	return s.queryLists(ctx, QueryGetInvites, userId, workspaceId)
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (list List, err error) {
+	errQuery := s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId).
+		Scan(&list.Id, &list.Title, &list.OwnerId, &list.WorkspaceId, &list.Visibility, &list.Role)
+	if errQuery != nil {
+		if errors.Is(errQuery, pgx.ErrNoRows) {
+			return List{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(errQuery)
+		s.log.Errorf("failed to get list by id=(%s), due to error: %v", id, errQuery)
+		return List{}, errQuery
+	}
+	return list, nil
+}
+
+// GetByIds returns the lists of the given ids the user can still see,
+// regardless of their workspace.
+func (s *Storage) GetByIds(ctx context.Context, ids []string, userId string) ([]List, error) {
+	return s.queryLists(ctx, QueryGetByIds, ids, userId)
+}
+
+// GetCounts returns the open and overdue todos of the given lists by list id,
+// lists without todos being left out.
+func (s *Storage) GetCounts(ctx context.Context, ids []string) (map[string]Counts, error) {
+	rows, err := s.db.Query(ctx, QueryGetCounts, ids)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query list counts. due to error: %v", err)
+		return nil, err
+	}
+	counts := make(map[string]Counts)
+	for rows.Next() {
+		var id string
+		var c Counts
+		if errScan := rows.Scan(&id, &c.Open, &c.Overdue); errScan != nil {
+			s.log.Errorf("failed to scan list counts. due to error: %v", errScan)
+			return nil, errScan
+		}
+		counts[id] = c
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetRole returns the role the user holds on a list of the workspace. Users
+// without access, including pending invitees, get apperror.ErrNotFound.
+func (s *Storage) GetRole(ctx context.Context, id, userId string, workspaceId *string) (role Role, err error) {
+	errQuery := s.db.QueryRow(ctx, QueryGetRole, id, userId, workspaceId).Scan(&role)
+	if errQuery != nil {
+		if errors.Is(errQuery, pgx.ErrNoRows) {
+			return "", apperror.ErrNotFound
+		}
+		s.TraceQueryError(errQuery)
+		s.log.Errorf("failed to get role on list id=(%s), due to error: %v", id, errQuery)
+		return "", errQuery
+	}
+	return role, nil
+}
+
+func (s *Storage) Create(ctx context.Context, list *List) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	a, err := s.Insert(ctx, tx, list)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.activity.Publish(a)
+	return nil
+}
+
+// Insert creates the list and its owner membership inside the transaction and
+// records the activity, which the caller publishes once it has committed.
+func (s *Storage) Insert(ctx context.Context, tx pgx.Tx, list *List) (activity.Activity, error) {
+	var clientId *string
+	if list.Id != "" {
+		clientId = &list.Id
+	}
+	err := tx.QueryRow(ctx, QueryCreate, list.Title, list.OwnerId, list.WorkspaceId, list.Visibility, clientId).
+		Scan(&list.Id, &list.Visibility)
+	if err != nil {
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return activity.Activity{}, apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		return activity.Activity{}, err
+	}
+	if _, err = tx.Exec(ctx, QueryCreateOwner, list.Id, list.OwnerId); err != nil {
+		s.TraceQueryError(err)
+		return activity.Activity{}, err
+	}
+	list.Role = RoleOwner
+	return s.recordActivity(ctx, tx, *list, list.OwnerId, activity.KindCreated)
+}
+
+func (s *Storage) Update(ctx context.Context, list *List, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, QueryUpdate, list.Title, list.Visibility, list.Id).
+		Scan(&list.OwnerId, &list.WorkspaceId, &list.Visibility)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update list id=(%s). due to error: %v", list.Id, err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *list, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.activity.Publish(a)
+	return nil
+}
+
+// Delete records the activity before deleting the list, while the webhooks of
+// its members can still be resolved. The activity rows of the list outlive it
+// without their list, see activity_visible().
+func (s *Storage) Delete(ctx context.Context, id, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var list List
+	err = tx.QueryRow(ctx, QueryLock, id).
+		Scan(&list.Id, &list.Title, &list.OwnerId, &list.WorkspaceId, &list.Visibility)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, list, userId, activity.KindDeleted)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, QueryDelete, id); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete list id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.activity.Publish(a)
+	return nil
+}
+
+func (s *Storage) GetMembers(ctx context.Context, id string) ([]Member, error) {
+	rows, err := s.db.Query(ctx, QueryGetMembers, id)
+	if err != nil {
+		s.TraceQueryError(err)
+		return nil, err
+	}
+	members := make([]Member, 0)
+	for rows.Next() {
+		var member Member
+		errScan := rows.Scan(&member.ListId, &member.UserId, &member.Role, &member.Accepted)
+		if errScan != nil {
+			s.log.Errorf("failed to scan list member. due to error: %v", errScan)
+			return nil, errScan
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// Invite adds a pending member and records the share in the activity feed.
+// Lists of a workspace only accept members of that workspace, anyone else is
+// reported as apperror.ErrNotFound.
+func (s *Storage) Invite(ctx context.Context, member Member, invitedBy string, workspaceId *string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, QueryInvite, member.ListId, member.UserId, member.Role)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to invite user_id=(%s) to list id=(%s). due to error: %v", member.UserId, member.ListId, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+
+	payload, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+	a := activity.Activity{
+		WorkspaceId: workspaceId,
+		ListId:      &member.ListId,
+		ActorId:     invitedBy,
+		Kind:        activity.KindShared,
+		Payload:     payload,
+	}
+	if err = s.activity.WithTx(tx).Create(ctx, &a); err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.activity.Publish(a)
+	return nil
+}
+
+func (s *Storage) Accept(ctx context.Context, id, userId string, workspaceId *string) error {
+	return s.execMember(ctx, QueryAccept, id, userId, workspaceId)
+}
+
+func (s *Storage) ChangeRole(ctx context.Context, id, userId string, role Role) error {
+	return s.execMember(ctx, QueryChangeRole, id, userId, role)
+}
+
+func (s *Storage) Revoke(ctx context.Context, id, userId string, workspaceId *string) error {
+	return s.execMember(ctx, QueryRevoke, id, userId, workspaceId)
+}
+
+func (s *Storage) execMember(ctx context.Context, query, id, userId string, args ...interface{}) error {
+	tag, err := s.db.Exec(ctx, query, append([]interface{}{id, userId}, args...)...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update member user_id=(%s) of list id=(%s). due to error: %v", userId, id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Storage) recordActivity(ctx context.Context, tx pgx.Tx, list List, actorId, kind string) (activity.Activity, error) {
+	// the role is the one of the caller, not part of the list
+	list.Role, list.Counts = "", nil
+	payload, err := json.Marshal(list)
+	if err != nil {
+		return activity.Activity{}, err
+	}
+	a := activity.Activity{
+		WorkspaceId: list.WorkspaceId,
+		ListId:      &list.Id,
+		ActorId:     actorId,
+		Kind:        kind,
+		Payload:     payload,
+		Origin:      activity.OriginFromContext(ctx),
+	}
+	err = s.activity.WithTx(tx).Create(ctx, &a)
+	return a, err
+}
+
+func (s *Storage) queryLists(ctx context.Context, query string, args ...interface{}) ([]List, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query lists. due to error: %v", err)
+		return nil, err
+	}
+	lists := make([]List, 0)
+	for rows.Next() {
+		var list List
+		errScan := rows.Scan(&list.Id, &list.Title, &list.OwnerId, &list.WorkspaceId, &list.Visibility, &list.Role)
+		if errScan != nil {
+			s.log.Errorf("failed to scan list. due to error: %v", errScan)
+			return nil, errScan
+		}
+		lists = append(lists, list)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
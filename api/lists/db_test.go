@@ -0,0 +1,65 @@
+package lists
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"todoproject/apperror"
+	"todoproject/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeClient answers every QueryRow with row, the other methods of db.Client
+// are not expected to be called.
+type fakeClient struct {
+	db.Client
+	row pgx.Row
+}
+
+func (c *fakeClient) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return c.row
+}
+
+type fakeRow struct {
+	role Role
+	err  error
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*Role) = r.role
+	return nil
+}
+
+func TestGetRole(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	tests := []struct {
+		name     string
+		row      fakeRow
+		wantRole Role
+		wantErr  error
+	}{
+		{name: "member", row: fakeRow{role: RoleViewer}, wantRole: RoleViewer},
+		{name: "no access", row: fakeRow{err: pgx.ErrNoRows}, wantErr: apperror.ErrNotFound},
+		{name: "query failure", row: fakeRow{err: io.ErrUnexpectedEOF}, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewStorage(&fakeClient{row: tt.row}, nil, log)
+
+			role, err := storage.GetRole(context.Background(), "list", "user", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetRole() error = %v, want %v", err, tt.wantErr)
+			}
+			if role != tt.wantRole {
+				t.Errorf("GetRole() = %q, want %q", role, tt.wantRole)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package lists
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+var roleRank = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+// IsAssignable reports whether the role can be granted through an invite or a role change.
+// Ownership is never transferred this way.
+func (r Role) IsAssignable() bool {
+	return r == RoleViewer || r == RoleEditor || r == RoleAdmin
+}
+
+func (r Role) CanEdit() bool {
+	return roleRank[r] >= roleRank[RoleEditor]
+}
+
+func (r Role) CanManage() bool {
+	return roleRank[r] >= roleRank[RoleAdmin]
+}
+
+type List struct {
+	Id          string  `json:"id"`
+	Title       string  `json:"title"`
+	OwnerId     string  `json:"owner_id"`
+	WorkspaceId *string `json:"workspace_id"`
+	Visibility  string  `json:"visibility"`
+	Role        Role    `json:"role,omitempty"`
+	// Counts is only filled in by the list index.
+	Counts *Counts `json:"counts,omitempty"`
+}
+
+type Counts struct {
+	Open    int `json:"open"`
+	Overdue int `json:"overdue"`
+}
+
+type Member struct {
+	ListId   string `json:"list_id"`
+	UserId   string `json:"user_id"`
+	Role     Role   `json:"role"`
+	Accepted bool   `json:"accepted"`
+}
+
+type CreateListDto struct {
+	Title      string `json:"title" binding:"required"`
+	Visibility string `json:"visibility"`
+}
+
+type UpdateListDto struct {
+	Title      string `json:"title" binding:"required"`
+	Visibility string `json:"visibility"`
+}
+
+type InviteMemberDto struct {
+	UserId string `json:"user_id" binding:"required"`
+	Role   Role   `json:"role" binding:"required"`
+}
+
+type ChangeRoleDto struct {
+	Role Role `json:"role" binding:"required"`
+}
@@ -0,0 +1,20 @@
+package lists
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]List, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (List, error)
+	GetByIds(ctx context.Context, ids []string, userId string) ([]List, error)
+	GetCounts(ctx context.Context, ids []string) (map[string]Counts, error)
+	GetRole(ctx context.Context, id, userId string, workspaceId *string) (Role, error)
+	GetInvites(ctx context.Context, userId string, workspaceId *string) ([]List, error)
+	Create(ctx context.Context, list *List) error
+	Update(ctx context.Context, list *List, userId string) error
+	Delete(ctx context.Context, id, userId string) error
+	GetMembers(ctx context.Context, id string) ([]Member, error)
+	Invite(ctx context.Context, member Member, invitedBy string, workspaceId *string) error
+	Accept(ctx context.Context, id, userId string, workspaceId *string) error
+	ChangeRole(ctx context.Context, id, userId string, role Role) error
+	Revoke(ctx context.Context, id, userId string, workspaceId *string) error
+}
@@ -0,0 +1,65 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id     = "id"
+	Unread = "unread"
+)
+
+var (
+	RelativeNotificationUrl = "/notifications"
+	ReadNotificationUrl     = fmt.Sprintf("/notifications/:%s/read", Id)
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitNotificationHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeNotificationUrl, h.GetAll)
+		api.POST(ReadNotificationUrl, h.MarkRead)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	notifications, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId, ctx.Query(Unread) == "true")
+	if err != nil {
+		h.Log.Errorf("failed to get notifications. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get notifications"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", notifications))
+}
+
+func (h *Handler) MarkRead(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.MarkRead(ctx, ctx.Param(Id), user.Id); err != nil {
+		if errors.Is(err, apperror.ErrNotFound) {
+			ctx.AbortWithStatusJSON(http.StatusNotFound, apperror.NewJsonMessage("fail", "not found"))
+			return
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to mark notification as read"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", "read"))
+}
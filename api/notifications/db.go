@@ -0,0 +1,87 @@
+package notifications
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+var QueryGetAll = `SELECT id, user_id, workspace_id, kind, payload, read, created_at FROM notifications
+	WHERE user_id = $1 AND workspace_id IS NOT DISTINCT FROM $2 AND (NOT $3 OR NOT read) ORDER BY created_at DESC`
+var QueryCreate = `INSERT INTO notifications (user_id, workspace_id, kind, payload) VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at`
+var QueryMarkRead = `UPDATE notifications SET read = true WHERE id = $1 AND user_id = $2`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+// WithTx returns a storage that runs its queries inside the given transaction,
+// so notifications are only persisted together with the change that caused them.
+func (s *Storage) WithTx(tx db.Client) *Storage {
+	return &Storage{db: tx, log: s.log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string, unreadOnly bool) ([]Notification, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId, unreadOnly)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query notifications. due to error: %v", err)
+		return nil, err
+	}
+	notifications := make([]Notification, 0)
+	for rows.Next() {
+		var n Notification
+		errScan := rows.Scan(&n.Id, &n.UserId, &n.WorkspaceId, &n.Kind, &n.Payload, &n.Read, &n.CreatedAt)
+		if errScan != nil {
+			s.log.Errorf("failed to scan notification. due to error: %v", errScan)
+			return nil, errScan
+		}
+		notifications = append(notifications, n)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (s *Storage) Create(ctx context.Context, notification *Notification) error {
+	err := s.db.QueryRow(ctx, QueryCreate, notification.UserId, notification.WorkspaceId, notification.Kind, notification.Payload).
+		Scan(&notification.Id, &notification.CreatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create notification for user_id=(%s). due to error: %v", notification.UserId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) MarkRead(ctx context.Context, id, userId string) error {
+	tag, err := s.db.Exec(ctx, QueryMarkRead, id, userId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to mark notification id=(%s) as read. due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
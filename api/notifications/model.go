@@ -0,0 +1,21 @@
+package notifications
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	KindTodoAssigned   = "todo_assigned"
+	KindTodoUnassigned = "todo_unassigned"
+)
+
+type Notification struct {
+	Id          string          `json:"id"`
+	UserId      string          `json:"user_id"`
+	WorkspaceId *string         `json:"workspace_id"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Read        bool            `json:"read"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
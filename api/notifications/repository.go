@@ -0,0 +1,9 @@
+package notifications
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string, unreadOnly bool) ([]Notification, error)
+	Create(ctx context.Context, notification *Notification) error
+	MarkRead(ctx context.Context, id, userId string) error
+}
@@ -0,0 +1,254 @@
+package schedule
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ClockLayout = "15:04"
+	// Slots start on a multiple of Step, so a block never starts at the odd
+	// minute the schedule is filled at.
+	Step = 5 * time.Minute
+)
+
+var (
+	DefaultWorkStart = "09:00"
+	DefaultWorkEnd   = "17:00"
+	DefaultWorkDays  = []int{1, 2, 3, 4, 5}
+	DefaultMinutes   = 30
+	priorityRanks    = map[string]int{"high": 0, "medium": 1, "low": 2}
+)
+
+// slot is free time of the user's calendar.
+type slot struct {
+	start, end time.Time
+}
+
+// overlaps reports whether the block shares some time with [start, end). Like
+// the overlap queries, a block ending when the other starts does not overlap.
+func (b Block) overlaps(start, end time.Time) bool {
+	return b.StartsAt.Before(end) && b.EndsAt.After(start)
+}
+
+// AutoSchedule fills the free working hours of the days from and to with
+// blocks for the user's open todos not scheduled yet: those assigned to them,
+// and the unassigned ones they wrote. Todos due first are placed first, then
+// the higher priorities and the older todos. A todo takes its estimate, is
+// never split and goes to the first free slot it fits in; blocked todos are
+// left out. With dry_run the plan is only returned, otherwise it is saved,
+// which is 409 when the calendar changed meanwhile.
+func (h *Handler) AutoSchedule(ctx *gin.Context) {
+	var autoDto AutoScheduleDto
+	if err := ctx.ShouldBindJSON(&autoDto); err != nil {
+		h.Log.Errorf("failed to bind auto schedule. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	from, to, message := parseRange(autoDto.From, autoDto.To, location, MaxAutoDays)
+	if message == "" {
+		message = defaultHours(&autoDto)
+	}
+	if message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	now := time.Now().In(location)
+	busy, err := h.Storage.GetRange(ctx, user.Id, user.WorkspaceId, from, to)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get schedule"))
+		return
+	}
+	scheduled, err := h.Storage.GetScheduledTodoIds(ctx, user.Id, user.WorkspaceId, now)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get schedule"))
+		return
+	}
+	open := false
+	todos, err := h.todoStorage.GetFiltered(ctx, user.Id, user.WorkspaceId,
+		todo.Filter{ListId: autoDto.ListId, Completed: &open}, now)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+		return
+	}
+
+	candidates := make([]todo.Todo, 0, len(todos))
+	for _, t := range todos {
+		mine := (t.AssigneeId != nil && *t.AssigneeId == user.Id) || (t.AssigneeId == nil && t.UserId == user.Id)
+		if mine && !t.IsBlocked && !scheduled[t.Id] {
+			candidates = append(candidates, t)
+		}
+	}
+	sortCandidates(candidates)
+
+	slots := freeSlots(from, to, autoDto, busy, now)
+	result := pack(candidates, slots, *autoDto.DefaultMinutes)
+	for i := range result.Blocks {
+		result.Blocks[i].UserId, result.Blocks[i].WorkspaceId = user.Id, user.WorkspaceId
+	}
+	if !autoDto.DryRun {
+		if err = h.Storage.CreateMany(ctx, result.Blocks); err != nil {
+			if errors.Is(err, apperror.ErrConflict) {
+				ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "the schedule changed meanwhile, try again"))
+				return
+			}
+			apperror.AbortWithError(ctx, err, "failed to save schedule")
+			return
+		}
+	}
+	titles := make(map[string]string, len(candidates))
+	for _, t := range candidates {
+		titles[t.Id] = t.Title
+	}
+	for i := range result.Blocks {
+		title := titles[*result.Blocks[i].TodoId]
+		result.Blocks[i].Title = &title
+	}
+	result.Blocks = localBlocks(result.Blocks, location)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", result))
+}
+
+// defaultHours fills the unset working hours of the dto, and returns why they
+// are refused or an empty string.
+func defaultHours(dto *AutoScheduleDto) string {
+	if dto.WorkStart == nil {
+		dto.WorkStart = &DefaultWorkStart
+	}
+	if dto.WorkEnd == nil {
+		dto.WorkEnd = &DefaultWorkEnd
+	}
+	if dto.WorkDays == nil {
+		dto.WorkDays = DefaultWorkDays
+	}
+	if dto.DefaultMinutes == nil {
+		dto.DefaultMinutes = &DefaultMinutes
+	}
+	start, errStart := time.Parse(ClockLayout, *dto.WorkStart)
+	end, errEnd := time.Parse(ClockLayout, *dto.WorkEnd)
+	if errStart != nil || errEnd != nil || !end.After(start) {
+		return "working hours must be times as 15:04, ending after they start"
+	}
+	for _, day := range dto.WorkDays {
+		if day < 0 || day > 6 {
+			return "work days are numbered from 0 for Sunday to 6"
+		}
+	}
+	if *dto.DefaultMinutes < 5 || *dto.DefaultMinutes > 24*60 {
+		return "default_minutes must be between 5 and 1440"
+	}
+	return ""
+}
+
+// sortCandidates orders the todos by due date, those without one last, then
+// by priority from high and by age.
+func sortCandidates(todos []todo.Todo) {
+	rank := func(t todo.Todo) int {
+		if t.Priority != nil {
+			if r, ok := priorityRanks[*t.Priority]; ok {
+				return r
+			}
+		}
+		return len(priorityRanks)
+	}
+	sort.SliceStable(todos, func(i, j int) bool {
+		a, b := todos[i], todos[j]
+		if (a.DueAt == nil) != (b.DueAt == nil) {
+			return a.DueAt != nil
+		}
+		if a.DueAt != nil && !a.DueAt.Equal(*b.DueAt) {
+			return a.DueAt.Before(*b.DueAt)
+		}
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+}
+
+// freeSlots returns the working hours of the days of [from, to) left after
+// the busy blocks and the past, in order. Hours are taken on the wall clock
+// of each day, so days changing the clock keep them.
+func freeSlots(from, to time.Time, dto AutoScheduleDto, busy []Block, now time.Time) []slot {
+	workStart, _ := time.Parse(ClockLayout, *dto.WorkStart)
+	workEnd, _ := time.Parse(ClockLayout, *dto.WorkEnd)
+	workDays := make(map[time.Weekday]bool, len(dto.WorkDays))
+	for _, day := range dto.WorkDays {
+		workDays[time.Weekday(day)] = true
+	}
+	earliest := now.Truncate(Step)
+	if earliest.Before(now) {
+		earliest = earliest.Add(Step)
+	}
+
+	slots := make([]slot, 0)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !workDays[day.Weekday()] {
+			continue
+		}
+		free := slot{
+			start: time.Date(day.Year(), day.Month(), day.Day(), workStart.Hour(), workStart.Minute(), 0, 0, day.Location()),
+			end:   time.Date(day.Year(), day.Month(), day.Day(), workEnd.Hour(), workEnd.Minute(), 0, 0, day.Location()),
+		}
+		if free.start.Before(earliest) {
+			free.start = earliest
+		}
+		// Busy blocks come ordered by start, each one cuts what is before it
+		// out of the day.
+		for _, block := range busy {
+			if !free.start.Before(free.end) {
+				break
+			}
+			if !block.overlaps(free.start, free.end) {
+				continue
+			}
+			if block.StartsAt.After(free.start) {
+				slots = append(slots, slot{start: free.start, end: block.StartsAt})
+			}
+			free.start = block.EndsAt
+		}
+		if free.start.Before(free.end) {
+			slots = append(slots, free)
+		}
+	}
+	return slots
+}
+
+// pack places each todo at the start of the first slot long enough for it,
+// which shrinks the slot. Blocks are left untitled so they keep following the
+// title of their todo.
+func pack(todos []todo.Todo, slots []slot, defaultMinutes int) AutoSchedule {
+	result := AutoSchedule{Blocks: make([]Block, 0), Unscheduled: make([]Unscheduled, 0)}
+	for _, t := range todos {
+		minutes := defaultMinutes
+		if t.EstimatedMinutes != nil {
+			minutes = *t.EstimatedMinutes
+		}
+		length := time.Duration(minutes) * time.Minute
+		placed := false
+		for i := range slots {
+			if slots[i].end.Sub(slots[i].start) < length {
+				continue
+			}
+			id := t.Id
+			result.Blocks = append(result.Blocks, Block{TodoId: &id,
+				StartsAt: slots[i].start, EndsAt: slots[i].start.Add(length)})
+			slots[i].start = slots[i].start.Add(length)
+			placed = true
+			break
+		}
+		if !placed {
+			result.Unscheduled = append(result.Unscheduled, Unscheduled{TodoId: t.Id, Title: t.Title, Minutes: minutes})
+		}
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+package schedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"todoproject/api/todo"
+)
+
+// at is a time of the week of Monday June 9th 2025.
+func at(day, hour, minute int) time.Time {
+	return time.Date(2025, time.June, 9+day, hour, minute, 0, 0, time.UTC)
+}
+
+func block(start, end time.Time) Block {
+	return Block{StartsAt: start, EndsAt: end}
+}
+
+func TestBlockOverlaps(t *testing.T) {
+	start, end := at(0, 10, 0), at(0, 11, 0)
+	tests := []struct {
+		name  string
+		block Block
+		want  bool
+	}{
+		{name: "before", block: block(at(0, 8, 0), at(0, 9, 0)), want: false},
+		{name: "ends at the start", block: block(at(0, 9, 0), at(0, 10, 0)), want: false},
+		{name: "ends inside", block: block(at(0, 9, 30), at(0, 10, 30)), want: true},
+		{name: "inside", block: block(at(0, 10, 15), at(0, 10, 45)), want: true},
+		{name: "same time", block: block(start, end), want: true},
+		{name: "around", block: block(at(0, 9, 0), at(0, 12, 0)), want: true},
+		{name: "starts inside", block: block(at(0, 10, 30), at(0, 11, 30)), want: true},
+		{name: "starts at the end", block: block(at(0, 11, 0), at(0, 12, 0)), want: false},
+		{name: "after", block: block(at(0, 12, 0), at(0, 13, 0)), want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.block.overlaps(start, end); got != test.want {
+				t.Errorf("overlaps = %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFreeSlots(t *testing.T) {
+	dto := AutoScheduleDto{WorkStart: &DefaultWorkStart, WorkEnd: &DefaultWorkEnd, WorkDays: DefaultWorkDays}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		now      time.Time
+		busy     []Block
+		want     []slot
+	}{
+		{
+			name: "free days", from: at(0, 0, 0), to: at(2, 0, 0), now: at(-1, 12, 0),
+			want: []slot{{at(0, 9, 0), at(0, 17, 0)}, {at(1, 9, 0), at(1, 17, 0)}},
+		},
+		{
+			name: "weekend", from: at(-2, 0, 0), to: at(0, 0, 0), now: at(-3, 12, 0),
+			want: []slot{},
+		},
+		{
+			name: "past hours rounded up to the step", from: at(0, 0, 0), to: at(1, 0, 0), now: at(0, 10, 2),
+			want: []slot{{at(0, 10, 5), at(0, 17, 0)}},
+		},
+		{
+			name: "past day", from: at(0, 0, 0), to: at(1, 0, 0), now: at(0, 18, 0),
+			want: []slot{},
+		},
+		{
+			name: "busy blocks cut the day", from: at(0, 0, 0), to: at(2, 0, 0), now: at(0, 10, 2),
+			busy: []Block{
+				block(at(0, 11, 0), at(0, 12, 0)),
+				block(at(0, 11, 30), at(0, 13, 0)),
+				block(at(1, 8, 0), at(1, 9, 30)),
+				block(at(1, 16, 30), at(1, 18, 0)),
+			},
+			want: []slot{{at(0, 10, 5), at(0, 11, 0)}, {at(0, 13, 0), at(0, 17, 0)}, {at(1, 9, 30), at(1, 16, 30)}},
+		},
+		{
+			name: "blocks touching the working hours", from: at(0, 0, 0), to: at(1, 0, 0), now: at(-1, 12, 0),
+			busy: []Block{block(at(0, 8, 0), at(0, 9, 0)), block(at(0, 17, 0), at(0, 18, 0))},
+			want: []slot{{at(0, 9, 0), at(0, 17, 0)}},
+		},
+		{
+			name: "block inside a longer one", from: at(0, 0, 0), to: at(1, 0, 0), now: at(-1, 12, 0),
+			busy: []Block{block(at(0, 10, 0), at(0, 14, 0)), block(at(0, 11, 0), at(0, 12, 0))},
+			want: []slot{{at(0, 9, 0), at(0, 10, 0)}, {at(0, 14, 0), at(0, 17, 0)}},
+		},
+		{
+			name: "busy all day", from: at(0, 0, 0), to: at(1, 0, 0), now: at(-1, 12, 0),
+			busy: []Block{block(at(0, 8, 0), at(0, 18, 0))},
+			want: []slot{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := freeSlots(test.from, test.to, dto, test.busy, test.now)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("freeSlots = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	hour, long := 60, 180
+	todos := []todo.Todo{
+		{Id: "a", Title: "Write report", EstimatedMinutes: &hour},
+		{Id: "b", Title: "Reply to mail"},
+		{Id: "c", Title: "Migrate database", EstimatedMinutes: &long},
+	}
+	slots := []slot{{at(0, 9, 0), at(0, 9, 30)}, {at(0, 10, 0), at(0, 12, 0)}}
+
+	result := pack(todos, slots, DefaultMinutes)
+	a, b := "a", "b"
+	want := AutoSchedule{
+		Blocks: []Block{
+			{TodoId: &a, StartsAt: at(0, 10, 0), EndsAt: at(0, 11, 0)},
+			{TodoId: &b, StartsAt: at(0, 9, 0), EndsAt: at(0, 9, 30)},
+		},
+		Unscheduled: []Unscheduled{{TodoId: "c", Title: "Migrate database", Minutes: long}},
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("pack = %+v, want %+v", result, want)
+	}
+}
@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id           = "id"
+	From         = "from"
+	To           = "to"
+	AllowOverlap = "allow_overlap"
+)
+
+const (
+	MaxTitleLength = 100
+	MaxBlockLength = 24 * time.Hour
+	// MaxRangeDays bounds the days of a schedule read, MaxAutoDays the days
+	// filled by one auto-scheduling.
+	MaxRangeDays = 62
+	MaxAutoDays  = 31
+)
+
+var (
+	BlocksUrl       = "/time-blocks"
+	BlockUrl        = fmt.Sprintf("/time-blocks/:%s", Id)
+	ScheduleUrl     = "/schedule"
+	AutoScheduleUrl = "/schedule/auto"
+)
+
+type Handler struct {
+	Storage     *Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitScheduleHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.POST(BlocksUrl, h.Create)
+		api.PUT(BlockUrl, h.Update)
+		api.DELETE(BlockUrl, h.Delete)
+		api.GET(ScheduleUrl, h.GetSchedule)
+		api.POST(AutoScheduleUrl, h.AutoSchedule)
+	}
+}
+
+// GetSchedule returns the user's blocks of the days from and to of their
+// calendar, both included. It defaults to the coming week.
+func (h *Handler) GetSchedule(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	today := todo.StartOfDay(time.Now().In(location))
+	schedule := Schedule{
+		From:     ctx.DefaultQuery(From, today.Format(todo.DateLayout)),
+		To:       ctx.DefaultQuery(To, today.AddDate(0, 0, 6).Format(todo.DateLayout)),
+		Timezone: location.String(),
+	}
+	from, to, message := parseRange(schedule.From, schedule.To, location, MaxRangeDays)
+	if message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	blocks, err := h.Storage.GetRange(ctx, user.Id, user.WorkspaceId, from, to)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get schedule"))
+		return
+	}
+	schedule.Blocks = localBlocks(blocks, location)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", schedule))
+}
+
+// Create adds a block to the user's calendar. A block overlapping others is
+// refused with 409 and the blocks it overlaps, unless allow_overlap=true.
+func (h *Handler) Create(ctx *gin.Context) {
+	var blockDto BlockDto
+	if err := ctx.ShouldBindJSON(&blockDto); err != nil {
+		h.Log.Errorf("failed to bind time block. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	block := Block{UserId: user.Id, WorkspaceId: user.WorkspaceId}
+	if !h.fill(ctx, &block, blockDto) {
+		return
+	}
+	overlaps, err := h.Storage.Create(ctx, &block, ctx.Query(AllowOverlap) == "true")
+	h.respond(ctx, http.StatusCreated, block, overlaps, err, "failed to create time block")
+}
+
+// Update moves the block or changes what it is for, with the overlap rules of
+// Create.
+func (h *Handler) Update(ctx *gin.Context) {
+	var blockDto BlockDto
+	if err := ctx.ShouldBindJSON(&blockDto); err != nil {
+		h.Log.Errorf("failed to bind time block. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	block, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get time block")
+		return
+	}
+	if !h.fill(ctx, &block, blockDto) {
+		return
+	}
+	overlaps, err := h.Storage.Update(ctx, &block, ctx.Query(AllowOverlap) == "true")
+	h.respond(ctx, http.StatusOK, block, overlaps, err, "failed to update time block")
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete time block")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// fill validates the dto into the block. Blocks for a todo need the user to
+// see the todo and are named after it unless given a title.
+func (h *Handler) fill(ctx *gin.Context, block *Block, dto BlockDto) bool {
+	if dto.Title != nil {
+		title := strings.TrimSpace(*dto.Title)
+		if title == "" || len([]rune(title)) > MaxTitleLength {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "titles must have between 1 and 100 characters"))
+			return false
+		}
+		dto.Title = &title
+	}
+	if dto.TodoId == nil && dto.Title == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "either todo_id or title is required"))
+		return false
+	}
+	if !dto.EndsAt.After(dto.StartsAt) || dto.EndsAt.Sub(dto.StartsAt) > MaxBlockLength {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "a block must end after it starts and last at most a day"))
+		return false
+	}
+	if dto.TodoId != nil {
+		user := users.GetCurrentUser(ctx)
+		if _, _, err := h.todoStorage.GetTodoById(ctx, *dto.TodoId, user.Id, user.WorkspaceId); err != nil {
+			apperror.AbortWithError(ctx, err, "failed to get todo")
+			return false
+		}
+	}
+	block.TodoId, block.Title, block.StartsAt, block.EndsAt = dto.TodoId, dto.Title, dto.StartsAt, dto.EndsAt
+	return true
+}
+
+// respond writes the saved block, or the blocks it overlaps when the overlap
+// was refused.
+func (h *Handler) respond(ctx *gin.Context, status int, block Block, overlaps []Block, err error, message string) {
+	location := users.GetCurrentUser(ctx).Location()
+	if errors.Is(err, apperror.ErrConflict) {
+		ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", gin.H{
+			"message": "the block overlaps others, move it or allow the overlap", "overlaps": localBlocks(overlaps, location),
+		}))
+		return
+	}
+	if err != nil {
+		apperror.AbortWithError(ctx, err, message)
+		return
+	}
+	saved, err := h.Storage.GetById(ctx, block.Id, block.UserId, block.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, message)
+		return
+	}
+	ctx.JSON(status, apperror.NewJsonMessage("success", localBlocks([]Block{saved}, location)[0]))
+}
+
+// parseRange returns the span from the midnight starting the day from to the
+// midnight ending the day to, or why the dates are refused.
+func parseRange(from, to string, location *time.Location, maxDays int) (time.Time, time.Time, string) {
+	start, err := todo.ParseLocalDate(from, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, "invalid from date"
+	}
+	end, err := todo.ParseLocalDate(to, location)
+	if err != nil {
+		return time.Time{}, time.Time{}, "invalid to date"
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, "to must not be before from"
+	}
+	end = end.AddDate(0, 0, 1)
+	if end.After(start.AddDate(0, 0, maxDays)) {
+		return time.Time{}, time.Time{}, fmt.Sprintf("a range cannot span more than %d days", maxDays)
+	}
+	return start, end, ""
+}
+
+// localBlocks gives the times of the blocks in the location.
+func localBlocks(blocks []Block, location *time.Location) []Block {
+	for i := range blocks {
+		blocks[i].StartsAt, blocks[i].EndsAt = blocks[i].StartsAt.In(location), blocks[i].EndsAt.In(location)
+	}
+	return blocks
+}
@@ -0,0 +1,234 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"time"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// blockColumns names blocks by their title, or by the title of their todo
+// while the user can still see it.
+const blockColumns = `b.id, b.user_id, b.workspace_id, b.todo_id,
+	COALESCE(b.title, (SELECT t.title FROM todo t WHERE t.id = b.todo_id AND todo_role(t.list_id, t.user_id, b.user_id) IS NOT NULL)),
+	b.starts_at, b.ends_at,
+	EXISTS (SELECT 1 FROM time_blocks o WHERE o.user_id = b.user_id AND o.workspace_id IS NOT DISTINCT FROM b.workspace_id
+		AND o.id <> b.id AND o.starts_at < b.ends_at AND o.ends_at > b.starts_at)`
+
+// Blocks are private to their user and scoped to the workspace they were
+// created in, so are overlaps.
+var QueryGetRange = `SELECT ` + blockColumns + ` FROM time_blocks b
+	WHERE b.user_id = $1 AND b.workspace_id IS NOT DISTINCT FROM $2 AND b.starts_at < $4 AND b.ends_at > $3
+	ORDER BY b.starts_at, b.ends_at`
+var QueryGetById = `SELECT ` + blockColumns + ` FROM time_blocks b
+	WHERE b.id = $1 AND b.user_id = $2 AND b.workspace_id IS NOT DISTINCT FROM $3`
+var QueryLockUser = `SELECT pg_advisory_xact_lock(hashtext('time_blocks:' || $1::text))`
+var QueryGetOverlaps = `SELECT ` + blockColumns + ` FROM time_blocks b
+	WHERE b.user_id = $1 AND b.workspace_id IS NOT DISTINCT FROM $2 AND b.starts_at < $4 AND b.ends_at > $3
+	AND b.id IS DISTINCT FROM $5::uuid ORDER BY b.starts_at`
+var QueryCreate = `INSERT INTO time_blocks (user_id, workspace_id, todo_id, title, starts_at, ends_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+var QueryUpdate = `UPDATE time_blocks SET todo_id = $4, title = $5, starts_at = $6, ends_at = $7
+	WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryDelete = `DELETE FROM time_blocks WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryGetScheduledTodoIds = `SELECT DISTINCT todo_id FROM time_blocks
+	WHERE user_id = $1 AND workspace_id IS NOT DISTINCT FROM $2 AND todo_id IS NOT NULL AND ends_at > $3`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+// GetRange returns the blocks of the user sharing some time with [from, to).
+func (s *Storage) GetRange(ctx context.Context, userId string, workspaceId *string, from, to time.Time) ([]Block, error) {
+	return s.queryBlocks(ctx, s.db, QueryGetRange, userId, workspaceId, from, to)
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (block Block, err error) {
+	if err = scanBlock(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId), &block); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Block{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get time block by id=(%s), due to error: %v", id, err)
+		return Block{}, err
+	}
+	return block, nil
+}
+
+// Create saves the block. Unless allowOverlap is set, a block overlapping
+// others is not saved: they are returned with apperror.ErrConflict.
+func (s *Storage) Create(ctx context.Context, block *Block, allowOverlap bool) ([]Block, error) {
+	return s.write(ctx, block, allowOverlap, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, QueryCreate, block.UserId, block.WorkspaceId, block.TodoId, block.Title,
+			block.StartsAt, block.EndsAt).Scan(&block.Id)
+	})
+}
+
+// Update moves the block, with the same overlap rules as Create.
+func (s *Storage) Update(ctx context.Context, block *Block, allowOverlap bool) ([]Block, error) {
+	return s.write(ctx, block, allowOverlap, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, QueryUpdate, block.Id, block.UserId, block.WorkspaceId, block.TodoId, block.Title,
+			block.StartsAt, block.EndsAt)
+		if err == nil && tag.RowsAffected() == 0 {
+			return apperror.ErrNotFound
+		}
+		return err
+	})
+}
+
+// write checks the overlaps of the block and runs the change in the same
+// transaction, the blocks of the user being locked meanwhile.
+func (s *Storage) write(ctx context.Context, block *Block, allowOverlap bool, change func(tx pgx.Tx) error) ([]Block, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryLockUser, block.UserId); err != nil {
+		s.TraceQueryError(err)
+		return nil, err
+	}
+	var id *string
+	if block.Id != "" {
+		id = &block.Id
+	}
+	overlaps, err := s.queryBlocks(ctx, tx, QueryGetOverlaps, block.UserId, block.WorkspaceId, block.StartsAt, block.EndsAt, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(overlaps) > 0 && !allowOverlap {
+		return overlaps, apperror.ErrConflict
+	}
+	if err = change(tx); err != nil {
+		if !errors.Is(err, apperror.ErrNotFound) {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to write time block. due to error: %v", err)
+		}
+		return nil, err
+	}
+	block.Overlaps = len(overlaps) > 0
+	return overlaps, tx.Commit(ctx)
+}
+
+// CreateMany saves the blocks together, none of them may overlap an existing
+// block or the whole batch is apperror.ErrConflict.
+func (s *Storage) CreateMany(ctx context.Context, blocks []Block) error {
+	if len(blocks) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryLockUser, blocks[0].UserId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	for i := range blocks {
+		block := &blocks[i]
+		overlaps, err := s.queryBlocks(ctx, tx, QueryGetOverlaps, block.UserId, block.WorkspaceId, block.StartsAt, block.EndsAt, nil)
+		if err != nil {
+			return err
+		}
+		if len(overlaps) > 0 {
+			return apperror.ErrConflict
+		}
+		err = tx.QueryRow(ctx, QueryCreate, block.UserId, block.WorkspaceId, block.TodoId, block.Title,
+			block.StartsAt, block.EndsAt).Scan(&block.Id)
+		if err != nil {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to create time block. due to error: %v", err)
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete time block id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// GetScheduledTodoIds returns the todos having a block of the user ending
+// after the given time.
+func (s *Storage) GetScheduledTodoIds(ctx context.Context, userId string, workspaceId *string, after time.Time) (map[string]bool, error) {
+	rows, err := s.db.Query(ctx, QueryGetScheduledTodoIds, userId, workspaceId, after)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query scheduled todos. due to error: %v", err)
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if errScan := rows.Scan(&id); errScan != nil {
+			s.log.Errorf("failed to scan scheduled todo. due to error: %v", errScan)
+			return nil, errScan
+		}
+		ids[id] = true
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (s *Storage) queryBlocks(ctx context.Context, q db.Client, query string, args ...interface{}) ([]Block, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query time blocks. due to error: %v", err)
+		return nil, err
+	}
+	blocks := make([]Block, 0)
+	for rows.Next() {
+		var block Block
+		if errScan := scanBlock(rows, &block); errScan != nil {
+			s.log.Errorf("failed to scan time block. due to error: %v", errScan)
+			return nil, errScan
+		}
+		blocks = append(blocks, block)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func scanBlock(row pgx.Row, block *Block) error {
+	return row.Scan(&block.Id, &block.UserId, &block.WorkspaceId, &block.TodoId, &block.Title,
+		&block.StartsAt, &block.EndsAt, &block.Overlaps)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,58 @@
+package schedule
+
+import "time"
+
+// Block is a slot of the user's calendar for a todo, or busy time named by
+// its title. Overlaps is set on reads when another block shares some of it.
+type Block struct {
+	Id          string    `json:"id"`
+	UserId      string    `json:"user_id"`
+	WorkspaceId *string   `json:"workspace_id"`
+	TodoId      *string   `json:"todo_id"`
+	Title       *string   `json:"title"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Overlaps    bool      `json:"overlaps"`
+}
+
+type BlockDto struct {
+	TodoId   *string   `json:"todo_id"`
+	Title    *string   `json:"title"`
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+}
+
+// Schedule is the blocks of a range of days of the user's calendar, their
+// times given in the user's time zone.
+type Schedule struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Timezone string  `json:"timezone"`
+	Blocks   []Block `json:"blocks"`
+}
+
+// AutoScheduleDto sets the days and working hours to fill. Days are dates of
+// the user's calendar, hours are local times as "15:04" and work days are
+// numbered from Sunday as 0. Todos without an estimate take DefaultMinutes.
+type AutoScheduleDto struct {
+	From           string  `json:"from" binding:"required"`
+	To             string  `json:"to" binding:"required"`
+	WorkStart      *string `json:"work_start"`
+	WorkEnd        *string `json:"work_end"`
+	WorkDays       []int   `json:"work_days"`
+	DefaultMinutes *int    `json:"default_minutes"`
+	ListId         *string `json:"list_id"`
+	DryRun         bool    `json:"dry_run"`
+}
+
+type AutoSchedule struct {
+	Blocks      []Block       `json:"blocks"`
+	Unscheduled []Unscheduled `json:"unscheduled"`
+}
+
+// Unscheduled is a todo that did not fit in any free slot.
+type Unscheduled struct {
+	TodoId  string `json:"todo_id"`
+	Title   string `json:"title"`
+	Minutes int    `json:"minutes"`
+}
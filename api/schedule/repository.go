@@ -0,0 +1,16 @@
+package schedule
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	GetRange(ctx context.Context, userId string, workspaceId *string, from, to time.Time) ([]Block, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Block, error)
+	Create(ctx context.Context, block *Block, allowOverlap bool) ([]Block, error)
+	Update(ctx context.Context, block *Block, allowOverlap bool) ([]Block, error)
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	CreateMany(ctx context.Context, blocks []Block) error
+	GetScheduledTodoIds(ctx context.Context, userId string, workspaceId *string, after time.Time) (map[string]bool, error)
+}
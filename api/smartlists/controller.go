@@ -0,0 +1,212 @@
+package smartlists
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id = "id"
+)
+
+// MaxSmartLists bounds the smart lists of a user in a workspace, each one
+// costs a count query on every read of the index.
+const MaxSmartLists = 50
+
+var (
+	RelativeSmartListUrl = "/smart-lists"
+	GetByIdUrl           = fmt.Sprintf("/smart-lists/:%s", Id)
+	TodosUrl             = fmt.Sprintf("/smart-lists/:%s/todos", Id)
+	IndexUrl             = "/lists/index"
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitSmartListHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeSmartListUrl, h.GetAll)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeSmartListUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.GET(TodosUrl, h.GetTodos)
+		api.GET(IndexUrl, h.GetIndex)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	smartLists, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err == nil {
+		err = h.count(ctx, user, smartLists)
+	}
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get smart lists"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", smartLists))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	smartList, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get smart list")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", smartList))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var smartListDto SmartListDto
+	if !h.bind(ctx, &smartListDto) {
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	smartList := SmartList{OwnerId: user.Id, WorkspaceId: user.WorkspaceId, Name: smartListDto.Name, Filter: smartListDto.Filter}
+	if err := h.Storage.Create(ctx, &smartList); err != nil {
+		message := "failed to create smart list"
+		if errors.Is(err, apperror.ErrConflict) {
+			message = fmt.Sprintf("at most %d smart lists are allowed", MaxSmartLists)
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", smartList))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var smartListDto SmartListDto
+	if !h.bind(ctx, &smartListDto) {
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	smartList := SmartList{Id: ctx.Param(Id), OwnerId: user.Id, WorkspaceId: user.WorkspaceId,
+		Name: smartListDto.Name, Filter: smartListDto.Filter}
+	if err := h.Storage.Update(ctx, &smartList); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update smart list")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", smartList))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete smart list")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// GetTodos evaluates the smart list with the same filtering as the todo
+// listing, in the user's time zone.
+func (h *Handler) GetTodos(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	smartList, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get smart list")
+		return
+	}
+
+	now := time.Now().In(user.Location())
+	todos, err := h.todoStorage.GetFiltered(ctx, user.Id, user.WorkspaceId, smartList.Filter, now)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todos))
+}
+
+// GetIndex returns the lists of the workspace with their open and overdue
+// counts, and the smart lists of the user with their counts.
+func (h *Handler) GetIndex(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	index, err := h.index(ctx, user)
+	if err != nil {
+		h.Log.Errorf("failed to get list index. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get list index"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", index))
+}
+
+func (h *Handler) index(ctx *gin.Context, user users.User) (Index, error) {
+	all, err := h.listStorage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		return Index{}, err
+	}
+	ids := make([]string, len(all))
+	for i := range all {
+		ids[i] = all[i].Id
+	}
+	counts, err := h.listStorage.GetCounts(ctx, ids)
+	if err != nil {
+		return Index{}, err
+	}
+	for i := range all {
+		c := counts[all[i].Id]
+		all[i].Counts = &c
+	}
+
+	smartLists, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		return Index{}, err
+	}
+	if err = h.count(ctx, user, smartLists); err != nil {
+		return Index{}, err
+	}
+	return Index{Lists: all, SmartLists: smartLists}, nil
+}
+
+func (h *Handler) count(ctx *gin.Context, user users.User, smartLists []SmartList) error {
+	now := time.Now().In(user.Location())
+	for i := range smartLists {
+		count, err := h.todoStorage.Count(ctx, user.Id, user.WorkspaceId, smartLists[i].Filter, now)
+		if err != nil {
+			return err
+		}
+		smartLists[i].Count = &count
+	}
+	return nil
+}
+
+func (h *Handler) bind(ctx *gin.Context, smartListDto *SmartListDto) bool {
+	if err := ctx.ShouldBindJSON(smartListDto); err != nil {
+		h.Log.Errorf("failed to bind smart list. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return false
+	}
+	if len([]rune(smartListDto.Name)) > 100 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "name is too long"))
+		return false
+	}
+	if message := smartListDto.Filter.Validate(); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,145 @@
+package smartlists
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const smartListColumns = `id, owner_id, workspace_id, name, filter, created_at, updated_at`
+
+// Smart lists are private to their owner and scoped to the workspace they were
+// created in, the todos they show still go through todo_role.
+var QueryGetAll = `SELECT ` + smartListColumns + ` FROM smart_lists
+	WHERE owner_id = $1 AND workspace_id IS NOT DISTINCT FROM $2 ORDER BY name`
+var QueryGetById = `SELECT ` + smartListColumns + ` FROM smart_lists
+	WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryLockOwner = `SELECT pg_advisory_xact_lock(hashtext('smart_lists:' || $1::text))`
+var QueryCount = `SELECT count(*) FROM smart_lists WHERE owner_id = $1 AND workspace_id IS NOT DISTINCT FROM $2`
+var QueryCreate = `INSERT INTO smart_lists (owner_id, workspace_id, name, filter)
+	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
+var QueryUpdate = `UPDATE smart_lists SET name = $4, filter = $5, updated_at = now()
+	WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3
+	RETURNING ` + smartListColumns
+var QueryDelete = `DELETE FROM smart_lists WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]SmartList, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query smart lists. due to error: %v", err)
+		return nil, err
+	}
+	smartLists := make([]SmartList, 0)
+	for rows.Next() {
+		var smartList SmartList
+		if errScan := scanSmartList(rows, &smartList); errScan != nil {
+			s.log.Errorf("failed to scan smart list. due to error: %v", errScan)
+			return nil, errScan
+		}
+		smartLists = append(smartLists, smartList)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return smartLists, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (smartList SmartList, err error) {
+	if err = scanSmartList(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId), &smartList); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SmartList{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get smart list by id=(%s), due to error: %v", id, err)
+		return SmartList{}, err
+	}
+	return smartList, nil
+}
+
+// Create saves the smart list unless its owner already has MaxSmartLists of
+// them in the workspace, in which case apperror.ErrConflict is returned.
+func (s *Storage) Create(ctx context.Context, smartList *SmartList) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// serializes the creations of the owner so the limit cannot be raced
+	if _, err = tx.Exec(ctx, QueryLockOwner, smartList.OwnerId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	var count int
+	if err = tx.QueryRow(ctx, QueryCount, smartList.OwnerId, smartList.WorkspaceId).Scan(&count); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if count >= MaxSmartLists {
+		return apperror.ErrConflict
+	}
+	err = tx.QueryRow(ctx, QueryCreate, smartList.OwnerId, smartList.WorkspaceId, smartList.Name, smartList.Filter).
+		Scan(&smartList.Id, &smartList.CreatedAt, &smartList.UpdatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create smart list. due to error: %v", err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Storage) Update(ctx context.Context, smartList *SmartList) error {
+	row := s.db.QueryRow(ctx, QueryUpdate, smartList.Id, smartList.OwnerId, smartList.WorkspaceId, smartList.Name, smartList.Filter)
+	if err := scanSmartList(row, smartList); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update smart list id=(%s). due to error: %v", smartList.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete smart list id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func scanSmartList(row pgx.Row, smartList *SmartList) error {
+	return row.Scan(&smartList.Id, &smartList.OwnerId, &smartList.WorkspaceId, &smartList.Name, &smartList.Filter,
+		&smartList.CreatedAt, &smartList.UpdatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
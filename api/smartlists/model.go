@@ -0,0 +1,33 @@
+package smartlists
+
+import (
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+)
+
+// SmartList is a saved todo filter evaluated on every read, so relative due
+// windows such as "this_week" follow the calendar.
+type SmartList struct {
+	Id          string      `json:"id"`
+	OwnerId     string      `json:"owner_id"`
+	WorkspaceId *string     `json:"workspace_id"`
+	Name        string      `json:"name"`
+	Filter      todo.Filter `json:"filter"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	// Count is the number of matching todos, only filled in by the listings.
+	Count *int `json:"count,omitempty"`
+}
+
+type SmartListDto struct {
+	Name   string      `json:"name" binding:"required"`
+	Filter todo.Filter `json:"filter"`
+}
+
+// Index is what the sidebar shows: the lists with their counts next to the
+// smart lists with theirs.
+type Index struct {
+	Lists      []lists.List `json:"lists"`
+	SmartLists []SmartList  `json:"smart_lists"`
+}
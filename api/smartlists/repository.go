@@ -0,0 +1,11 @@
+package smartlists
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]SmartList, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (SmartList, error)
+	Create(ctx context.Context, smartList *SmartList) error
+	Update(ctx context.Context, smartList *SmartList) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+}
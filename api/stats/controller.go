@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"net/http"
+	"time"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	From       = "from"
+	To         = "to"
+	ListId     = "list_id"
+	AssigneeId = "assignee_id"
+)
+
+const (
+	DateLayout = "2006-01-02"
+	// DefaultDays is the length of the range when from is not given.
+	DefaultDays = 30
+	MaxDays     = 366
+)
+
+var RelativeStatsUrl = "/stats"
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitStatsHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeStatsUrl, h.GetStats)
+	}
+}
+
+// GetStats reports on the todos of the workspace the user can see, from and
+// to being local dates in the user's time zone. The range defaults to the
+// last 30 days.
+func (h *Handler) GetStats(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	query := Query{UserId: user.Id, WorkspaceId: user.WorkspaceId, Timezone: location.String()}
+
+	now := time.Now().In(location)
+	query.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if to, ok := ctx.GetQuery(To); ok {
+		date, err := time.ParseInLocation(DateLayout, to, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid to date"))
+			return
+		}
+		query.To = date
+	}
+	query.From = query.To.AddDate(0, 0, 1-DefaultDays)
+	if from, ok := ctx.GetQuery(From); ok {
+		date, err := time.ParseInLocation(DateLayout, from, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid from date"))
+			return
+		}
+		query.From = date
+	}
+	if query.From.After(query.To) || query.From.AddDate(0, 0, MaxDays).Before(query.To) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid date range"))
+		return
+	}
+
+	if listId, ok := ctx.GetQuery(ListId); ok {
+		query.ListId = &listId
+	}
+	if assigneeId, ok := ctx.GetQuery(AssigneeId); ok {
+		query.AssigneeId = &assigneeId
+	}
+
+	stats, err := h.Storage.Get(ctx, query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get stats"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", stats))
+}
@@ -0,0 +1,175 @@
+package stats
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/db"
+)
+
+// statsScope selects the todos of the workspace the user can see, narrowed by
+// the optional list and assignee, and marks the ones completed in the range.
+// Days are the local dates of the time zone $3. Every query starts with it so
+// they all take the same parameters.
+const statsScope = `WITH scope AS (
+	SELECT t.id, t.list_id, t.tags, t.due_at, t.created_at, t.completed_at,
+		(t.completed_at AT TIME ZONE $3)::date BETWEEN $4::date AND $5::date AS completed_in_range,
+		t.completed_at IS NULL AND t.due_at < now() AS overdue
+	FROM todo t
+	WHERE t.workspace_id IS NOT DISTINCT FROM $2 AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL
+	AND ($6::uuid IS NULL OR t.list_id = $6) AND ($7::uuid IS NULL OR t.assignee_id = $7))`
+
+var QuerySummary = statsScope + `
+	SELECT count(*) FILTER (WHERE (created_at AT TIME ZONE $3)::date BETWEEN $4::date AND $5::date),
+		count(*) FILTER (WHERE completed_in_range),
+		(avg(extract(epoch FROM completed_at - created_at)) FILTER (WHERE completed_in_range))::float8,
+		count(*) FILTER (WHERE overdue),
+		count(*) FILTER (WHERE completed_in_range AND completed_at > due_at)
+	FROM scope`
+var QueryCompletedPerDay = statsScope + `
+	SELECT to_char(d.day, 'YYYY-MM-DD'), count(s.id)
+	FROM (SELECT g::date AS day FROM generate_series($4::date::timestamp, $5::date::timestamp, interval '1 day') g) d
+	LEFT JOIN scope s ON s.completed_in_range AND (s.completed_at AT TIME ZONE $3)::date = d.day
+	GROUP BY d.day ORDER BY d.day`
+
+// QueryStreaks groups the days with a completion into runs of consecutive
+// days: within a run, the day minus its rank is constant.
+var QueryStreaks = statsScope + `,
+	days AS (SELECT DISTINCT (completed_at AT TIME ZONE $3)::date AS day FROM scope WHERE completed_at IS NOT NULL),
+	runs AS (SELECT min(day) AS first_day, max(day) AS last_day
+		FROM (SELECT day, day - (row_number() OVER (ORDER BY day))::int AS run FROM days) r GROUP BY run)
+	SELECT COALESCE((SELECT last_day - first_day + 1 FROM runs
+			WHERE last_day >= (now() AT TIME ZONE $3)::date - 1 ORDER BY last_day DESC LIMIT 1), 0),
+		COALESCE((SELECT max(least(last_day, $5::date) - greatest(first_day, $4::date) + 1) FROM runs
+			WHERE last_day >= $4::date AND first_day <= $5::date), 0)`
+var QueryByList = statsScope + `
+	SELECT s.list_id, l.title, count(*) FILTER (WHERE s.completed_in_range),
+		count(*) FILTER (WHERE s.completed_at IS NULL), count(*) FILTER (WHERE s.overdue)
+	FROM scope s LEFT JOIN lists l ON l.id = s.list_id
+	GROUP BY s.list_id, l.title ORDER BY 3 DESC, 4 DESC`
+var QueryByTag = statsScope + `
+	SELECT tag, count(*) FILTER (WHERE s.completed_in_range),
+		count(*) FILTER (WHERE s.completed_at IS NULL), count(*) FILTER (WHERE s.overdue)
+	FROM scope s CROSS JOIN LATERAL unnest(s.tags) AS tag
+	GROUP BY tag ORDER BY 2 DESC, 3 DESC, tag`
+
+// QuerySnapshot makes the queries of a report read the same snapshot.
+var QuerySnapshot = `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+// Get computes the statistics with one aggregate query per section, all in a
+// single read-only transaction.
+func (s *Storage) Get(ctx context.Context, query Query) (Stats, error) {
+	stats := Stats{
+		From:     query.From.Format(DateLayout),
+		To:       query.To.Format(DateLayout),
+		Timezone: query.Timezone,
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return Stats{}, err
+	}
+	defer tx.Rollback(ctx)
+	if _, err = tx.Exec(ctx, QuerySnapshot); err != nil {
+		s.TraceQueryError(err)
+		return Stats{}, err
+	}
+
+	args := []interface{}{query.UserId, query.WorkspaceId, query.Timezone,
+		query.From.Format(DateLayout), query.To.Format(DateLayout), query.ListId, query.AssigneeId}
+
+	err = tx.QueryRow(ctx, QuerySummary, args...).Scan(&stats.Created, &stats.Completed,
+		&stats.AverageCompletionSeconds, &stats.Overdue.Open, &stats.Overdue.CompletedLate)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get stats summary for user_id=(%s). due to error: %v", query.UserId, err)
+		return Stats{}, err
+	}
+	if err = tx.QueryRow(ctx, QueryStreaks, args...).Scan(&stats.CurrentStreak, &stats.LongestStreak); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get streaks for user_id=(%s). due to error: %v", query.UserId, err)
+		return Stats{}, err
+	}
+
+	stats.CompletedPerDay = make([]DayCount, 0)
+	err = s.queryRows(ctx, tx, QueryCompletedPerDay, args, func(rows pgx.Rows) error {
+		var day DayCount
+		if err := rows.Scan(&day.Date, &day.Count); err != nil {
+			return err
+		}
+		stats.CompletedPerDay = append(stats.CompletedPerDay, day)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.ByList = make([]ListStats, 0)
+	err = s.queryRows(ctx, tx, QueryByList, args, func(rows pgx.Rows) error {
+		var list ListStats
+		if err := rows.Scan(&list.ListId, &list.Title, &list.Completed, &list.Open, &list.Overdue); err != nil {
+			return err
+		}
+		stats.ByList = append(stats.ByList, list)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats.ByTag = make([]TagStats, 0)
+	err = s.queryRows(ctx, tx, QueryByTag, args, func(rows pgx.Rows) error {
+		var tag TagStats
+		if err := rows.Scan(&tag.Tag, &tag.Completed, &tag.Open, &tag.Overdue); err != nil {
+			return err
+		}
+		stats.ByTag = append(stats.ByTag, tag)
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) queryRows(ctx context.Context, tx pgx.Tx, query string, args []interface{}, scan func(pgx.Rows) error) error {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query stats. due to error: %v", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if errScan := scan(rows); errScan != nil {
+			s.log.Errorf("failed to scan stats. due to error: %v", errScan)
+			return errScan
+		}
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,65 @@
+package stats
+
+import "time"
+
+// Query selects the todos the statistics are computed on. From and To are
+// local dates of the time zone, both included.
+type Query struct {
+	UserId      string
+	WorkspaceId *string
+	ListId      *string
+	AssigneeId  *string
+	Timezone    string
+	From        time.Time
+	To          time.Time
+}
+
+type Stats struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+	Created  int    `json:"created"`
+	// Completed counts the todos completed in the range.
+	Completed       int        `json:"completed"`
+	CompletedPerDay []DayCount `json:"completed_per_day"`
+	// CurrentStreak is the number of consecutive days with a completion up to
+	// today, or up to yesterday while nothing was completed yet today.
+	CurrentStreak int `json:"current_streak"`
+	// LongestStreak is the longest run of such days within the range.
+	LongestStreak int `json:"longest_streak"`
+	// AverageCompletionSeconds is the mean time from creation to completion
+	// of the todos completed in the range, null when there are none.
+	AverageCompletionSeconds *float64    `json:"average_completion_seconds"`
+	Overdue                  Overdue     `json:"overdue"`
+	ByList                   []ListStats `json:"by_list"`
+	ByTag                    []TagStats  `json:"by_tag"`
+}
+
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type Overdue struct {
+	// Open counts the todos still open past their due date.
+	Open int `json:"open"`
+	// CompletedLate counts the todos completed in the range after their due date.
+	CompletedLate int `json:"completed_late"`
+}
+
+// ListStats is the breakdown of one list, todos without a list have a null
+// list id.
+type ListStats struct {
+	ListId    *string `json:"list_id"`
+	Title     *string `json:"title"`
+	Completed int     `json:"completed"`
+	Open      int     `json:"open"`
+	Overdue   int     `json:"overdue"`
+}
+
+type TagStats struct {
+	Tag       string `json:"tag"`
+	Completed int    `json:"completed"`
+	Open      int    `json:"open"`
+	Overdue   int    `json:"overdue"`
+}
@@ -0,0 +1,7 @@
+package stats
+
+import "context"
+
+type Repository interface {
+	Get(ctx context.Context, query Query) (Stats, error)
+}
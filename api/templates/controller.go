@@ -0,0 +1,319 @@
+package templates
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/api/workspaces"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id = "id"
+)
+
+const (
+	DateLayout = "2006-01-02"
+	TimeLayout = "15:04"
+	// MaxItems bounds the number of todos a template creates, subtasks included.
+	MaxItems = 500
+	MaxDepth = 5
+	// MaxOffsetDays bounds due offsets to about ten years either way.
+	MaxOffsetDays = 3650
+)
+
+var (
+	RelativeTemplateUrl = "/templates"
+	GetByIdUrl          = fmt.Sprintf("/templates/:%s", Id)
+	InstantiateUrl      = fmt.Sprintf("/templates/:%s/instantiate", Id)
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitTemplateHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeTemplateUrl, h.GetAll)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeTemplateUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.POST(InstantiateUrl, h.Instantiate)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	templates, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get templates"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", templates))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	template, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get template")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", template))
+}
+
+// Create saves a template from the given items, or from the todos of a list
+// the user can see when list_id is set.
+func (h *Handler) Create(ctx *gin.Context) {
+	var templateDto CreateTemplateDto
+	if err := ctx.ShouldBindJSON(&templateDto); err != nil {
+		h.Log.Errorf("failed to bind template. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	template := Template{OwnerId: user.Id, WorkspaceId: user.WorkspaceId, Title: templateDto.Title,
+		Description: templateDto.Description, Items: templateDto.Items}
+
+	if templateDto.ListId != nil {
+		anchor, err := parseDate(templateDto.Anchor, user.Location())
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid anchor date"))
+			return
+		}
+		if _, err = h.listStorage.GetRole(ctx, *templateDto.ListId, user.Id, user.WorkspaceId); err != nil {
+			apperror.AbortWithError(ctx, err, "failed to get list")
+			return
+		}
+		todos, err := h.todoStorage.GetAllTodoByUserId(ctx, user.Id, user.WorkspaceId, templateDto.ListId)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+			return
+		}
+		template.Items = itemsFromTodos(todos, anchor)
+	}
+
+	if message := validateItems(template.Items); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+	if err := h.Storage.Create(ctx, &template); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create template"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", template))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var templateDto UpdateTemplateDto
+	if err := ctx.ShouldBindJSON(&templateDto); err != nil {
+		h.Log.Errorf("failed to bind template. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if message := validateItems(templateDto.Items); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	template := Template{Id: ctx.Param(Id), OwnerId: user.Id, WorkspaceId: user.WorkspaceId, Title: templateDto.Title,
+		Description: templateDto.Description, Items: templateDto.Items}
+	if err := h.Storage.Update(ctx, &template); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update template")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", template))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete template")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// Instantiate creates a new list owned by the user with every todo of the
+// template, due dates counted from the start date in the user's time zone.
+func (h *Handler) Instantiate(ctx *gin.Context) {
+	var instantiateDto InstantiateDto
+	if err := ctx.ShouldBindJSON(&instantiateDto); err != nil {
+		h.Log.Errorf("failed to bind template instantiation. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	if user.WorkspaceRole == string(workspaces.RoleGuest) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "guests cannot create lists"))
+		return
+	}
+	if !lists.ValidVisibility(instantiateDto.Visibility, user.WorkspaceId) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid visibility"))
+		return
+	}
+	start, err := parseDate(instantiateDto.StartDate, user.Location())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid start date"))
+		return
+	}
+
+	template, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get template")
+		return
+	}
+
+	list := lists.List{Title: template.Title, OwnerId: user.Id, WorkspaceId: user.WorkspaceId, Visibility: instantiateDto.Visibility}
+	if instantiateDto.Title != "" {
+		list.Title = instantiateDto.Title
+	}
+	todos, parents := make([]todo.Todo, 0), make([]int, 0)
+	flatten(template.Items, -1, start, user.Id, &todos, &parents)
+
+	if err = h.Storage.Instantiate(ctx, &list, todos, parents); err != nil {
+		h.Log.Errorf("failed to instantiate template id=(%s). due to error: %v", template.Id, err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to instantiate template"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", gin.H{"list": list, "todos": todos}))
+}
+
+// flatten appends the items and their subtasks parents first, with the index
+// of the parent of every todo.
+func flatten(items []Item, parent int, start time.Time, userId string, todos *[]todo.Todo, parents *[]int) {
+	for _, item := range items {
+		t := todo.Todo{Title: item.Title, UserId: userId, Tags: item.Tags, Priority: item.Priority, Recurrence: item.Recurrence,
+			EstimatedMinutes: item.EstimatedMinutes}
+		if item.DueOffsetDays != nil {
+			hour, minute := 0, 0
+			if item.DueTime != nil {
+				at, _ := time.Parse(TimeLayout, *item.DueTime)
+				hour, minute = at.Hour(), at.Minute()
+			}
+			due := time.Date(start.Year(), start.Month(), start.Day()+*item.DueOffsetDays, hour, minute, 0, 0, start.Location())
+			t.DueAt = &due
+		}
+		*todos = append(*todos, t)
+		*parents = append(*parents, parent)
+		flatten(item.Subtasks, len(*todos)-1, start, userId, todos, parents)
+	}
+}
+
+// itemsFromTodos turns the todos of a list into items, subtasks under their
+// parent, with due dates relative to the anchor in its location.
+func itemsFromTodos(todos []todo.Todo, anchor time.Time) []Item {
+	sort.SliceStable(todos, func(i, j int) bool { return todos[i].CreatedAt.Before(todos[j].CreatedAt) })
+
+	ids := make(map[string]bool, len(todos))
+	for _, t := range todos {
+		ids[t.Id] = true
+	}
+	children := make(map[string][]todo.Todo)
+	roots := make([]todo.Todo, 0)
+	for _, t := range todos {
+		if t.ParentId != nil && ids[*t.ParentId] {
+			children[*t.ParentId] = append(children[*t.ParentId], t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+
+	var build func([]todo.Todo) []Item
+	build = func(todos []todo.Todo) []Item {
+		items := make([]Item, 0, len(todos))
+		for _, t := range todos {
+			item := Item{Title: t.Title, Tags: t.Tags, Priority: t.Priority, Recurrence: t.Recurrence,
+				EstimatedMinutes: t.EstimatedMinutes, Subtasks: build(children[t.Id])}
+			if t.DueAt != nil {
+				local := t.DueAt.In(anchor.Location())
+				day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+				offset := int(day.Sub(time.Date(anchor.Year(), anchor.Month(), anchor.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24)
+				item.DueOffsetDays = &offset
+				if local.Hour() != 0 || local.Minute() != 0 {
+					at := local.Format(TimeLayout)
+					item.DueTime = &at
+				}
+			}
+			items = append(items, item)
+		}
+		return items
+	}
+	return build(roots)
+}
+
+// validateItems returns why the items cannot be saved, or an empty string.
+func validateItems(items []Item) string {
+	count := 0
+	var validate func([]Item, int) string
+	validate = func(items []Item, depth int) string {
+		if depth > MaxDepth {
+			return fmt.Sprintf("subtasks cannot be nested more than %d levels", MaxDepth)
+		}
+		for _, item := range items {
+			if count++; count > MaxItems {
+				return fmt.Sprintf("a template cannot have more than %d items", MaxItems)
+			}
+			if item.Title == "" || len([]rune(item.Title)) > 100 {
+				return "item titles must have between 1 and 100 characters"
+			}
+			for _, tag := range item.Tags {
+				if tag == "" || len([]rune(tag)) > 50 {
+					return "tags must have between 1 and 50 characters"
+				}
+			}
+			if item.Priority != nil && !todo.Priorities[*item.Priority] {
+				return "invalid priority"
+			}
+			if item.EstimatedMinutes != nil && *item.EstimatedMinutes <= 0 {
+				return "invalid estimated minutes"
+			}
+			if item.DueOffsetDays != nil && (*item.DueOffsetDays > MaxOffsetDays || *item.DueOffsetDays < -MaxOffsetDays) {
+				return "invalid due offset"
+			}
+			if item.DueTime != nil {
+				if item.DueOffsetDays == nil {
+					return "a due time needs a due offset"
+				}
+				if _, err := time.Parse(TimeLayout, *item.DueTime); err != nil {
+					return "invalid due time"
+				}
+			}
+			if message := validate(item.Subtasks, depth+1); message != "" {
+				return message
+			}
+		}
+		return ""
+	}
+	return validate(items, 1)
+}
+
+// parseDate reads a local date, today when it is not given.
+func parseDate(date *string, location *time.Location) (time.Time, error) {
+	if date == nil {
+		now := time.Now().In(location)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location), nil
+	}
+	return time.ParseInLocation(DateLayout, *date, location)
+}
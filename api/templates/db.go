@@ -0,0 +1,175 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/api/activity"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const templateColumns = `id, owner_id, workspace_id, title, description, items, created_at, updated_at`
+
+// Templates are private to their owner and scoped to the workspace they were
+// created in.
+var QueryGetAll = `SELECT ` + templateColumns + ` FROM list_templates
+	WHERE owner_id = $1 AND workspace_id IS NOT DISTINCT FROM $2 ORDER BY title`
+var QueryGetById = `SELECT ` + templateColumns + ` FROM list_templates
+	WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryCreate = `INSERT INTO list_templates (owner_id, workspace_id, title, description, items)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, updated_at`
+var QueryUpdate = `UPDATE list_templates SET title = $4, description = $5, items = $6, updated_at = now()
+	WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3
+	RETURNING ` + templateColumns
+var QueryDelete = `DELETE FROM list_templates WHERE id = $1 AND owner_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+
+type Storage struct {
+	db    db.Client
+	lists *lists.Storage
+	todos *todo.Storage
+	log   *logrus.Logger
+}
+
+func NewStorage(db db.Client, lists *lists.Storage, todos *todo.Storage, log *logrus.Logger) *Storage {
+	return &Storage{db: db, lists: lists, todos: todos, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Template, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query templates. due to error: %v", err)
+		return nil, err
+	}
+	templates := make([]Template, 0)
+	for rows.Next() {
+		var template Template
+		if errScan := scanTemplate(rows, &template); errScan != nil {
+			s.log.Errorf("failed to scan template. due to error: %v", errScan)
+			return nil, errScan
+		}
+		templates = append(templates, template)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (template Template, err error) {
+	if err = scanTemplate(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId), &template); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Template{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get template by id=(%s), due to error: %v", id, err)
+		return Template{}, err
+	}
+	return template, nil
+}
+
+func (s *Storage) Create(ctx context.Context, template *Template) error {
+	items, err := json.Marshal(template.Items)
+	if err != nil {
+		return err
+	}
+	err = s.db.QueryRow(ctx, QueryCreate, template.OwnerId, template.WorkspaceId, template.Title, template.Description, items).
+		Scan(&template.Id, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create template. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Update(ctx context.Context, template *Template) error {
+	items, err := json.Marshal(template.Items)
+	if err != nil {
+		return err
+	}
+	row := s.db.QueryRow(ctx, QueryUpdate, template.Id, template.OwnerId, template.WorkspaceId,
+		template.Title, template.Description, items)
+	if err = scanTemplate(row, template); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update template id=(%s). due to error: %v", template.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete template id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// Instantiate creates the list and its todos in one transaction. The todos are
+// ordered parents first, parents[i] being the index of the parent of todos[i]
+// or -1. Their list and ids are filled in, the activities of the created list
+// and todos are published once everything is committed.
+func (s *Storage) Instantiate(ctx context.Context, list *lists.List, todos []todo.Todo, parents []int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := s.lists.Insert(ctx, tx, list)
+	if err != nil {
+		return err
+	}
+	activities := make([]activity.Activity, 0, len(todos)+1)
+	activities = append(activities, created)
+	for i := range todos {
+		todos[i].ListId, todos[i].WorkspaceId = &list.Id, list.WorkspaceId
+		if parents[i] >= 0 {
+			todos[i].ParentId = &todos[parents[i]].Id
+		}
+		a, err := s.todos.Insert(ctx, tx, &todos[i])
+		if err != nil {
+			s.log.Errorf("failed to create todo of list id=(%s) from template. due to error: %v", list.Id, err)
+			return err
+		}
+		activities = append(activities, a)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.todos.Committed(ctx, activities...)
+	return nil
+}
+
+func scanTemplate(row pgx.Row, template *Template) error {
+	return row.Scan(&template.Id, &template.OwnerId, &template.WorkspaceId, &template.Title, &template.Description,
+		&template.Items, &template.CreatedAt, &template.UpdatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,57 @@
+package templates
+
+import "time"
+
+// Template is a reusable list. Its todos are stored as a tree of items whose
+// due dates are relative to the start date chosen on instantiation.
+type Template struct {
+	Id          string    `json:"id"`
+	OwnerId     string    `json:"owner_id"`
+	WorkspaceId *string   `json:"workspace_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Items       []Item    `json:"items"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Item struct {
+	Title      string   `json:"title"`
+	Tags       []string `json:"tags"`
+	Priority   *string  `json:"priority"`
+	Recurrence *string  `json:"recurrence"`
+	// EstimatedMinutes is copied to the estimate of the todo.
+	EstimatedMinutes *int `json:"estimated_minutes"`
+	// DueOffsetDays is the number of days between the start date and the due
+	// date, the item has no due date when it is null.
+	DueOffsetDays *int `json:"due_offset_days"`
+	// DueTime is the local time of the due date as "15:04", the todo is due
+	// at the start of the day without it.
+	DueTime  *string `json:"due_time"`
+	Subtasks []Item  `json:"subtasks"`
+}
+
+// CreateTemplateDto creates a template either from the given items or, when
+// ListId is set, from the todos of that list. Due dates of the list are made
+// relative to Anchor, a local date defaulting to today.
+type CreateTemplateDto struct {
+	Title       string  `json:"title" binding:"required"`
+	Description string  `json:"description"`
+	Items       []Item  `json:"items"`
+	ListId      *string `json:"list_id"`
+	Anchor      *string `json:"anchor"`
+}
+
+type UpdateTemplateDto struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Items       []Item `json:"items"`
+}
+
+// InstantiateDto names the new list, Title defaulting to the template's, and
+// sets the local date the due offsets count from, today by default.
+type InstantiateDto struct {
+	Title      string  `json:"title"`
+	StartDate  *string `json:"start_date"`
+	Visibility string  `json:"visibility"`
+}
@@ -0,0 +1,16 @@
+package templates
+
+import (
+	"context"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Template, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Template, error)
+	Create(ctx context.Context, template *Template) error
+	Update(ctx context.Context, template *Template) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	Instantiate(ctx context.Context, list *lists.List, todos []todo.Todo, parents []int) error
+}
@@ -1,8 +1,16 @@
 package todo
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todoproject/api/activity"
+	"todoproject/api/lists"
 	"todoproject/api/users"
 	"todoproject/api/util"
 	"todoproject/apperror"
@@ -12,39 +20,82 @@ import (
 )
 
 const (
-	Id = "id"
+	Id        = "id"
+	ListId    = "list_id"
+	BlockerId = "blocker_id"
+	Force     = "force"
 )
 
 var (
 	GetAllUrl       = "/todos"
+	AssignedToMeUrl = "/todos/assigned-to-me"
 	GetByIdUrl      = fmt.Sprintf("/todo/:%s", Id)
+	AssigneeUrl     = fmt.Sprintf("/todo/:%s/assignee", Id)
+	CompleteUrl     = fmt.Sprintf("/todo/:%s/complete", Id)
+	BlockersUrl     = fmt.Sprintf("/todo/:%s/blockers", Id)
+	BlockerUrl      = fmt.Sprintf("/todo/:%s/blockers/:%s", Id, BlockerId)
 	RelativeTodoUrl = "/todo"
+	QuickUrl        = "/todo/quick"
 )
 
 type Handler struct {
 	Storage     *Storage
+	listStorage *lists.Storage
+	activity    *activity.Storage
 	userHandler *users.Handler
 	Log         *logrus.Logger
 }
 
-func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
-	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+func NewHandler(storage *Storage, listStorage *lists.Storage, activity *activity.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, activity: activity, userHandler: userHandler, Log: log}
 }
 
 func (h *Handler) InitTodoHandler(e *gin.Engine) {
 	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
 	{
 		api.GET(GetAllUrl, h.GetAll)
+		api.GET(AssignedToMeUrl, h.GetAssigned)
 		api.GET(GetByIdUrl, h.GetById)
 		api.POST(RelativeTodoUrl, h.Create)
+		api.POST(QuickUrl, h.QuickAdd)
 		api.PUT(RelativeTodoUrl, h.Update)
 		api.DELETE(RelativeTodoUrl, h.Delete)
+		api.PUT(AssigneeUrl, h.Assign)
+		api.DELETE(AssigneeUrl, h.Unassign)
+		api.PUT(CompleteUrl, h.Complete)
+		api.DELETE(CompleteUrl, h.Reopen)
+		api.GET(BlockersUrl, h.GetBlockers)
+		api.POST(BlockersUrl, h.AddBlocker)
+		api.DELETE(BlockerUrl, h.RemoveBlocker)
+		api.GET(TodayUrl, h.GetToday)
+		api.PUT(SnoozeUrl, h.Snooze)
+		api.DELETE(SnoozeUrl, h.Unsnooze)
+		api.PUT(MyDayUrl, h.Pick)
+		api.DELETE(MyDayUrl, h.Unpick)
+		api.GET(RotationUrl, h.GetRotation)
+		api.PUT(RotationUrl, h.SetRoster)
+		api.DELETE(RotationUrl, h.ClearRoster)
+		api.POST(RotationSkipUrl, h.Skip)
+		api.POST(RotationSwapUrl, h.Swap)
+		api.GET(ListRotationsUrl, h.GetListRotations)
+		api.PUT(WaitingUrl, h.SetWaiting)
+		api.DELETE(WaitingUrl, h.ClearWaiting)
+		api.GET(WeeklyReviewUrl, h.GetWeeklyReview)
+		api.GET(WsUrl, h.ServeWs)
 	}
 }
 
+// GetAll lists the todos the user can see, narrowed by the filter in the query
+// string, see ParseFilter.
 func (h *Handler) GetAll(ctx *gin.Context) {
-	user := GetCurrentUser(ctx)
-	todos, err := h.Storage.GetAllTodoByUserId(ctx, user.Id)
+	user := users.GetCurrentUser(ctx)
+	filter, err := ParseFilter(ctx, user.Location())
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	todos, err := h.Storage.GetFiltered(ctx, user.Id, user.WorkspaceId, filter, time.Now().In(user.Location()))
 	if err != nil {
 		h.Log.Errorf("failed to get todos. due to error: %v", err)
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
@@ -53,13 +104,113 @@ func (h *Handler) GetAll(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todos))
 }
 
+// ParseFilter reads a Filter from the query string: list_id, tag (repeated),
+// priority, completed, blocked, assignee_id, status_id, search, due, the
+// due_after and due_before dates, which are days in the given location,
+// include_snoozed, sort and desc. Custom field conditions are repeated field parameters written
+// <field id>:<op>[:<value>], values that are not JSON being taken as strings.
+func ParseFilter(ctx *gin.Context, location *time.Location) (filter Filter, err error) {
+	optional := func(key string) *string {
+		if value, ok := ctx.GetQuery(key); ok {
+			return &value
+		}
+		return nil
+	}
+	flag := func(key string) (*bool, error) {
+		value, ok := ctx.GetQuery(key)
+		if !ok {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", key)
+		}
+		return &b, nil
+	}
+	date := func(key string) (*time.Time, error) {
+		value, ok := ctx.GetQuery(key)
+		if !ok {
+			return nil, nil
+		}
+		t, err := ParseLocalDate(value, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s", key)
+		}
+		return &t, nil
+	}
+
+	filter = Filter{ListId: optional(ListId), Tags: ctx.QueryArray("tag"), Priority: optional("priority"),
+		AssigneeId: optional("assignee_id"), StatusId: optional("status_id"), Search: optional("search"), Due: optional("due")}
+	if filter.Completed, err = flag("completed"); err != nil {
+		return Filter{}, err
+	}
+	if filter.Blocked, err = flag("blocked"); err != nil {
+		return Filter{}, err
+	}
+	if filter.NextAction, err = flag("next_action"); err != nil {
+		return Filter{}, err
+	}
+	if filter.Waiting, err = flag("waiting"); err != nil {
+		return Filter{}, err
+	}
+	if context := optional("context"); context != nil {
+		normalized := normalizeContext(*context)
+		filter.Context = &normalized
+	}
+	if filter.DueAfter, err = date("due_after"); err != nil {
+		return Filter{}, err
+	}
+	if filter.DueBefore, err = date("due_before"); err != nil {
+		return Filter{}, err
+	}
+	snoozed, err := flag("include_snoozed")
+	if err != nil {
+		return Filter{}, err
+	}
+	filter.IncludeSnoozed = snoozed != nil && *snoozed
+	desc, err := flag("desc")
+	if err != nil {
+		return Filter{}, err
+	}
+	filter.Sort, filter.Desc = ctx.Query("sort"), desc != nil && *desc
+	for _, field := range ctx.QueryArray("field") {
+		parts := strings.SplitN(field, ":", 3)
+		if len(parts) < 2 {
+			return Filter{}, fmt.Errorf("invalid field condition %q", field)
+		}
+		condition := FieldCondition{FieldId: parts[0], Op: parts[1]}
+		if len(parts) == 3 {
+			condition.Value = json.RawMessage(parts[2])
+			if !json.Valid(condition.Value) {
+				condition.Value, _ = json.Marshal(parts[2])
+			}
+		}
+		filter.Fields = append(filter.Fields, condition)
+	}
+	if message := filter.Validate(); message != "" {
+		return Filter{}, errors.New(message)
+	}
+	return filter, nil
+}
+
+func (h *Handler) GetAssigned(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todos, err := h.Storage.GetAssignedTodos(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		h.Log.Errorf("failed to get assigned todos. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get assigned todos"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todos))
+}
+
 func (h *Handler) GetById(ctx *gin.Context) {
 	id := ctx.Param(Id)
-	user := GetCurrentUser(ctx)
-	todo, err := h.Storage.GetTodoById(ctx, id, user.Id)
+	user := users.GetCurrentUser(ctx)
+	todo, _, err := h.Storage.GetTodoById(ctx, id, user.Id, user.WorkspaceId)
 	if err != nil {
 		h.Log.Errorf("failed to get todo by id=(%s). due to error: %v", id, err)
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todo"))
+		apperror.AbortWithError(ctx, err, "failed to get todo")
 		return
 	}
 	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
@@ -74,15 +225,15 @@ func (h *Handler) Create(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
 		return
 	}
-
-	user := GetCurrentUser(ctx)
-	todo := Todo{
-		Title: todoDto.Title, UserId: user.Id,
+	if _, message := NormalizeContexts(todoDto.Contexts); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
 	}
 
-	if err := h.Storage.Create(ctx, &todo); err != nil {
+	todo, err := h.createTodo(ctx, users.GetCurrentUser(ctx), todoDto)
+	if err != nil {
 		h.Log.Errorf("failed to create todo. due to error: %v", err)
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		apperror.AbortWithError(ctx, err, "failed to create todo")
 		return
 	}
 	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", todo))
@@ -95,20 +246,18 @@ func (h *Handler) Update(ctx *gin.Context) {
 		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
 		return
 	}
-
-	user := GetCurrentUser(ctx)
-
-	if user.Id != todo.Id {
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to update"))
+	if _, message := NormalizeContexts(todo.Contexts); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
 		return
 	}
 
-	if err := h.Storage.Update(ctx, todo); err != nil {
+	updated, err := h.updateTodo(ctx, users.GetCurrentUser(ctx), todo)
+	if err != nil {
 		h.Log.Errorf("failed to update todo. due to error: %v", err)
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		apperror.AbortWithError(ctx, err, "failed to update todo")
 		return
 	}
-	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", updated))
 }
 
 func (h *Handler) Delete(ctx *gin.Context) {
@@ -119,16 +268,262 @@ func (h *Handler) Delete(ctx *gin.Context) {
 		return
 	}
 
-	user := GetCurrentUser(ctx)
-
-	if err := h.Storage.Delete(ctx, deleteDto.TodoId, user.Id); err != nil {
+	if err := h.deleteTodo(ctx, users.GetCurrentUser(ctx), deleteDto.TodoId); err != nil {
 		h.Log.Errorf("failed to delete todo. due to error: %v", err)
-		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		apperror.AbortWithError(ctx, err, "failed to delete todo")
 		return
 	}
 	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
 }
 
-func GetCurrentUser(ctx *gin.Context) users.User {
-	return ctx.MustGet(util.CURRENT_USER).(users.User)
+func (h *Handler) Assign(ctx *gin.Context) {
+	var assignDto AssignTodoDto
+	if err := ctx.ShouldBindJSON(&assignDto); err != nil {
+		h.Log.Errorf("failed to bind assignee. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.ListId == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "only todos on a list can be assigned"))
+		return
+	}
+	if _, err := h.listStorage.GetRole(ctx, *todo.ListId, assignDto.AssigneeId, user.WorkspaceId); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "assignee has no access to the list"))
+		return
+	}
+
+	if err := h.Storage.Assign(ctx, &todo, &assignDto.AssigneeId, user.Id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to assign todo"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+func (h *Handler) Unassign(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.AssigneeId == nil {
+		ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+		return
+	}
+
+	if err := h.Storage.Assign(ctx, &todo, nil, user.Id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to unassign todo"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+func (h *Handler) Complete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.CompletedAt != nil {
+		ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+		return
+	}
+	if todo.IsBlocked && ctx.Query(Force) != "true" {
+		ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", gin.H{
+			"message": "todo is blocked by open todos, complete them first or force it", "blocked_by": todo.BlockedBy,
+		}))
+		return
+	}
+
+	now := time.Now()
+	if err := h.Storage.Complete(ctx, &todo, &now, user.Id); err != nil {
+		if errors.Is(err, apperror.ErrConflict) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "the list does not allow this transition"))
+			return
+		}
+		apperror.AbortWithError(ctx, err, "failed to complete todo")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+func (h *Handler) Reopen(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.CompletedAt == nil {
+		ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+		return
+	}
+
+	if err := h.Storage.Complete(ctx, &todo, nil, user.Id); err != nil {
+		if errors.Is(err, apperror.ErrConflict) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "the list does not allow this transition"))
+			return
+		}
+		apperror.AbortWithError(ctx, err, "failed to reopen todo")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+// GetBlockers returns the todos blocking the todo, among the ones the user can see.
+func (h *Handler) GetBlockers(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, _, err := h.Storage.GetTodoById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	blockers, err := h.Storage.GetBlockers(ctx, todo.Id, user.Id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get blockers"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", blockers))
+}
+
+// AddBlocker makes the todo blocked by another todo of the workspace the user
+// can see. Dependencies closing a cycle are refused with 409.
+func (h *Handler) AddBlocker(ctx *gin.Context) {
+	var blockerDto AddBlockerDto
+	if err := ctx.ShouldBindJSON(&blockerDto); err != nil {
+		h.Log.Errorf("failed to bind blocker. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if blockerDto.BlockedById == todo.Id {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "a todo cannot block itself"))
+		return
+	}
+	if _, _, err = h.Storage.GetTodoById(ctx, blockerDto.BlockedById, user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get blocking todo")
+		return
+	}
+
+	if err = h.Storage.AddBlocker(ctx, &todo, blockerDto.BlockedById, user.Id); err != nil {
+		if errors.Is(err, apperror.ErrConflict) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "the dependency would create a cycle"))
+			return
+		}
+		apperror.AbortWithError(ctx, err, "failed to add blocker")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+func (h *Handler) RemoveBlocker(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if err = h.Storage.RemoveBlocker(ctx, &todo, ctx.Param(BlockerId), user.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to remove blocker")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+// createTodo checks that the user may add todos to the requested list and
+// creates the todo, keeping the id chosen by the client if there is one.
+func (h *Handler) createTodo(ctx context.Context, user users.User, todoDto CreateTodoDto) (Todo, error) {
+	if todoDto.ListId != nil {
+		role, err := h.listStorage.GetRole(ctx, *todoDto.ListId, user.Id, user.WorkspaceId)
+		if err != nil {
+			return Todo{}, err
+		}
+		if !role.CanEdit() {
+			return Todo{}, apperror.ErrForbidden
+		}
+	}
+
+	contexts, message := NormalizeContexts(todoDto.Contexts)
+	if message != "" {
+		return Todo{}, errors.New(message)
+	}
+	todo := Todo{
+		Title: todoDto.Title, UserId: user.Id, ListId: todoDto.ListId, WorkspaceId: user.WorkspaceId,
+		DueAt: todoDto.DueAt, Tags: todoDto.Tags, Priority: todoDto.Priority, Recurrence: todoDto.Recurrence,
+		ParentId: todoDto.ParentId, EstimatedMinutes: todoDto.EstimatedMinutes,
+		Contexts: contexts, NextAction: todoDto.NextAction,
+	}
+	if todoDto.Id != nil {
+		todo.Id = *todoDto.Id
+	}
+
+	if err := h.Storage.Create(ctx, &todo); err != nil {
+		if errors.Is(err, apperror.ErrConflict) && !h.ownsTodo(ctx, todo.Id, user) {
+			// the id of a todo of someone else must not reveal that it exists
+			return Todo{}, apperror.ErrNotFound
+		}
+		return Todo{}, err
+	}
+	return todo, nil
+}
+
+// ownsTodo reports whether the user created the todo, the case of a create
+// being retried with its client id.
+func (h *Handler) ownsTodo(ctx context.Context, id string, user users.User) bool {
+	todo, _, err := h.Storage.GetTodoById(ctx, id, user.Id, user.WorkspaceId)
+	return err == nil && todo.UserId == user.Id
+}
+
+func (h *Handler) updateTodo(ctx context.Context, user users.User, changes Todo) (Todo, error) {
+	todo, err := h.editableTodo(ctx, changes.Id, user)
+	if err != nil {
+		return Todo{}, err
+	}
+	todo.Title, todo.DueAt, todo.Tags = changes.Title, changes.DueAt, changes.Tags
+	todo.Priority, todo.Recurrence = changes.Priority, changes.Recurrence
+	todo.EstimatedMinutes = changes.EstimatedMinutes
+	contexts, message := NormalizeContexts(changes.Contexts)
+	if message != "" {
+		return Todo{}, errors.New(message)
+	}
+	todo.Contexts, todo.NextAction = contexts, changes.NextAction
+
+	if err = h.Storage.Update(ctx, &todo, user.Id); err != nil {
+		return Todo{}, err
+	}
+	return todo, nil
+}
+
+func (h *Handler) deleteTodo(ctx context.Context, user users.User, id string) error {
+	if _, err := h.editableTodo(ctx, id, user); err != nil {
+		return err
+	}
+	return h.Storage.Delete(ctx, id, user.Id, user.WorkspaceId)
+}
+
+// editableTodo loads the todo and checks that the user may change it. Todos the
+// user cannot see are apperror.ErrNotFound, read-only access is apperror.ErrForbidden.
+func (h *Handler) editableTodo(ctx context.Context, id string, user users.User) (Todo, error) {
+	todo, role, err := h.Storage.GetTodoById(ctx, id, user.Id, user.WorkspaceId)
+	if err != nil {
+		return Todo{}, err
+	}
+	if !role.CanEdit() {
+		return Todo{}, apperror.ErrForbidden
+	}
+	return todo, nil
 }
@@ -0,0 +1,88 @@
+package todo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"todoproject/api/lists"
+	"todoproject/api/users"
+	"todoproject/api/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func newTestRouter(h *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	e := gin.New()
+	e.Use(func(ctx *gin.Context) {
+		ctx.Set(util.CURRENT_USER, users.User{Id: "user"})
+	})
+	e.GET(util.ApiV1+GetByIdUrl, h.GetById)
+	e.DELETE(util.ApiV1+RelativeTodoUrl, h.Delete)
+	return e
+}
+
+// A todo the caller cannot see answers 404 whatever the request, so that its
+// existence is not revealed. A todo the caller can only view answers 403 to
+// changes.
+func TestTodoAccess(t *testing.T) {
+	tests := []struct {
+		name        string
+		row         fakeRow
+		method      string
+		path        string
+		body        string
+		wantStatus  int
+		wantMessage string
+	}{
+		{
+			name: "get invisible", row: fakeRow{err: pgx.ErrNoRows},
+			method: http.MethodGet, path: util.ApiV1 + "/todo/other",
+			wantStatus: http.StatusNotFound, wantMessage: "not found",
+		},
+		{
+			name: "delete invisible", row: fakeRow{err: pgx.ErrNoRows},
+			method: http.MethodDelete, path: util.ApiV1 + RelativeTodoUrl, body: `{"todo_id":"other"}`,
+			wantStatus: http.StatusNotFound, wantMessage: "not found",
+		},
+		{
+			name: "get as viewer", row: fakeRow{id: "shared", role: lists.RoleViewer},
+			method: http.MethodGet, path: util.ApiV1 + "/todo/shared",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "delete as viewer", row: fakeRow{id: "shared", role: lists.RoleViewer},
+			method: http.MethodDelete, path: util.ApiV1 + RelativeTodoUrl, body: `{"todo_id":"shared"}`,
+			wantStatus: http.StatusForbidden, wantMessage: "not enough permissions",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := NewStorage(&fakeClient{row: tt.row}, nil, nil, quietLogger())
+			router := newTestRouter(NewHandler(storage, nil, nil, nil, quietLogger()))
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if tt.wantMessage == "" {
+				return
+			}
+			var response struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to read response %q: %v", recorder.Body.String(), err)
+			}
+			if response.Status != "fail" || response.Message != tt.wantMessage {
+				t.Errorf("response = %+v, want fail with %s", response, tt.wantMessage)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package todo
+
+import "time"
+
+// Dates of the planning features are days of the user's calendar: a time is
+// first moved to the user's location, whose midnight starts the day.
+
+const DateLayout = "2006-01-02"
+
+// StartOfDay returns the midnight starting the day of t in its location.
+func StartOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// StartOfWeek returns the midnight starting the Monday of the week of t.
+func StartOfWeek(t time.Time) time.Time {
+	day := StartOfDay(t)
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
+
+// LocalDate returns the day of t in the location as a DateLayout string.
+func LocalDate(t time.Time, location *time.Location) string {
+	return t.In(location).Format(DateLayout)
+}
+
+// ParseLocalDate returns the midnight starting the day in the location.
+func ParseLocalDate(date string, location *time.Location) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, date, location)
+}
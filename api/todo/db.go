@@ -2,28 +2,322 @@ package todo
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
+	"time"
+	"todoproject/api/activity"
+	"todoproject/api/lists"
+	"todoproject/api/notifications"
+	"todoproject/apperror"
 	"todoproject/db"
 )
 
-var QueryGetAll = `SELECT * FROM todo WHERE user_id = $1;`
-var QueryGetTodoById = `SELECT * FROM todo WHERE id = $1 and user_id = $2`
-var QueryCreate = `INSERT INTO todo (title, user_id) VALUES ($1, $2) RETURNING id`
-var QueryUpdate = `UPDATE todo SET title = $1 WHERE id = $2 AND user_id = $3`
-var QueryDelete = `DELETE FROM todo WHERE id = $1 AND user_id = $2`
+// uniqueViolation is the Postgres error code of a duplicate key, raised when a
+// client retries the create of a todo whose id it generated itself.
+const uniqueViolation = "23505"
+
+const todoColumns = `t.id, t.title, t.user_id, t.list_id, t.assignee_id, t.workspace_id, t.due_at, t.tags, t.priority, t.recurrence, t.created_at, t.completed_at, t.parent_id, t.estimated_minutes, t.status_id, t.contexts, t.next_action, t.waiting_for, t.follow_up_at, ` + blockersColumn + `, ` + customFieldsColumn
+
+// blockersColumn is the JSON array of the todos blocking t.
+const blockersColumn = `COALESCE((SELECT json_agg(json_build_object('id', b.id, 'title', b.title, 'completed_at', b.completed_at)
+	ORDER BY b.created_at) FROM todo_dependencies d JOIN todo b ON b.id = d.blocked_by_id WHERE d.todo_id = t.id), '[]')`
+
+// customFieldsColumn is the JSON object of the custom field values of t.
+const customFieldsColumn = `COALESCE((SELECT jsonb_object_agg(fv.field_id, fv.value) FROM todo_field_values fv
+	WHERE fv.todo_id = t.id), '{}')`
+
+// Every query authorizes through the todo_role() and list_role() SQL functions,
+// see pgconsole/create_role_functions.sql: todos without a list are private to
+// their author, todos on a list follow the list membership. Queries are scoped
+// to the active workspace, a null workspace being the user's personal space.
+var QueryGetAll = `SELECT ` + todoColumns + ` FROM todo t
+	WHERE t.workspace_id IS NOT DISTINCT FROM $2 AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL AND ` + filterConditions + filterOrder
+var QueryCount = `SELECT count(*) FROM todo t
+	WHERE t.workspace_id IS NOT DISTINCT FROM $2 AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL AND ` + filterConditions
+
+// filterConditions evaluates a Filter, see Filter.args for the parameters.
+const filterConditions = `($3::uuid IS NULL OR t.list_id = $3)
+	AND ($4::varchar[] IS NULL OR t.tags @> $4)
+	AND ($5::varchar IS NULL OR t.priority = $5)
+	AND ($6::boolean IS NULL OR (t.completed_at IS NOT NULL) = $6)
+	AND ($7::uuid IS NULL OR t.assignee_id = $7)
+	AND ($8::timestamptz IS NULL OR t.due_at >= $8)
+	AND ($9::timestamptz IS NULL OR t.due_at < $9)
+	AND ($10::boolean IS NULL OR (t.due_at IS NULL) = $10)
+	AND ($11::varchar IS NULL OR strpos(lower(t.title), lower($11)) > 0)
+	AND ($12::uuid IS NULL OR t.status_id = $12)
+	AND ($13::boolean IS NULL OR EXISTS (SELECT 1 FROM todo_dependencies d JOIN todo b ON b.id = d.blocked_by_id
+		WHERE d.todo_id = t.id AND b.completed_at IS NULL) = $13)
+	AND ($14::jsonb IS NULL OR NOT EXISTS (SELECT 1 FROM jsonb_to_recordset($14) AS c(field_id uuid, op text, value jsonb)
+		WHERE NOT CASE c.op
+			WHEN 'unset' THEN NOT EXISTS (SELECT 1 FROM todo_field_values fv WHERE fv.todo_id = t.id AND fv.field_id = c.field_id)
+			ELSE EXISTS (SELECT 1 FROM todo_field_values fv WHERE fv.todo_id = t.id AND fv.field_id = c.field_id AND CASE c.op
+				WHEN 'set' THEN true
+				WHEN 'eq' THEN fv.value = c.value
+				WHEN 'contains' THEN fv.value @> c.value
+				WHEN 'gt' THEN fv.value > c.value
+				WHEN 'gte' THEN fv.value >= c.value
+				WHEN 'lt' THEN fv.value < c.value
+				WHEN 'lte' THEN fv.value <= c.value
+			END)
+		END))
+	AND ($15::boolean OR NOT EXISTS (SELECT 1 FROM todo_plans p
+		WHERE p.todo_id = t.id AND p.user_id = $1 AND p.snoozed_until > now()))
+	AND ($16::varchar IS NULL OR $16 = ANY(t.contexts))
+	AND ($17::boolean IS NULL OR t.next_action = $17)
+	AND ($18::boolean IS NULL OR (t.waiting_for IS NOT NULL) = $18)`
+
+// filterOrder sorts by the key $19, the custom field $20 for the "field" key,
+// descending when $21. Each key type needs its own pair of sort expressions.
+const filterOrder = ` ORDER BY
+	CASE WHEN NOT $21::boolean THEN CASE $19::varchar WHEN 'due_at' THEN t.due_at WHEN 'created_at' THEN t.created_at END END ASC NULLS LAST,
+	CASE WHEN $21 THEN CASE $19 WHEN 'due_at' THEN t.due_at WHEN 'created_at' THEN t.created_at END END DESC NULLS LAST,
+	CASE WHEN NOT $21 AND $19 = 'title' THEN lower(t.title) END ASC NULLS LAST,
+	CASE WHEN $21 AND $19 = 'title' THEN lower(t.title) END DESC NULLS LAST,
+	CASE WHEN NOT $21 AND $19 = 'priority' THEN array_position(ARRAY['low', 'medium', 'high']::varchar[], t.priority) END ASC NULLS LAST,
+	CASE WHEN $21 AND $19 = 'priority' THEN array_position(ARRAY['low', 'medium', 'high']::varchar[], t.priority) END DESC NULLS LAST,
+	CASE WHEN NOT $21 AND $19 = 'field' THEN (SELECT fv.value FROM todo_field_values fv WHERE fv.todo_id = t.id AND fv.field_id = $20::uuid) END ASC NULLS LAST,
+	CASE WHEN $21 AND $19 = 'field' THEN (SELECT fv.value FROM todo_field_values fv WHERE fv.todo_id = t.id AND fv.field_id = $20::uuid) END DESC NULLS LAST,
+	t.created_at, t.id`
+
+var QueryGetAssigned = `SELECT ` + todoColumns + ` FROM todo t
+	WHERE t.assignee_id = $1 AND t.workspace_id IS NOT DISTINCT FROM $2 AND list_role(t.list_id, $1) IS NOT NULL`
+var QueryGetTodoById = `SELECT ` + todoColumns + `, r.role FROM todo t
+	CROSS JOIN LATERAL (SELECT todo_role(t.list_id, t.user_id, $2) AS role) r
+	WHERE t.id = $1 AND t.workspace_id IS NOT DISTINCT FROM $3 AND r.role IS NOT NULL`
+var QueryGetByIds = `SELECT ` + todoColumns + ` FROM todo t
+	WHERE t.id = ANY($1) AND todo_role(t.list_id, t.user_id, $2) IS NOT NULL`
+var QueryCreate = `INSERT INTO todo (id, title, user_id, list_id, workspace_id, due_at, tags, priority, recurrence, parent_id, estimated_minutes,
+		contexts, next_action, status_id)
+	SELECT COALESCE($5::uuid, gen_random_uuid()), $1, $2, $3, $4, $6, COALESCE($7, '{}'), $8, $9, $10, $11, COALESCE($12, '{}'), $13,
+		(SELECT s.id FROM list_statuses s WHERE s.list_id = $3 ORDER BY s.position LIMIT 1)
+	WHERE ($3::uuid IS NULL OR EXISTS (SELECT 1 FROM lists l WHERE l.id = $3
+		AND l.workspace_id IS NOT DISTINCT FROM $4 AND list_role(l.id, $2) IN ('owner', 'admin', 'editor')))
+	AND ($10::uuid IS NULL OR EXISTS (SELECT 1 FROM todo p WHERE p.id = $10 AND p.list_id IS NOT DISTINCT FROM $3
+		AND p.workspace_id IS NOT DISTINCT FROM $4 AND ($3::uuid IS NOT NULL OR p.user_id = $2)))
+	RETURNING id, created_at, status_id`
+var QueryUpdate = `UPDATE todo t SET title = $1, due_at = $5, tags = COALESCE($6, '{}'),
+	priority = $7, recurrence = $8, estimated_minutes = $9, contexts = COALESCE($10, '{}'), next_action = $11 WHERE t.id = $2 AND t.workspace_id IS NOT DISTINCT FROM $4
+	AND todo_role(t.list_id, t.user_id, $3) IN ('owner', 'admin', 'editor')
+	RETURNING ` + todoColumns
+
+// QueryComplete also moves todos of a list with a workflow to its terminal
+// status, or back to its first open status when reopened. Todos already
+// completed, or already open when reopened, are left alone.
+var QueryComplete = `UPDATE todo t SET completed_at = $2, status_id = COALESCE((SELECT s.id FROM list_statuses s
+		WHERE s.list_id = t.list_id AND s.terminal = ($2::timestamptz IS NOT NULL) ORDER BY s.position LIMIT 1), t.status_id)
+	WHERE t.id = $1 AND (t.completed_at IS NULL) = ($2::timestamptz IS NOT NULL) RETURNING ` + todoColumns
+
+// QueryMoveToList moves the todo and its subtasks to the list $2, in the
+// status of the new list matching their completion. Assignees who cannot see
+// the new list are unassigned.
+var QueryMoveToList = `UPDATE todo t SET list_id = $2,
+	status_id = (SELECT s.id FROM list_statuses s WHERE s.list_id = $2 AND (s.terminal OR t.completed_at IS NULL)
+		ORDER BY s.terminal = (t.completed_at IS NOT NULL) DESC, s.position LIMIT 1),
+	assignee_id = CASE WHEN list_role($2, t.assignee_id) IS NOT NULL THEN t.assignee_id END
+	WHERE t.id = $1 OR t.parent_id = $1`
+
+// QueryDropFieldValues drops the custom field values of the todo and its
+// subtasks, fields belonging to a list.
+var QueryDropFieldValues = `DELETE FROM todo_field_values fv USING todo t
+	WHERE fv.todo_id = t.id AND (t.id = $1 OR t.parent_id = $1)`
+
+// QueryMove sets the status of the todo to one of its list, terminal statuses
+// completing it.
+var QueryMove = `UPDATE todo t SET status_id = s.id,
+	completed_at = CASE WHEN s.terminal THEN COALESCE(t.completed_at, now()) END
+	FROM list_statuses s WHERE t.id = $1 AND s.id = $2 AND s.list_id = t.list_id
+	RETURNING ` + todoColumns
+
+// QuerySyncCompletion completes the todos of the list $1 now in its terminal
+// status and reopens the ones that left it.
+var QuerySyncCompletion = `UPDATE todo t SET completed_at = CASE WHEN s.terminal THEN COALESCE(t.completed_at, now()) END
+	FROM list_statuses s WHERE s.id = t.status_id AND t.list_id = $1 AND s.terminal <> (t.completed_at IS NOT NULL)
+	RETURNING ` + todoColumns
+
+// QueryLockTransition locks the todo and tells whether its list allows moving
+// it to the status $2, or when $2 is null to the first status that is terminal
+// as $3 says, which is where completing and reopening put it. Lists that do not
+// enforce transitions allow every move, as do todos without a status yet. The
+// todo's completion is returned too.
+var QueryLockTransition = `SELECT t.completed_at IS NOT NULL,
+	NOT COALESCE(l.enforce_transitions, false) OR t.status_id IS NULL OR s.id IS NULL OR s.id = t.status_id
+		OR EXISTS (SELECT 1 FROM list_status_transitions tr WHERE tr.from_status_id = t.status_id AND tr.to_status_id = s.id)
+	FROM todo t LEFT JOIN lists l ON l.id = t.list_id
+	LEFT JOIN LATERAL (SELECT s.id FROM list_statuses s WHERE s.list_id = t.list_id
+		AND (s.id = $2 OR $2::uuid IS NULL AND s.terminal = $3) ORDER BY s.position LIMIT 1) s ON true
+	WHERE t.id = $1 FOR UPDATE OF t`
+var QuerySetFieldValue = `INSERT INTO todo_field_values (todo_id, field_id, value)
+	SELECT t.id, f.id, $3::jsonb FROM todo t JOIN list_fields f ON f.list_id = t.list_id WHERE t.id = $1 AND f.id = $2
+	ON CONFLICT (todo_id, field_id) DO UPDATE SET value = excluded.value`
+var QueryClearFieldValue = `DELETE FROM todo_field_values WHERE todo_id = $1 AND field_id = $2`
+
+// QueryTouch rewrites a todo unchanged so that the sync triggers see a change
+// stored outside of its row.
+var QueryTouch = `UPDATE todo t SET title = t.title WHERE t.id = $1 RETURNING ` + todoColumns
+var QueryGetMyDay = `SELECT ` + todoColumns + ` FROM todo t JOIN todo_plans p ON p.todo_id = t.id AND p.user_id = $1
+	WHERE p.my_day = to_date($3, 'YYYY-MM-DD') AND t.workspace_id IS NOT DISTINCT FROM $2 AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL
+	ORDER BY p.picked_at, t.id`
+var QuerySnooze = `INSERT INTO todo_plans AS p (todo_id, user_id, snoozed_until) VALUES ($1, $2, $3)
+	ON CONFLICT (todo_id, user_id) DO UPDATE SET snoozed_until = excluded.snoozed_until
+	RETURNING p.todo_id, p.snoozed_until, to_char(p.my_day, 'YYYY-MM-DD')`
+
+// QueryPick also ends the snooze, a todo picked for the day being wanted now.
+var QueryPick = `INSERT INTO todo_plans AS p (todo_id, user_id, my_day, picked_at) VALUES ($1, $2, to_date($3, 'YYYY-MM-DD'), now())
+	ON CONFLICT (todo_id, user_id) DO UPDATE SET my_day = excluded.my_day, picked_at = excluded.picked_at, snoozed_until = NULL
+	RETURNING p.todo_id, p.snoozed_until, to_char(p.my_day, 'YYYY-MM-DD')`
+var QueryUnsnooze = `UPDATE todo_plans p SET snoozed_until = NULL WHERE p.todo_id = $1 AND p.user_id = $2
+	RETURNING p.todo_id, p.snoozed_until, to_char(p.my_day, 'YYYY-MM-DD')`
+var QueryUnpick = `UPDATE todo_plans p SET my_day = NULL, picked_at = NULL WHERE p.todo_id = $1 AND p.user_id = $2
+	RETURNING p.todo_id, p.snoozed_until, to_char(p.my_day, 'YYYY-MM-DD')`
+
+// QueryDropPlan removes a plan left empty, including picks of a past day.
+var QueryDropPlan = `DELETE FROM todo_plans WHERE todo_id = $1 AND user_id = $2
+	AND (snoozed_until IS NULL OR snoozed_until <= now()) AND (my_day IS NULL OR my_day < to_date($3, 'YYYY-MM-DD'))`
+var QueryGetRaw = `SELECT ` + todoColumns + ` FROM todo t WHERE t.id = $1`
+
+// Roster changes and completions lock the todo row, so the turn is never
+// passed twice at once.
+var QueryLockTodo = `SELECT ` + todoColumns + ` FROM todo t WHERE t.id = $1 FOR UPDATE OF t`
+var QueryGetRoster = `SELECT user_id, skips FROM todo_rotation_members WHERE todo_id = $1 ORDER BY position`
+var QueryGetListRosters = `SELECT m.todo_id, m.user_id, m.skips FROM todo_rotation_members m JOIN todo t ON t.id = m.todo_id
+	WHERE t.list_id = $1 ORDER BY m.todo_id, m.position`
+var QueryDropRosterMembers = `DELETE FROM todo_rotation_members WHERE todo_id = $1 AND NOT (user_id = ANY($2::uuid[]))`
+var QueryPlaceRosterMembers = `INSERT INTO todo_rotation_members (todo_id, user_id, position)
+	SELECT $1, m.user_id, m.position - 1 FROM unnest($2::uuid[]) WITH ORDINALITY AS m(user_id, position)
+	ON CONFLICT (todo_id, user_id) DO UPDATE SET position = excluded.position`
+var QueryClearRoster = `DELETE FROM todo_rotation_members WHERE todo_id = $1`
+var QueryAddSkips = `UPDATE todo_rotation_members SET skips = skips + $3 WHERE todo_id = $1 AND user_id = $2`
+var QuerySetSkips = `UPDATE todo_rotation_members m SET skips = s.skips
+	FROM unnest($2::uuid[], $3::integer[]) AS s(user_id, skips) WHERE m.todo_id = $1 AND m.user_id = s.user_id`
+
+// QuerySwapMembers exchanges the positions of $2 and $3, each row reading
+// the other's position from before the update.
+var QuerySwapMembers = `UPDATE todo_rotation_members m SET position = o.position FROM todo_rotation_members o
+	WHERE m.todo_id = $1 AND o.todo_id = $1
+	AND ((m.user_id = $2 AND o.user_id = $3) OR (m.user_id = $3 AND o.user_id = $2))`
+
+// QuerySetWaiting puts the todo in waiting for $2, which is no next action
+// anymore, or takes it out of waiting when $2 is null.
+var QuerySetWaiting = `UPDATE todo t SET waiting_for = $2, follow_up_at = $3,
+	next_action = CASE WHEN $2::varchar IS NULL THEN t.next_action ELSE false END
+	WHERE t.id = $1 RETURNING ` + todoColumns
+
+// QueryAddTag and QuerySetPriority change a single column, leaving the rest of
+// the todo as concurrent writers left it. They return no row when the todo is
+// already as asked.
+var QueryAddTag = `UPDATE todo t SET tags = array_append(t.tags, $2::varchar)
+	WHERE t.id = $1 AND NOT ($2 = ANY(t.tags)) RETURNING ` + todoColumns
+var QuerySetPriority = `UPDATE todo t SET priority = $2
+	WHERE t.id = $1 AND t.priority IS DISTINCT FROM $2 RETURNING ` + todoColumns
+
+// reviewScope selects the todos of the user for their review: those assigned
+// to them and the unassigned ones they wrote. Each review query ends with the
+// limit of todos.
+const reviewScope = `SELECT ` + todoColumns + ` FROM todo t
+	WHERE t.workspace_id IS NOT DISTINCT FROM $2 AND todo_role(t.list_id, t.user_id, $1) IS NOT NULL
+	AND (t.assignee_id = $1 OR (t.assignee_id IS NULL AND t.user_id = $1)) AND `
+
+// reviewActive leaves out the todos the user snoozed.
+const reviewActive = ` AND t.completed_at IS NULL AND NOT EXISTS (SELECT 1 FROM todo_plans p
+	WHERE p.todo_id = t.id AND p.user_id = $1 AND p.snoozed_until > now())`
+
+// QueryReviewStale selects the open todos nothing happened to since $3.
+var QueryReviewStale = reviewScope + `COALESCE((SELECT max(a.created_at) FROM activity a WHERE a.todo_id = t.id), t.created_at) < $3` +
+	reviewActive + ` ORDER BY t.created_at LIMIT $4`
+var QueryReviewWaiting = reviewScope + `t.waiting_for IS NOT NULL AND t.completed_at IS NULL
+	ORDER BY t.follow_up_at ASC NULLS LAST, t.created_at LIMIT $3`
+
+// QueryReviewUnscheduled selects the open todos without a due date or a time
+// block ahead that are neither next actions nor waiting: the ones left to
+// decide on.
+var QueryReviewUnscheduled = reviewScope + `t.due_at IS NULL AND NOT t.next_action AND t.waiting_for IS NULL
+	AND NOT EXISTS (SELECT 1 FROM time_blocks b WHERE b.todo_id = t.id AND b.user_id = $1 AND b.ends_at > now())` +
+	reviewActive + ` ORDER BY t.created_at LIMIT $3`
+
+// QueryReviewCompleted selects the todos completed since $3.
+var QueryReviewCompleted = reviewScope + `t.completed_at >= $3 ORDER BY t.completed_at DESC LIMIT $4`
+var QueryGetBlockers = `SELECT ` + todoColumns + ` FROM todo t JOIN todo_dependencies d ON d.blocked_by_id = t.id
+	WHERE d.todo_id = $1 AND todo_role(t.list_id, t.user_id, $2) IS NOT NULL ORDER BY t.created_at`
+
+// Dependencies are written one at a time, so that two concurrent additions
+// cannot close a cycle that neither of them sees.
+var QueryLockDependencies = `SELECT pg_advisory_xact_lock(hashtext('todo_dependencies'))`
+
+// QueryCreatesCycle reports whether $1 is already reachable from $2 through
+// blocked_by edges, in which case $1 blocked by $2 would close a cycle.
+var QueryCreatesCycle = `WITH RECURSIVE chain(id) AS (
+		SELECT $2::uuid
+		UNION
+		SELECT d.blocked_by_id FROM todo_dependencies d JOIN chain c ON d.todo_id = c.id
+	) SELECT EXISTS (SELECT 1 FROM chain WHERE id = $1::uuid)`
+var QueryAddBlocker = `INSERT INTO todo_dependencies (todo_id, blocked_by_id, created_by) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+var QueryRemoveBlocker = `DELETE FROM todo_dependencies WHERE todo_id = $1 AND blocked_by_id = $2`
+var QueryAssign = `UPDATE todo SET assignee_id = $2 WHERE id = $1`
+var QueryCreateAssignment = `INSERT INTO todo_assignments (todo_id, assignee_id, assigned_by) VALUES ($1, $2, $3)`
+var QueryDelete = `DELETE FROM todo t WHERE t.id = $1 AND t.workspace_id IS NOT DISTINCT FROM $3
+	AND todo_role(t.list_id, t.user_id, $2) IN ('owner', 'admin', 'editor')
+	RETURNING ` + todoColumns
 
 type Storage struct {
-	db  db.Client
-	log *logrus.Logger
+	db            db.Client
+	notifications *notifications.Storage
+	activity      *activity.Storage
+	observers     []Observer
+	log           *logrus.Logger
 }
 
-func NewStorage(db db.Client, log *logrus.Logger) *Storage {
-	return &Storage{db: db, log: log}
+// Observer is told about the changes to todos once they are committed, in
+// the goroutine that made them and with its context. Changes the observer
+// makes through the storage are observed in turn.
+type Observer interface {
+	TodoChanged(ctx context.Context, activities []activity.Activity)
 }
 
-func (s *Storage) GetAllTodoByUserId(ctx context.Context, userId string) (t []Todo, err error) {
-	rows, err := s.db.Query(ctx, QueryGetAll, userId)
+func NewStorage(db db.Client, notifications *notifications.Storage, activity *activity.Storage, log *logrus.Logger) *Storage {
+	return &Storage{db: db, notifications: notifications, activity: activity, log: log}
+}
+
+// Observe registers an observer of the changes. Observers must be registered
+// before the storage is used.
+func (s *Storage) Observe(observer Observer) {
+	s.observers = append(s.observers, observer)
+}
+
+func (s *Storage) GetAllTodoByUserId(ctx context.Context, userId string, workspaceId, listId *string) (t []Todo, err error) {
+	return s.GetFiltered(ctx, userId, workspaceId, Filter{ListId: listId, IncludeSnoozed: true}, time.Now())
+}
+
+// GetFiltered returns the todos of the workspace the user can see that match
+// the filter, relative due windows being resolved around now.
+func (s *Storage) GetFiltered(ctx context.Context, userId string, workspaceId *string, filter Filter, now time.Time) ([]Todo, error) {
+	args := append([]interface{}{userId, workspaceId}, filter.args(now)...)
+	return s.queryTodos(ctx, QueryGetAll, append(args, filter.orderArgs()...)...)
+}
+
+// Count counts the todos GetFiltered would return.
+func (s *Storage) Count(ctx context.Context, userId string, workspaceId *string, filter Filter, now time.Time) (count int, err error) {
+	args := append([]interface{}{userId, workspaceId}, filter.args(now)...)
+	if err = s.db.QueryRow(ctx, QueryCount, args...).Scan(&count); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to count todos. due to error: %v", err)
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetAssignedTodos returns the todos assigned to the user across every list
+// of the workspace the user still has access to.
+func (s *Storage) GetAssignedTodos(ctx context.Context, userId string, workspaceId *string) ([]Todo, error) {
+	return s.queryTodos(ctx, QueryGetAssigned, userId, workspaceId)
+}
+
+func (s *Storage) queryTodos(ctx context.Context, query string, args ...interface{}) (t []Todo, err error) {
+	rows, err := s.db.Query(ctx, query, args...)
 	if err != nil {
 		s.TraceQueryError(err)
 		s.log.Errorf("failed to query all todos. due to error: %v", err)
@@ -32,7 +326,7 @@ func (s *Storage) GetAllTodoByUserId(ctx context.Context, userId string) (t []To
 	todos := make([]Todo, 0)
 	for rows.Next() {
 		var todo Todo
-		errScan := rows.Scan(&todo.Id, &todo.Title, &todo.UserId)
+		errScan := scanTodo(rows, &todo)
 		if errScan != nil {
 			s.log.Errorf("failed to scan todo. due to error: %v", errScan)
 			return nil, errScan
@@ -48,44 +342,900 @@ func (s *Storage) GetAllTodoByUserId(ctx context.Context, userId string) (t []To
 	return todos, nil
 }
 
-func (s *Storage) GetTodoById(ctx context.Context, id, userId string) (todo Todo, err error) {
-	errQuery := s.db.QueryRow(ctx, QueryGetTodoById, id, &todo.UserId).Scan(&todo.Id, &todo.Title, &todo.UserId)
+// GetByIds returns the todos of the given ids the user can still see,
+// regardless of their workspace.
+func (s *Storage) GetByIds(ctx context.Context, ids []string, userId string) ([]Todo, error) {
+	return s.queryTodos(ctx, QueryGetByIds, ids, userId)
+}
+
+// GetTodoById returns the todo together with the role the user holds on it.
+// Todos the user cannot see are reported as apperror.ErrNotFound.
+func (s *Storage) GetTodoById(ctx context.Context, id, userId string, workspaceId *string) (todo Todo, role lists.Role, err error) {
+	errQuery := scanTodo(s.db.QueryRow(ctx, QueryGetTodoById, id, userId, workspaceId), &todo, &role)
 	if errQuery != nil {
+		if errors.Is(errQuery, pgx.ErrNoRows) {
+			return Todo{}, "", apperror.ErrNotFound
+		}
 		s.TraceQueryError(errQuery)
 		s.log.Errorf("failed to get todo by id=(%s), due to error: %v", id, errQuery)
-		return Todo{}, errQuery
+		return Todo{}, "", errQuery
 	}
-	return todo, nil
+	return todo, role, nil
 }
 
 func (s *Storage) Create(ctx context.Context, todo *Todo) error {
-	if err := s.db.QueryRow(ctx, QueryCreate, todo.Title, todo.UserId).Scan(&todo.Id); err != nil {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
 		s.TraceQueryError(err)
 		return err
 	}
-	return nil
+	defer tx.Rollback(ctx)
+
+	a, err := s.Insert(ctx, tx, todo)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// Insert creates the todo inside the transaction of the caller, for changes
+// spanning several todos. The returned activity must be handed to Committed
+// once the transaction is committed.
+func (s *Storage) Insert(ctx context.Context, tx pgx.Tx, todo *Todo) (activity.Activity, error) {
+	var clientId *string
+	if todo.Id != "" {
+		clientId = &todo.Id
+	}
+	err := tx.QueryRow(ctx, QueryCreate, todo.Title, todo.UserId, todo.ListId, todo.WorkspaceId, clientId,
+		todo.DueAt, todo.Tags, todo.Priority, todo.Recurrence, todo.ParentId, todo.EstimatedMinutes, todo.Contexts, todo.NextAction).Scan(&todo.Id, &todo.CreatedAt, &todo.StatusId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return activity.Activity{}, apperror.ErrNotFound
+		}
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return activity.Activity{}, apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		return activity.Activity{}, err
+	}
+	if todo.BlockedBy == nil {
+		todo.BlockedBy = make([]Blocker, 0)
+	}
+	if todo.CustomFields == nil {
+		todo.CustomFields = make(map[string]interface{})
+	}
+	return s.recordActivity(ctx, tx, *todo, todo.UserId, activity.KindCreated)
 }
 
-func (s *Storage) Update(ctx context.Context, todo Todo) error {
-	_, errUpdate := s.db.Exec(ctx, QueryUpdate, todo.Title, todo.Id, todo.UserId)
+func (s *Storage) Update(ctx context.Context, todo *Todo, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	errUpdate := scanTodo(tx.QueryRow(ctx, QueryUpdate, todo.Title, todo.Id, userId, todo.WorkspaceId, todo.DueAt, todo.Tags,
+		todo.Priority, todo.Recurrence, todo.EstimatedMinutes, todo.Contexts, todo.NextAction), todo)
 	if errUpdate != nil {
+		if errors.Is(errUpdate, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
 		s.TraceQueryError(errUpdate)
 		s.log.Errorf("failed to update todo id=(%s). due to error: %v", todo.Id, errUpdate)
 		return errUpdate
 	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// Complete marks the todo as done at the given time, or reopens it when
+// completedAt is nil. Completing a rotating todo passes it to the next member
+// of its roster. A todo already in the state asked for is only reloaded. A
+// list enforcing transitions that does not allow the move
+// to its terminal status, or back to its first one, is apperror.ErrConflict.
+// Permissions are checked by the caller.
+func (s *Storage) Complete(ctx context.Context, todo *Todo, completedAt *time.Time, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = s.lockTransition(ctx, tx, todo.Id, nil, completedAt != nil); err != nil {
+		return err
+	}
+	if err = scanTodo(tx.QueryRow(ctx, QueryComplete, todo.Id, completedAt), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return scanTodo(tx.QueryRow(ctx, QueryGetRaw, todo.Id), todo)
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to complete todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	kind := activity.KindCompleted
+	if completedAt == nil {
+		kind = activity.KindReopened
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, kind)
+	if err != nil {
+		return err
+	}
+	activities := []activity.Activity{a}
+	if completedAt != nil {
+		passed, err := s.passTurn(ctx, tx, todo, userId)
+		if err != nil {
+			return err
+		}
+		activities = append(activities, passed...)
+	}
+	return s.commit(ctx, tx, activities...)
+}
+
+// Move puts the todo in a status of its list, apperror.ErrNotFound when the
+// status belongs to another list and apperror.ErrConflict when the list does
+// not allow the transition. Moving in or out of the terminal status completes
+// or reopens the todo as Complete does. Permissions are checked by the caller.
+func (s *Storage) Move(ctx context.Context, todo *Todo, statusId, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	completed, err := s.lockTransition(ctx, tx, todo.Id, &statusId, false)
+	if err != nil {
+		return err
+	}
+	if err = scanTodo(tx.QueryRow(ctx, QueryMove, todo.Id, statusId), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to move todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindMoved)
+	if err != nil {
+		return err
+	}
+	activities := []activity.Activity{a}
+	if completed != (todo.CompletedAt != nil) {
+		changed, err := s.completionChanged(ctx, tx, todo, userId)
+		if err != nil {
+			return err
+		}
+		activities = append(activities, changed...)
+	}
+	return s.commit(ctx, tx, activities...)
+}
+
+// SyncCompletion completes the todos of the list now in its terminal status and
+// reopens the ones that left it, inside the transaction that changed the
+// statuses. The activities are for Committed once the transaction is.
+func (s *Storage) SyncCompletion(ctx context.Context, tx pgx.Tx, listId, userId string) ([]activity.Activity, error) {
+	rows, err := tx.Query(ctx, QuerySyncCompletion, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to sync completion of list id=(%s). due to error: %v", listId, err)
+		return nil, err
+	}
+	todos := make([]Todo, 0)
+	for rows.Next() {
+		var todo Todo
+		if errScan := scanTodo(rows, &todo); errScan != nil {
+			s.log.Errorf("failed to scan todo. due to error: %v", errScan)
+			return nil, errScan
+		}
+		todos = append(todos, todo)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+
+	var activities []activity.Activity
+	for i := range todos {
+		changed, err := s.completionChanged(ctx, tx, &todos[i], userId)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, changed...)
+	}
+	return activities, nil
+}
+
+// completionChanged records that the todo was completed or reopened by a move
+// of its status, passing a completed rotating todo to its next member.
+func (s *Storage) completionChanged(ctx context.Context, tx pgx.Tx, todo *Todo, userId string) ([]activity.Activity, error) {
+	if todo.CompletedAt == nil {
+		a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindReopened)
+		return []activity.Activity{a}, err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindCompleted)
+	if err != nil {
+		return nil, err
+	}
+	passed, err := s.passTurn(ctx, tx, todo, userId)
+	if err != nil {
+		return nil, err
+	}
+	return append([]activity.Activity{a}, passed...), nil
+}
+
+// lockTransition locks the todo and checks its list allows the move, see
+// QueryLockTransition. It returns whether the todo was completed.
+func (s *Storage) lockTransition(ctx context.Context, tx pgx.Tx, id string, statusId *string, terminal bool) (completed bool, err error) {
+	var allowed bool
+	if err = tx.QueryRow(ctx, QueryLockTransition, id, statusId, terminal).Scan(&completed, &allowed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to lock todo id=(%s). due to error: %v", id, err)
+		return false, err
+	}
+	if !allowed {
+		return false, apperror.ErrConflict
+	}
+	return completed, nil
+}
+
+// MoveToList moves the todo with its subtasks to another list of its
+// workspace. Their custom field values are dropped and so are assignees who
+// cannot see the list. Permissions on both lists are checked by the caller.
+func (s *Storage) MoveToList(ctx context.Context, todo *Todo, listId, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryDropFieldValues, todo.Id); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, QueryMoveToList, todo.Id, listId); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to move todo id=(%s) to list id=(%s). due to error: %v", todo.Id, listId, err)
+		return err
+	}
+	if err = scanTodo(tx.QueryRow(ctx, QueryGetRaw, todo.Id), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// SetCustomFields writes the custom field values of the todo by field id, a
+// null value clearing the field. The values must have been validated against
+// the fields of the todo's list, fields of other lists are
+// apperror.ErrNotFound. Permissions are checked by the caller.
+func (s *Storage) SetCustomFields(ctx context.Context, todo *Todo, values map[string]json.RawMessage, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for fieldId, value := range values {
+		if len(value) == 0 || string(value) == "null" {
+			_, err = tx.Exec(ctx, QueryClearFieldValue, todo.Id, fieldId)
+		} else {
+			var tag pgconn.CommandTag
+			tag, err = tx.Exec(ctx, QuerySetFieldValue, todo.Id, fieldId, string(value))
+			if err == nil && tag.RowsAffected() == 0 {
+				return apperror.ErrNotFound
+			}
+		}
+		if err != nil {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to set field id=(%s) of todo id=(%s). due to error: %v", fieldId, todo.Id, err)
+			return err
+		}
+	}
+	if err = scanTodo(tx.QueryRow(ctx, QueryTouch, todo.Id), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// GetMyDay returns the todos the user picked for the date.
+func (s *Storage) GetMyDay(ctx context.Context, userId string, workspaceId *string, date string) ([]Todo, error) {
+	return s.queryTodos(ctx, QueryGetMyDay, userId, workspaceId, date)
+}
+
+// Snooze hides the todo from the user's listings until the given time, a nil
+// time ending the snooze.
+func (s *Storage) Snooze(ctx context.Context, id, userId string, until *time.Time, today string) (Plan, error) {
+	if until == nil {
+		return s.updatePlan(ctx, QueryUnsnooze, id, userId, today)
+	}
+	return s.updatePlan(ctx, QuerySnooze, id, userId, today, until)
+}
+
+// Pick adds the todo to the user's day, today being the user's date, or
+// removes it when pick is false.
+func (s *Storage) Pick(ctx context.Context, id, userId string, pick bool, today string) (Plan, error) {
+	if !pick {
+		return s.updatePlan(ctx, QueryUnpick, id, userId, today)
+	}
+	return s.updatePlan(ctx, QueryPick, id, userId, today, today)
+}
+
+// updatePlan runs a plan query taking the todo and user ids followed by args
+// and drops the plan when nothing is left in it. Removing from a missing plan
+// returns an empty one.
+func (s *Storage) updatePlan(ctx context.Context, query, id, userId, today string, args ...interface{}) (plan Plan, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return Plan{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, query, append([]interface{}{id, userId}, args...)...).Scan(&plan.TodoId, &plan.SnoozedUntil, &plan.MyDay)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Plan{TodoId: id}, nil
+	}
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to plan todo id=(%s). due to error: %v", id, err)
+		return Plan{}, err
+	}
+	if _, err = tx.Exec(ctx, QueryDropPlan, id, userId, today); err != nil {
+		s.TraceQueryError(err)
+		return Plan{}, err
+	}
+	return plan, tx.Commit(ctx)
+}
+
+// SetWaiting puts the todo in waiting for someone until the follow-up, or
+// takes it out of waiting when waitingFor is nil. Permissions are checked by
+// the caller.
+func (s *Storage) SetWaiting(ctx context.Context, todo *Todo, waitingFor *string, followUpAt *time.Time, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = scanTodo(tx.QueryRow(ctx, QuerySetWaiting, todo.Id, waitingFor, followUpAt), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to set waiting of todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// AddTag adds the tag to the todo unless it already has it. Permissions are
+// checked by the caller.
+func (s *Storage) AddTag(ctx context.Context, todo *Todo, tag, userId string) error {
+	return s.setColumn(ctx, QueryAddTag, todo, userId, tag)
+}
+
+// SetPriority changes the priority of the todo. Permissions are checked by
+// the caller.
+func (s *Storage) SetPriority(ctx context.Context, todo *Todo, priority *string, userId string) error {
+	return s.setColumn(ctx, QuerySetPriority, todo, userId, priority)
+}
+
+// setColumn runs a query changing a single column of the todo and records
+// the update, nothing is recorded when the query left the todo unchanged.
+func (s *Storage) setColumn(ctx context.Context, query string, todo *Todo, userId string, value interface{}) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = scanTodo(tx.QueryRow(ctx, query, todo.Id, value), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// GetWeeklyReview returns the user's todos to review: the open ones nothing
+// happened to since staleSince, those waiting for someone, those left
+// without a date or next action, and those completed since the start of the
+// review. Each part holds at most limit todos.
+func (s *Storage) GetWeeklyReview(ctx context.Context, userId string, workspaceId *string, since, staleSince time.Time, limit int) (review WeeklyReview, err error) {
+	if review.Stale, err = s.queryTodos(ctx, QueryReviewStale, userId, workspaceId, staleSince, limit); err != nil {
+		return WeeklyReview{}, err
+	}
+	if review.Waiting, err = s.queryTodos(ctx, QueryReviewWaiting, userId, workspaceId, limit); err != nil {
+		return WeeklyReview{}, err
+	}
+	if review.Unscheduled, err = s.queryTodos(ctx, QueryReviewUnscheduled, userId, workspaceId, limit); err != nil {
+		return WeeklyReview{}, err
+	}
+	if review.Completed, err = s.queryTodos(ctx, QueryReviewCompleted, userId, workspaceId, since, limit); err != nil {
+		return WeeklyReview{}, err
+	}
+	review.Since = since
+	return review, nil
+}
+
+// GetRotation returns the roster of the todo, empty when it does not rotate.
+func (s *Storage) GetRotation(ctx context.Context, todo Todo) (Rotation, error) {
+	members, err := s.getRoster(ctx, s.db, todo.Id)
+	if err != nil {
+		return Rotation{}, err
+	}
+	return newRotation(todo, members), nil
+}
+
+// GetListRotations returns the rosters of the todos of the list, by todo id.
+func (s *Storage) GetListRotations(ctx context.Context, listId string) (map[string][]RotationMember, error) {
+	rows, err := s.db.Query(ctx, QueryGetListRosters, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query rosters of list id=(%s). due to error: %v", listId, err)
+		return nil, err
+	}
+	rosters := make(map[string][]RotationMember)
+	for rows.Next() {
+		var todoId string
+		var member RotationMember
+		if errScan := rows.Scan(&todoId, &member.UserId, &member.Skips); errScan != nil {
+			s.log.Errorf("failed to scan roster member. due to error: %v", errScan)
+			return nil, errScan
+		}
+		rosters[todoId] = append(rosters[todoId], member)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return rosters, nil
+}
+
+// SetRoster makes the todo rotate through the members in the given order,
+// members kept from the previous roster keeping their skips. The todo goes to
+// the first member unless its assignee is on the roster. Permissions and
+// members are checked by the caller.
+func (s *Storage) SetRoster(ctx context.Context, todo *Todo, members []string, userId string) (Rotation, error) {
+	return s.changeRoster(ctx, todo, userId, func(tx pgx.Tx) (*string, error) {
+		if _, err := tx.Exec(ctx, QueryDropRosterMembers, todo.Id, members); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, QueryPlaceRosterMembers, todo.Id, members); err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if todo.AssigneeId != nil && *todo.AssigneeId == member {
+				return todo.AssigneeId, nil
+			}
+		}
+		return &members[0], nil
+	})
+}
+
+// ClearRoster stops the rotation of the todo, which stays with its assignee.
+func (s *Storage) ClearRoster(ctx context.Context, todo *Todo, userId string) error {
+	_, err := s.changeRoster(ctx, todo, userId, func(tx pgx.Tx) (*string, error) {
+		_, err := tx.Exec(ctx, QueryClearRoster, todo.Id)
+		return todo.AssigneeId, err
+	})
+	return err
+}
+
+// Skip passes the member over for their next turns. When the todo is theirs
+// the current turn is the first one skipped and the todo moves on at once.
+// Members not on the roster are apperror.ErrNotFound.
+func (s *Storage) Skip(ctx context.Context, todo *Todo, memberId string, turns int, userId string) (Rotation, error) {
+	return s.changeRoster(ctx, todo, userId, func(tx pgx.Tx) (*string, error) {
+		current := todo.AssigneeId != nil && *todo.AssigneeId == memberId
+		if current {
+			turns--
+		}
+		tag, err := tx.Exec(ctx, QueryAddSkips, todo.Id, memberId, turns)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() == 0 {
+			return nil, apperror.ErrNotFound
+		}
+		if !current {
+			return todo.AssigneeId, nil
+		}
+		return s.nextTurn(ctx, tx, *todo)
+	})
+}
+
+// Swap trades the places of two members of the roster, and so their turns:
+// when the todo is one's, it goes to the other. Members not on the roster are
+// apperror.ErrNotFound.
+func (s *Storage) Swap(ctx context.Context, todo *Todo, memberId, withMemberId string, userId string) (Rotation, error) {
+	return s.changeRoster(ctx, todo, userId, func(tx pgx.Tx) (*string, error) {
+		tag, err := tx.Exec(ctx, QuerySwapMembers, todo.Id, memberId, withMemberId)
+		if err != nil {
+			return nil, err
+		}
+		if tag.RowsAffected() != 2 {
+			return nil, apperror.ErrNotFound
+		}
+		if todo.AssigneeId != nil && *todo.AssigneeId == memberId {
+			return &withMemberId, nil
+		}
+		if todo.AssigneeId != nil && *todo.AssigneeId == withMemberId {
+			return &memberId, nil
+		}
+		return todo.AssigneeId, nil
+	})
+}
+
+// changeRoster runs a change of the roster with the todo locked and reloaded.
+// The change returns who the todo should be assigned to, which is recorded as
+// any other assignment when it differs.
+func (s *Storage) changeRoster(ctx context.Context, todo *Todo, userId string, change func(tx pgx.Tx) (*string, error)) (Rotation, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return Rotation{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err = scanTodo(tx.QueryRow(ctx, QueryLockTodo, todo.Id), todo); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Rotation{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		return Rotation{}, err
+	}
+	assigneeId, err := change(tx)
+	if err != nil {
+		if !errors.Is(err, apperror.ErrNotFound) {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to change roster of todo id=(%s). due to error: %v", todo.Id, err)
+		}
+		return Rotation{}, err
+	}
+
+	assigned := *todo
+	var activities []activity.Activity
+	if !sameAssignee(assigneeId, todo.AssigneeId) {
+		var a activity.Activity
+		if assigned, a, err = s.assign(ctx, tx, *todo, assigneeId, userId); err != nil {
+			return Rotation{}, err
+		}
+		activities = append(activities, a)
+	}
+	members, err := s.getRoster(ctx, tx, todo.Id)
+	if err != nil {
+		return Rotation{}, err
+	}
+	if err = s.commit(ctx, tx, activities...); err != nil {
+		return Rotation{}, err
+	}
+	*todo = assigned
+	return newRotation(assigned, members), nil
+}
+
+// passTurn gives the completed todo to the next member of its roster, if it
+// has one, reopened for their turn. It returns the activities of the
+// assignment and of the reopening.
+func (s *Storage) passTurn(ctx context.Context, tx pgx.Tx, todo *Todo, userId string) ([]activity.Activity, error) {
+	assigneeId, err := s.nextTurn(ctx, tx, *todo)
+	if err != nil || sameAssignee(assigneeId, todo.AssigneeId) {
+		return nil, err
+	}
+	assigned, assignment, err := s.assign(ctx, tx, *todo, assigneeId, userId)
+	if err != nil {
+		return nil, err
+	}
+	*todo = assigned
+	if err = scanTodo(tx.QueryRow(ctx, QueryComplete, todo.Id, nil), todo); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to reopen todo id=(%s). due to error: %v", todo.Id, err)
+		return nil, err
+	}
+	reopening, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindReopened)
+	if err != nil {
+		return nil, err
+	}
+	return []activity.Activity{assignment, reopening}, nil
+}
+
+// nextTurn returns the member whose turn follows the assignee's and uses up
+// the skips it passed over. A todo without roster stays with its assignee.
+func (s *Storage) nextTurn(ctx context.Context, tx pgx.Tx, todo Todo) (*string, error) {
+	members, err := s.getRoster(ctx, tx, todo.Id)
+	if err != nil || len(members) == 0 {
+		return todo.AssigneeId, err
+	}
+	next := nextMember(members, todo.AssigneeId)
+	ids, skips := make([]string, len(members)), make([]int, len(members))
+	for i, member := range members {
+		ids[i], skips[i] = member.UserId, member.Skips
+	}
+	if _, err = tx.Exec(ctx, QuerySetSkips, todo.Id, ids, skips); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to use skips of todo id=(%s). due to error: %v", todo.Id, err)
+		return nil, err
+	}
+	return &members[next].UserId, nil
+}
+
+func (s *Storage) getRoster(ctx context.Context, q db.Client, todoId string) ([]RotationMember, error) {
+	rows, err := q.Query(ctx, QueryGetRoster, todoId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query roster of todo id=(%s). due to error: %v", todoId, err)
+		return nil, err
+	}
+	members := make([]RotationMember, 0)
+	for rows.Next() {
+		var member RotationMember
+		if errScan := rows.Scan(&member.UserId, &member.Skips); errScan != nil {
+			s.log.Errorf("failed to scan roster member. due to error: %v", errScan)
+			return nil, errScan
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+// GetBlockers returns the todos blocking the todo that the user can see.
+func (s *Storage) GetBlockers(ctx context.Context, id, userId string) ([]Todo, error) {
+	return s.queryTodos(ctx, QueryGetBlockers, id, userId)
+}
+
+// AddBlocker makes the todo blocked by another one. Dependencies that would
+// close a cycle are apperror.ErrConflict, existing ones are left as they are.
+// Permissions are checked by the caller.
+func (s *Storage) AddBlocker(ctx context.Context, todo *Todo, blockerId, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryLockDependencies); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	var cycle bool
+	if err = tx.QueryRow(ctx, QueryCreatesCycle, todo.Id, blockerId).Scan(&cycle); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to check dependency cycle of todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	if cycle {
+		return apperror.ErrConflict
+	}
+	if _, err = tx.Exec(ctx, QueryAddBlocker, todo.Id, blockerId, userId); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to add blocker to todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	return s.commitDependency(ctx, tx, todo, userId)
+}
+
+func (s *Storage) RemoveBlocker(ctx context.Context, todo *Todo, blockerId, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, QueryRemoveBlocker, todo.Id, blockerId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to remove blocker of todo id=(%s). due to error: %v", todo.Id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return s.commitDependency(ctx, tx, todo, userId)
+}
+
+// commitDependency reloads the todo with its blockers and records the change
+// as an update, so that live clients refresh whether it is blocked. The todo
+// is touched for the syncing clients to see it too.
+func (s *Storage) commitDependency(ctx context.Context, tx pgx.Tx, todo *Todo, userId string) error {
+	if err := scanTodo(tx.QueryRow(ctx, QueryTouch, todo.Id), todo); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	a, err := s.recordActivity(ctx, tx, *todo, userId, activity.KindUpdated)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// Assign sets or clears the assignee of the todo. The change is recorded in
+// todo_assignments and the affected assignee is notified in the same transaction.
+func (s *Storage) Assign(ctx context.Context, todo *Todo, assigneeId *string, assignedBy string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	assigned, a, err := s.assign(ctx, tx, *todo, assigneeId, assignedBy)
+	if err != nil {
+		return err
+	}
+	if err = s.commit(ctx, tx, a); err != nil {
+		return err
+	}
+	*todo = assigned
 	return nil
 }
 
-func (s *Storage) Delete(ctx context.Context, id string, userId string) error {
-	_, errDelete := s.db.Exec(ctx, QueryDelete, id, userId)
+// assign runs Assign in the transaction and returns the assigned todo with
+// the activity to publish once committed.
+func (s *Storage) assign(ctx context.Context, tx pgx.Tx, todo Todo, assigneeId *string, assignedBy string) (Todo, activity.Activity, error) {
+	if _, err := tx.Exec(ctx, QueryAssign, todo.Id, assigneeId); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to assign todo id=(%s). due to error: %v", todo.Id, err)
+		return Todo{}, activity.Activity{}, err
+	}
+	if _, err := tx.Exec(ctx, QueryCreateAssignment, todo.Id, assigneeId, assignedBy); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to record assignment of todo id=(%s). due to error: %v", todo.Id, err)
+		return Todo{}, activity.Activity{}, err
+	}
+
+	assigned := todo
+	assigned.AssigneeId = assigneeId
+
+	activityKind := activity.KindAssigned
+	notified, kind := assigneeId, notifications.KindTodoAssigned
+	if assigneeId == nil {
+		activityKind = activity.KindUnassigned
+		notified, kind = todo.AssigneeId, notifications.KindTodoUnassigned
+	}
+	a, err := s.recordActivity(ctx, tx, assigned, assignedBy, activityKind)
+	if err != nil {
+		return Todo{}, activity.Activity{}, err
+	}
+	if notified != nil && *notified != assignedBy {
+		payload, _ := json.Marshal(AssignmentPayload{TodoId: todo.Id, Title: todo.Title, AssignedBy: assignedBy})
+		notification := notifications.Notification{UserId: *notified, WorkspaceId: todo.WorkspaceId, Kind: kind, Payload: payload}
+		if err = s.notifications.WithTx(tx).Create(ctx, &notification); err != nil {
+			return Todo{}, activity.Activity{}, err
+		}
+	}
+	return assigned, a, nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id string, userId string, workspaceId *string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var todo Todo
+	errDelete := scanTodo(tx.QueryRow(ctx, QueryDelete, id, userId, workspaceId), &todo)
 	if errDelete != nil {
+		if errors.Is(errDelete, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
 		s.TraceQueryError(errDelete)
 		s.log.Errorf("failed to delete todo id=(%s). due to error: %v", id, errDelete)
 		return errDelete
 	}
+	a, err := s.recordActivity(ctx, tx, todo, userId, activity.KindDeleted)
+	if err != nil {
+		return err
+	}
+	return s.commit(ctx, tx, a)
+}
+
+// scanTodo scans the todoColumns of a row into the todo, followed by the
+// extra columns of the query if any.
+func scanTodo(row pgx.Row, todo *Todo, extra ...interface{}) error {
+	dest := []interface{}{&todo.Id, &todo.Title, &todo.UserId, &todo.ListId, &todo.AssigneeId, &todo.WorkspaceId,
+		&todo.DueAt, &todo.Tags, &todo.Priority, &todo.Recurrence, &todo.CreatedAt, &todo.CompletedAt, &todo.ParentId, &todo.EstimatedMinutes,
+		&todo.StatusId, &todo.Contexts, &todo.NextAction, &todo.WaitingFor, &todo.FollowUpAt, &todo.BlockedBy, &todo.CustomFields}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	todo.IsBlocked = false
+	for _, blocker := range todo.BlockedBy {
+		if blocker.CompletedAt == nil {
+			todo.IsBlocked = true
+		}
+	}
+	return nil
+}
+
+// recordActivity writes the activity of a todo change inside the transaction
+// of the change itself, with a snapshot of the todo as payload.
+func (s *Storage) recordActivity(ctx context.Context, tx pgx.Tx, todo Todo, actorId, kind string) (activity.Activity, error) {
+	payload, err := json.Marshal(todo)
+	if err != nil {
+		return activity.Activity{}, err
+	}
+	a := activity.Activity{
+		WorkspaceId: todo.WorkspaceId,
+		ListId:      todo.ListId,
+		TodoId:      &todo.Id,
+		ActorId:     actorId,
+		Kind:        kind,
+		Payload:     payload,
+		Origin:      activity.OriginFromContext(ctx),
+	}
+	err = s.activity.WithTx(tx).Create(ctx, &a)
+	return a, err
+}
+
+// commit commits the transaction and only then hands its activities over,
+// see Committed.
+func (s *Storage) commit(ctx context.Context, tx pgx.Tx, activities ...activity.Activity) error {
+	if err := tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.Committed(ctx, activities...)
 	return nil
 }
 
+// Committed publishes the activities of a committed transaction to the live
+// subscribers, then tells the observers.
+func (s *Storage) Committed(ctx context.Context, activities ...activity.Activity) {
+	s.activity.Publish(activities...)
+	if len(activities) == 0 {
+		return
+	}
+	for _, observer := range s.observers {
+		observer.TodoChanged(ctx, activities)
+	}
+}
+
 func (s *Storage) TraceQueryError(err error) {
 	if pgErr, ok := err.(*pgconn.PgError); ok {
 		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
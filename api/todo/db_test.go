@@ -0,0 +1,84 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"todoproject/api/lists"
+	"todoproject/apperror"
+	"todoproject/db"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeClient answers every QueryRow with row, the other methods of db.Client
+// are not expected to be called.
+type fakeClient struct {
+	db.Client
+	row  pgx.Row
+	args [][]interface{}
+}
+
+func (c *fakeClient) QueryRow(_ context.Context, _ string, args ...interface{}) pgx.Row {
+	c.args = append(c.args, args)
+	return c.row
+}
+
+// fakeRow scans err, or else a todo of the given id on which the user holds
+// role: the id is the first column and the role the last one.
+type fakeRow struct {
+	err  error
+	id   string
+	role lists.Role
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = r.id
+	*dest[len(dest)-1].(*lists.Role) = r.role
+	return nil
+}
+
+func quietLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestGetTodoByIdNotFound(t *testing.T) {
+	workspaceId := "workspace"
+	tests := []struct {
+		name        string
+		err         error
+		wantErr     error
+		workspaceId *string
+	}{
+		{name: "invisible todo", err: pgx.ErrNoRows, wantErr: apperror.ErrNotFound},
+		{name: "invisible todo in workspace", err: pgx.ErrNoRows, wantErr: apperror.ErrNotFound, workspaceId: &workspaceId},
+		{name: "query failure", err: io.ErrUnexpectedEOF, wantErr: io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{row: fakeRow{err: tt.err}}
+			storage := NewStorage(client, nil, nil, quietLogger())
+
+			todo, role, err := storage.GetTodoById(context.Background(), "todo", "user", tt.workspaceId)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetTodoById() error = %v, want %v", err, tt.wantErr)
+			}
+			if errors.Is(err, apperror.ErrForbidden) {
+				t.Fatalf("GetTodoById() must not report an invisible todo as forbidden")
+			}
+			if todo.Id != "" || role != "" {
+				t.Errorf("GetTodoById() = %+v, %q, want zero values", todo, role)
+			}
+			if len(client.args) != 1 || client.args[0][1] != "user" || client.args[0][2] != tt.workspaceId {
+				t.Errorf("GetTodoById() queried with %v, want the user and workspace", client.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Relative due date windows of a Filter, read in the user's time zone.
+const (
+	DueOverdue   = "overdue"
+	DueToday     = "today"
+	DueTomorrow  = "tomorrow"
+	DueThisWeek  = "this_week"
+	DueNextWeek  = "next_week"
+	DueNext7Days = "next_7_days"
+	DueNone      = "none"
+)
+
+var dueWindows = map[string]bool{
+	DueOverdue: true, DueToday: true, DueTomorrow: true, DueThisWeek: true,
+	DueNextWeek: true, DueNext7Days: true, DueNone: true,
+}
+
+// Operators of a FieldCondition. Set and unset take no value, contains tests
+// a multi-select for an option and the comparisons order numbers and dates.
+const (
+	FieldSet      = "set"
+	FieldUnset    = "unset"
+	FieldEq       = "eq"
+	FieldContains = "contains"
+	FieldGt       = "gt"
+	FieldGte      = "gte"
+	FieldLt       = "lt"
+	FieldLte      = "lte"
+)
+
+var fieldOperators = map[string]bool{
+	FieldSet: true, FieldUnset: true, FieldEq: true, FieldContains: true,
+	FieldGt: true, FieldGte: true, FieldLt: true, FieldLte: true,
+}
+
+// Sort keys of a Filter, custom fields are sorted by with SortFieldPrefix
+// followed by the field id.
+const (
+	SortCreatedAt   = "created_at"
+	SortDueAt       = "due_at"
+	SortPriority    = "priority"
+	SortTitle       = "title"
+	SortFieldPrefix = "field:"
+)
+
+// MaxFieldConditions bounds the custom field conditions of a filter.
+const MaxFieldConditions = 20
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldCondition is a condition on the value of a custom field. Value is
+// compared as JSON, so it must have the type of the field's values.
+type FieldCondition struct {
+	FieldId string          `json:"field_id"`
+	Op      string          `json:"op"`
+	Value   json.RawMessage `json:"value,omitempty"`
+}
+
+// Filter selects todos for the listing and for smart lists. Unset fields do
+// not filter, tags must all be present.
+type Filter struct {
+	ListId     *string    `json:"list_id,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Priority   *string    `json:"priority,omitempty"`
+	Completed  *bool      `json:"completed,omitempty"`
+	Blocked    *bool      `json:"blocked,omitempty"`
+	AssigneeId *string    `json:"assignee_id,omitempty"`
+	StatusId   *string    `json:"status_id,omitempty"`
+	Search     *string    `json:"search,omitempty"`
+	Due        *string    `json:"due,omitempty"`
+	DueAfter   *time.Time `json:"due_after,omitempty"`
+	DueBefore  *time.Time `json:"due_before,omitempty"`
+	// Fields are conditions on custom field values, all of which must hold.
+	Fields []FieldCondition `json:"fields,omitempty"`
+	// IncludeSnoozed shows the todos the user snoozed, which are hidden until
+	// their snooze ends otherwise.
+	IncludeSnoozed bool `json:"include_snoozed,omitempty"`
+	// Context selects the todos that can be done in the @context, NextAction
+	// and Waiting the todos flagged as next actions and waiting for someone.
+	Context    *string `json:"context,omitempty"`
+	NextAction *bool   `json:"next_action,omitempty"`
+	Waiting    *bool   `json:"waiting,omitempty"`
+	// Sort is one of the sort keys, created_at by default, Desc reverses it.
+	// Todos without a value for the key come last either way.
+	Sort string `json:"sort,omitempty"`
+	Desc bool   `json:"desc,omitempty"`
+}
+
+// Validate returns why the filter cannot be evaluated, or an empty string.
+func (f Filter) Validate() string {
+	if f.Priority != nil && !Priorities[*f.Priority] {
+		return "invalid priority"
+	}
+	if f.Due != nil {
+		if !dueWindows[*f.Due] {
+			return fmt.Sprintf("invalid due window %q", *f.Due)
+		}
+		if f.DueAfter != nil || f.DueBefore != nil {
+			return "due cannot be combined with due_after or due_before"
+		}
+	}
+	if f.DueAfter != nil && f.DueBefore != nil && !f.DueAfter.Before(*f.DueBefore) {
+		return "due_after must be before due_before"
+	}
+	if f.Search != nil && len([]rune(*f.Search)) > 100 {
+		return "search is too long"
+	}
+	if f.Context != nil && !contextPattern.MatchString(*f.Context) {
+		return "invalid context"
+	}
+	if len(f.Fields) > MaxFieldConditions {
+		return fmt.Sprintf("at most %d field conditions are allowed", MaxFieldConditions)
+	}
+	for _, condition := range f.Fields {
+		if !uuidPattern.MatchString(condition.FieldId) {
+			return "invalid field id"
+		}
+		if !fieldOperators[condition.Op] {
+			return fmt.Sprintf("invalid field operator %q", condition.Op)
+		}
+		hasValue := len(condition.Value) > 0 && string(condition.Value) != "null"
+		if condition.Op == FieldSet || condition.Op == FieldUnset {
+			if hasValue {
+				return fmt.Sprintf("operator %s takes no value", condition.Op)
+			}
+		} else if !hasValue || !json.Valid(condition.Value) {
+			return fmt.Sprintf("operator %s needs a value", condition.Op)
+		}
+	}
+	switch f.Sort {
+	case "", SortCreatedAt, SortDueAt, SortPriority, SortTitle:
+	default:
+		if !strings.HasPrefix(f.Sort, SortFieldPrefix) || !uuidPattern.MatchString(strings.TrimPrefix(f.Sort, SortFieldPrefix)) {
+			return fmt.Sprintf("invalid sort %q", f.Sort)
+		}
+	}
+	return ""
+}
+
+// args returns the filter as the parameters $3 to $18 of filterConditions,
+// resolving the relative due window around now, whose location is the user's
+// time zone. Overdue only selects open todos unless completed is set.
+func (f Filter) args(now time.Time) []interface{} {
+	after, before, completed := f.DueAfter, f.DueBefore, f.Completed
+	var noDue *bool
+	if f.Due != nil {
+		today := StartOfDay(now)
+		window := func(from, to time.Time) {
+			after, before = &from, &to
+		}
+		switch *f.Due {
+		case DueOverdue:
+			before = &now
+			if completed == nil {
+				open := false
+				completed = &open
+			}
+		case DueToday:
+			window(today, today.AddDate(0, 0, 1))
+		case DueTomorrow:
+			window(today.AddDate(0, 0, 1), today.AddDate(0, 0, 2))
+		case DueThisWeek, DueNextWeek:
+			monday := StartOfWeek(now)
+			if *f.Due == DueNextWeek {
+				monday = monday.AddDate(0, 0, 7)
+			}
+			window(monday, monday.AddDate(0, 0, 7))
+		case DueNext7Days:
+			window(today, today.AddDate(0, 0, 7))
+		case DueNone:
+			none := true
+			noDue = &none
+		}
+	}
+
+	var tags []string
+	if len(f.Tags) > 0 {
+		tags = f.Tags
+	}
+	var fields *string
+	if len(f.Fields) > 0 {
+		// cannot fail, the values were checked to be valid JSON
+		b, _ := json.Marshal(f.Fields)
+		conditions := string(b)
+		fields = &conditions
+	}
+	return []interface{}{f.ListId, tags, f.Priority, completed, f.AssigneeId, after, before, noDue, f.Search, f.StatusId, f.Blocked, fields, f.IncludeSnoozed,
+		f.Context, f.NextAction, f.Waiting}
+}
+
+// orderArgs returns the sort of the filter as the parameters $19 to $21 of
+// filterOrder.
+func (f Filter) orderArgs() []interface{} {
+	key, fieldId := f.Sort, (*string)(nil)
+	if strings.HasPrefix(key, SortFieldPrefix) {
+		id := strings.TrimPrefix(key, SortFieldPrefix)
+		key, fieldId = "field", &id
+	}
+	if key == "" {
+		key = SortCreatedAt
+	}
+	return []interface{}{key, fieldId, f.Desc}
+}
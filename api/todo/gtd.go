@@ -0,0 +1,142 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"todoproject/api/users"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MaxContexts         = 10
+	MaxWaitingForLength = 100
+	// The weekly review covers the last ReviewDays days of the user's
+	// calendar, todos are stale after StaleDays without activity and each
+	// part of the review holds at most MaxReviewItems todos.
+	ReviewDays     = 7
+	StaleDays      = 14
+	MaxReviewItems = 100
+)
+
+var (
+	WaitingUrl      = fmt.Sprintf("/todo/:%s/waiting", Id)
+	WeeklyReviewUrl = "/review/weekly"
+)
+
+// contextPattern matches a normalized @context.
+var contextPattern = regexp.MustCompile(`^@[\p{Ll}\p{Lo}\p{N}_-]{1,49}$`)
+
+// SetWaiting puts the todo in waiting for someone, optionally with a
+// follow-up. Waiting todos are no next actions.
+func (h *Handler) SetWaiting(ctx *gin.Context) {
+	var waitingDto WaitingDto
+	if err := ctx.ShouldBindJSON(&waitingDto); err != nil {
+		h.Log.Errorf("failed to bind waiting. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	waitingFor := strings.TrimSpace(waitingDto.WaitingFor)
+	if waitingFor == "" || len([]rune(waitingFor)) > MaxWaitingForLength {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "waiting_for must have between 1 and 100 characters"))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	followUpAt := waitingDto.FollowUpAt
+	if waitingDto.FollowUp != nil {
+		if followUpAt != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "give either follow_up_at or follow_up"))
+			return
+		}
+		date, err := ParseLocalDate(*waitingDto.FollowUp, user.Location())
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid follow_up date"))
+			return
+		}
+		followUpAt = &date
+	}
+
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if err = h.Storage.SetWaiting(ctx, &todo, &waitingFor, followUpAt, user.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to set waiting")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+func (h *Handler) ClearWaiting(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.WaitingFor == nil {
+		ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+		return
+	}
+	if err = h.Storage.SetWaiting(ctx, &todo, nil, nil, user.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to clear waiting")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", todo))
+}
+
+// GetWeeklyReview bundles the user's todos to go through in a weekly review:
+// the open ones without activity for StaleDays days, the ones waiting for
+// someone by follow-up, the ones without a due date, time block or next
+// action flag, and the ones completed over the last ReviewDays days. Only the
+// todos assigned to the user and the unassigned ones they wrote are reviewed,
+// snoozed todos are left out until their snooze ends.
+func (h *Handler) GetWeeklyReview(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	today := StartOfDay(time.Now().In(location))
+	review, err := h.Storage.GetWeeklyReview(ctx, user.Id, user.WorkspaceId,
+		today.AddDate(0, 0, -ReviewDays), today.AddDate(0, 0, -StaleDays), MaxReviewItems)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get weekly review"))
+		return
+	}
+	review.Timezone = location.String()
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", review))
+}
+
+// NormalizeContexts lowercases the contexts and prefixes them with @ where
+// missing, dropping duplicates. It returns why they are refused, or an empty
+// string.
+func NormalizeContexts(contexts []string) ([]string, string) {
+	normalized := make([]string, 0, len(contexts))
+	seen := make(map[string]bool, len(contexts))
+	for _, context := range contexts {
+		context = normalizeContext(context)
+		if !contextPattern.MatchString(context) {
+			return nil, fmt.Sprintf("invalid context %q, contexts are single words as @home", context)
+		}
+		if !seen[context] {
+			seen[context] = true
+			normalized = append(normalized, context)
+		}
+	}
+	if len(normalized) > MaxContexts {
+		return nil, fmt.Sprintf("a todo cannot have more than %d contexts", MaxContexts)
+	}
+	return normalized, ""
+}
+
+func normalizeContext(context string) string {
+	context = strings.ToLower(strings.TrimSpace(context))
+	if !strings.HasPrefix(context, "@") {
+		context = "@" + context
+	}
+	return context
+}
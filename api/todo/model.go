@@ -1,15 +1,193 @@
 package todo
 
+import (
+	"time"
+	"todoproject/api/activity"
+	"todoproject/quickadd"
+)
+
 type Todo struct {
-	Id     string `json:"id"`
-	Title  string `json:"title"`
-	UserId string `json:"user_id"`
+	Id          string     `json:"id"`
+	Title       string     `json:"title"`
+	UserId      string     `json:"user_id"`
+	ListId      *string    `json:"list_id"`
+	AssigneeId  *string    `json:"assignee_id"`
+	WorkspaceId *string    `json:"workspace_id"`
+	DueAt       *time.Time `json:"due_at"`
+	Tags        []string   `json:"tags"`
+	Priority    *string    `json:"priority"`
+	Recurrence  *string    `json:"recurrence"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	// ParentId is set on subtasks, which live on the list of their parent.
+	ParentId *string `json:"parent_id"`
+	// EstimatedMinutes is how long the todo is expected to take.
+	EstimatedMinutes *int `json:"estimated_minutes"`
+	// StatusId is the workflow status of todos on a list that has one.
+	StatusId *string `json:"status_id"`
+	// BlockedBy are the todos that must be done first, the todo IsBlocked
+	// while one of them is open.
+	BlockedBy []Blocker `json:"blocked_by"`
+	IsBlocked bool      `json:"is_blocked"`
+	// CustomFields are the values of the custom fields of the list by field id.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+	// Contexts are the @contexts the todo can be done in, NextAction flags it
+	// as the next step to take.
+	Contexts   []string `json:"contexts"`
+	NextAction bool     `json:"next_action"`
+	// WaitingFor is who the todo waits on, to be followed up at FollowUpAt.
+	WaitingFor *string    `json:"waiting_for"`
+	FollowUpAt *time.Time `json:"follow_up_at"`
 }
 
+type Blocker struct {
+	Id          string     `json:"id"`
+	Title       string     `json:"title"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Priorities are the values accepted for Todo.Priority.
+var Priorities = map[string]bool{"low": true, "medium": true, "high": true}
+
 type CreateTodoDto struct {
-	Title string `json:"title"`
+	Id               *string    `json:"id"`
+	Title            string     `json:"title"`
+	ListId           *string    `json:"list_id"`
+	DueAt            *time.Time `json:"due_at"`
+	Tags             []string   `json:"tags"`
+	Priority         *string    `json:"priority"`
+	Recurrence       *string    `json:"recurrence"`
+	ParentId         *string    `json:"parent_id"`
+	EstimatedMinutes *int       `json:"estimated_minutes"`
+	Contexts         []string   `json:"contexts"`
+	NextAction       bool       `json:"next_action"`
+}
+
+type QuickAddDto struct {
+	Text   string  `json:"text"`
+	ListId *string `json:"list_id"`
+}
+
+// QuickAddResult is the todo created from a quick-add line with what was
+// recognized in it, the todo is nil on a dry run.
+type QuickAddResult struct {
+	Todo   *Todo           `json:"todo"`
+	Parsed quickadd.Result `json:"parsed"`
+}
+
+type AddBlockerDto struct {
+	BlockedById string `json:"blocked_by_id" binding:"required"`
+}
+
+// Plan is the user's own planning of a todo: hidden until SnoozedUntil, or
+// picked for MyDay, a date of the user's calendar.
+type Plan struct {
+	TodoId       string     `json:"todo_id"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+	MyDay        *string    `json:"my_day"`
+}
+
+// SnoozeDto gives either the time the snooze ends or a date of the user's
+// calendar, whose midnight ends it.
+type SnoozeDto struct {
+	Until *time.Time `json:"until"`
+	Date  *string    `json:"date"`
+}
+
+// Today is the daily planning view. Each todo appears once: picks first, then
+// open todos due before today, then open todos due today.
+type Today struct {
+	Date     string `json:"date"`
+	Timezone string `json:"timezone"`
+	MyDay    []Todo `json:"my_day"`
+	Overdue  []Todo `json:"overdue"`
+	DueToday []Todo `json:"due_today"`
 }
 
 type DeleteTodoDto struct {
 	TodoId string `json:"todo_id"`
 }
+
+type AssignTodoDto struct {
+	AssigneeId string `json:"assignee_id" binding:"required"`
+}
+
+type AssignmentPayload struct {
+	TodoId     string `json:"todo_id"`
+	Title      string `json:"title"`
+	AssignedBy string `json:"assigned_by"`
+}
+
+// WsCommand is a change sent by a client over the WebSocket. ClientId is
+// echoed in the reply so the client can match acknowledgements to commands,
+// Todo.Id is generated by the client for creates.
+type WsCommand struct {
+	Type     string        `json:"type"`
+	ClientId string        `json:"client_id"`
+	Todo     CreateTodoDto `json:"todo"`
+}
+
+// WsMessage is sent by the server: an "ack" or "error" reply to a command, or a
+// "todo.<kind>" broadcast of a change made by another session.
+type WsMessage struct {
+	Type     string             `json:"type"`
+	ClientId string             `json:"client_id,omitempty"`
+	Todo     *Todo              `json:"todo,omitempty"`
+	Status   int                `json:"status,omitempty"`
+	Message  string             `json:"message,omitempty"`
+	Activity *activity.Activity `json:"activity,omitempty"`
+}
+
+// Rotation is the roster of a todo passed from member to member each time it
+// is completed. Upcoming are the members whose turns follow the current
+// assignee's, in order and with their skips applied.
+type Rotation struct {
+	TodoId     string           `json:"todo_id"`
+	Title      string           `json:"title"`
+	AssigneeId *string          `json:"assignee_id"`
+	Members    []RotationMember `json:"members"`
+	Upcoming   []string         `json:"upcoming"`
+}
+
+// RotationMember is a member of a roster, Skips being the turns they will be
+// passed over for.
+type RotationMember struct {
+	UserId string `json:"user_id"`
+	Skips  int    `json:"skips"`
+}
+
+type RosterDto struct {
+	Members []string `json:"members" binding:"required"`
+}
+
+// SkipDto skips the member's next turns, starting with the current one when
+// the todo is theirs.
+type SkipDto struct {
+	UserId string `json:"user_id" binding:"required"`
+	Turns  *int   `json:"turns"`
+}
+
+// SwapDto trades the places of two members of the roster.
+type SwapDto struct {
+	UserId     string `json:"user_id" binding:"required"`
+	WithUserId string `json:"with_user_id" binding:"required"`
+}
+
+// WaitingDto puts a todo in waiting for someone. The follow-up is either a
+// time or a date of the user's calendar, whose midnight it is.
+type WaitingDto struct {
+	WaitingFor string     `json:"waiting_for" binding:"required"`
+	FollowUpAt *time.Time `json:"follow_up_at"`
+	FollowUp   *string    `json:"follow_up"`
+}
+
+// WeeklyReview bundles the user's todos to go through in a GTD weekly
+// review, see Handler.GetWeeklyReview.
+type WeeklyReview struct {
+	Since       time.Time `json:"since"`
+	Timezone    string    `json:"timezone"`
+	Stale       []Todo    `json:"stale"`
+	Waiting     []Todo    `json:"waiting"`
+	Unscheduled []Todo    `json:"unscheduled"`
+	Completed   []Todo    `json:"completed"`
+}
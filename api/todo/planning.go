@@ -0,0 +1,138 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/users"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxSnooze bounds how far ahead a todo can be snoozed.
+const MaxSnooze = 366 * 24 * time.Hour
+
+var (
+	TodayUrl  = "/today"
+	SnoozeUrl = fmt.Sprintf("/todo/:%s/snooze", Id)
+	MyDayUrl  = fmt.Sprintf("/todo/:%s/my-day", Id)
+)
+
+// GetToday returns the user's plan for the current day of their calendar:
+// the todos they picked, the open todos due before today and the open todos
+// due today. Snoozed todos are left out unless picked.
+func (h *Handler) GetToday(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	now := time.Now().In(location)
+	start, open := StartOfDay(now), false
+	end := start.AddDate(0, 0, 1)
+
+	today := Today{Date: LocalDate(now, location), Timezone: location.String()}
+	var err error
+	if today.MyDay, err = h.Storage.GetMyDay(ctx, user.Id, user.WorkspaceId, today.Date); err == nil {
+		today.Overdue, err = h.Storage.GetFiltered(ctx, user.Id, user.WorkspaceId,
+			Filter{Completed: &open, DueBefore: &start, Sort: SortDueAt}, now)
+	}
+	if err == nil {
+		today.DueToday, err = h.Storage.GetFiltered(ctx, user.Id, user.WorkspaceId,
+			Filter{Completed: &open, DueAfter: &start, DueBefore: &end, Sort: SortDueAt}, now)
+	}
+	if err != nil {
+		h.Log.Errorf("failed to get today of user_id=(%s). due to error: %v", user.Id, err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get today"))
+		return
+	}
+
+	picked := make(map[string]bool, len(today.MyDay))
+	for _, t := range today.MyDay {
+		picked[t.Id] = true
+	}
+	today.Overdue, today.DueToday = unpicked(today.Overdue, picked), unpicked(today.DueToday, picked)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", today))
+}
+
+// Snooze hides the todo from the user's listings until the given time, or
+// until the midnight starting the given date of the user's calendar.
+func (h *Handler) Snooze(ctx *gin.Context) {
+	var snoozeDto SnoozeDto
+	if err := ctx.ShouldBindJSON(&snoozeDto); err != nil {
+		h.Log.Errorf("failed to bind snooze. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	until := snoozeDto.Until
+	if (until == nil) == (snoozeDto.Date == nil) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "either until or date is required"))
+		return
+	}
+	if snoozeDto.Date != nil {
+		date, err := ParseLocalDate(*snoozeDto.Date, user.Location())
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid date"))
+			return
+		}
+		until = &date
+	}
+	now := time.Now()
+	if !until.After(now) || until.Sub(now) > MaxSnooze {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "a snooze must end in the coming year"))
+		return
+	}
+	h.plan(ctx, func(id string, today string) (Plan, error) {
+		return h.Storage.Snooze(ctx, id, user.Id, until, today)
+	})
+}
+
+func (h *Handler) Unsnooze(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	h.plan(ctx, func(id string, today string) (Plan, error) {
+		return h.Storage.Snooze(ctx, id, user.Id, nil, today)
+	})
+}
+
+// Pick adds the todo to the user's day, which also ends its snooze. Picks
+// lapse at the user's next midnight.
+func (h *Handler) Pick(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	h.plan(ctx, func(id string, today string) (Plan, error) {
+		return h.Storage.Pick(ctx, id, user.Id, true, today)
+	})
+}
+
+func (h *Handler) Unpick(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	h.plan(ctx, func(id string, today string) (Plan, error) {
+		return h.Storage.Pick(ctx, id, user.Id, false, today)
+	})
+}
+
+// plan applies a change to the user's plan of the todo of the request, which
+// they only need to see. Today is the current date of the user's calendar.
+func (h *Handler) plan(ctx *gin.Context, change func(id, today string) (Plan, error)) {
+	id := ctx.Param(Id)
+	user := users.GetCurrentUser(ctx)
+	if _, _, err := h.Storage.GetTodoById(ctx, id, user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	plan, err := change(id, LocalDate(time.Now(), user.Location()))
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to plan todo"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", plan))
+}
+
+func unpicked(todos []Todo, picked map[string]bool) []Todo {
+	kept := make([]Todo, 0, len(todos))
+	for _, t := range todos {
+		if !picked[t.Id] {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
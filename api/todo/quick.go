@@ -0,0 +1,58 @@
+package todo
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"todoproject/api/users"
+	"todoproject/apperror"
+	"todoproject/quickadd"
+
+	"github.com/gin-gonic/gin"
+)
+
+const DryRun = "dry_run"
+
+// QuickAdd creates a todo from a line such as "Pay rent tomorrow 9am #home",
+// reading dates in the user's time zone. With dry_run=true it only returns
+// what was recognized, so clients can highlight it while the user types.
+func (h *Handler) QuickAdd(ctx *gin.Context) {
+	var dto QuickAddDto
+	if err := ctx.ShouldBindJSON(&dto); err != nil {
+		h.Log.Errorf("failed to bind quick add. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if strings.TrimSpace(dto.Text) == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "text is required"))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	parsed := quickadd.Parse(dto.Text, time.Now().In(user.Location()))
+	if ctx.Query(DryRun) == "true" {
+		ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", QuickAddResult{Parsed: parsed}))
+		return
+	}
+	if parsed.Title == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "title is empty"))
+		return
+	}
+
+	todoDto := CreateTodoDto{Title: parsed.Title, ListId: dto.ListId, DueAt: parsed.Due, Tags: parsed.Tags}
+	if parsed.Priority != "" {
+		todoDto.Priority = &parsed.Priority
+	}
+	if parsed.Recurrence != nil {
+		rule := parsed.Recurrence.RRule()
+		todoDto.Recurrence = &rule
+	}
+
+	todo, err := h.createTodo(ctx, user, todoDto)
+	if err != nil {
+		h.Log.Errorf("failed to quick add todo. due to error: %v", err)
+		apperror.AbortWithError(ctx, err, "failed to create todo")
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", QuickAddResult{Todo: &todo, Parsed: parsed}))
+}
@@ -1,11 +1,43 @@
 package todo
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"time"
+	"todoproject/api/activity"
+	"todoproject/api/lists"
+
+	"github.com/jackc/pgx/v5"
+)
 
 type Repository interface {
-	GetAllTodoByUserId(ctx context.Context, userId string) (t []Todo, err error)
-	GetTodoById(ctx context.Context, id string) (todo Todo, err error)
+	GetAllTodoByUserId(ctx context.Context, userId string, workspaceId, listId *string) (t []Todo, err error)
+	GetFiltered(ctx context.Context, userId string, workspaceId *string, filter Filter, now time.Time) ([]Todo, error)
+	Count(ctx context.Context, userId string, workspaceId *string, filter Filter, now time.Time) (int, error)
+	GetAssignedTodos(ctx context.Context, userId string, workspaceId *string) ([]Todo, error)
+	GetTodoById(ctx context.Context, id, userId string, workspaceId *string) (todo Todo, role lists.Role, err error)
+	GetByIds(ctx context.Context, ids []string, userId string) ([]Todo, error)
 	Create(ctx context.Context, todo *Todo) error
-	Update(ctx context.Context, todo Todo) error
-	Delete(ctx context.Context, id string, userId string) error
+	Insert(ctx context.Context, tx pgx.Tx, todo *Todo) (activity.Activity, error)
+	Update(ctx context.Context, todo *Todo, userId string) error
+	Complete(ctx context.Context, todo *Todo, completedAt *time.Time, userId string) error
+	Move(ctx context.Context, todo *Todo, statusId, userId string) error
+	MoveToList(ctx context.Context, todo *Todo, listId, userId string) error
+	SetCustomFields(ctx context.Context, todo *Todo, values map[string]json.RawMessage, userId string) error
+	GetMyDay(ctx context.Context, userId string, workspaceId *string, date string) ([]Todo, error)
+	Snooze(ctx context.Context, id, userId string, until *time.Time, today string) (Plan, error)
+	Pick(ctx context.Context, id, userId string, pick bool, today string) (Plan, error)
+	GetBlockers(ctx context.Context, id, userId string) ([]Todo, error)
+	AddBlocker(ctx context.Context, todo *Todo, blockerId, userId string) error
+	RemoveBlocker(ctx context.Context, todo *Todo, blockerId, userId string) error
+	Assign(ctx context.Context, todo *Todo, assigneeId *string, assignedBy string) error
+	SetWaiting(ctx context.Context, todo *Todo, waitingFor *string, followUpAt *time.Time, userId string) error
+	GetWeeklyReview(ctx context.Context, userId string, workspaceId *string, since, staleSince time.Time, limit int) (WeeklyReview, error)
+	GetRotation(ctx context.Context, todo Todo) (Rotation, error)
+	GetListRotations(ctx context.Context, listId string) (map[string][]RotationMember, error)
+	SetRoster(ctx context.Context, todo *Todo, members []string, userId string) (Rotation, error)
+	ClearRoster(ctx context.Context, todo *Todo, userId string) error
+	Skip(ctx context.Context, todo *Todo, memberId string, turns int, userId string) (Rotation, error)
+	Swap(ctx context.Context, todo *Todo, memberId, withMemberId string, userId string) (Rotation, error)
+	Delete(ctx context.Context, id string, userId string, workspaceId *string) error
 }
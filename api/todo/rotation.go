@@ -0,0 +1,259 @@
+package todo
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"todoproject/api/users"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Turns = "turns"
+
+const (
+	MaxRosterSize = 50
+	// MaxTurns bounds the turns skipped at once and the upcoming turns shown.
+	MaxTurns     = 52
+	DefaultTurns = 5
+)
+
+var (
+	RotationUrl      = fmt.Sprintf("/todo/:%s/rotation", Id)
+	RotationSkipUrl  = fmt.Sprintf("/todo/:%s/rotation/skip", Id)
+	RotationSwapUrl  = fmt.Sprintf("/todo/:%s/rotation/swap", Id)
+	ListRotationsUrl = fmt.Sprintf("/lists/:%s/rotations", Id)
+)
+
+// GetRotation returns the roster of the todo and its next turns, as many as
+// given by turns.
+func (h *Handler) GetRotation(ctx *gin.Context) {
+	turns, ok := parseTurns(ctx)
+	if !ok {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	todo, _, err := h.Storage.GetTodoById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	rotation, err := h.Storage.GetRotation(ctx, todo)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get rotation"))
+		return
+	}
+	rotation.Upcoming = upcoming(rotation.Members, rotation.AssigneeId, turns)
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rotation))
+}
+
+// GetListRotations returns the rotating todos of the list the user can see,
+// each with its next turns.
+func (h *Handler) GetListRotations(ctx *gin.Context) {
+	turns, ok := parseTurns(ctx)
+	if !ok {
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	listId := ctx.Param(Id)
+	if _, err := h.listStorage.GetRole(ctx, listId, user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return
+	}
+	rosters, err := h.Storage.GetListRotations(ctx, listId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get rotations"))
+		return
+	}
+	todos, err := h.Storage.GetAllTodoByUserId(ctx, user.Id, user.WorkspaceId, &listId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+		return
+	}
+	rotations := make([]Rotation, 0, len(rosters))
+	for _, t := range todos {
+		if members, ok := rosters[t.Id]; ok {
+			rotation := newRotation(t, members)
+			rotation.Upcoming = upcoming(members, t.AssigneeId, turns)
+			rotations = append(rotations, rotation)
+		}
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rotations))
+}
+
+// SetRoster makes a recurring todo of a list rotate through the given members
+// of the list, in order.
+func (h *Handler) SetRoster(ctx *gin.Context) {
+	var rosterDto RosterDto
+	if err := ctx.ShouldBindJSON(&rosterDto); err != nil {
+		h.Log.Errorf("failed to bind roster. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if len(rosterDto.Members) == 0 || len(rosterDto.Members) > MaxRosterSize {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail",
+			fmt.Sprintf("a roster must have between 1 and %d members", MaxRosterSize)))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if todo.ListId == nil || todo.Recurrence == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "only recurring todos on a list can rotate"))
+		return
+	}
+	seen := make(map[string]bool, len(rosterDto.Members))
+	for _, member := range rosterDto.Members {
+		if seen[member] {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "a member is listed twice"))
+			return
+		}
+		seen[member] = true
+		if _, err := h.listStorage.GetRole(ctx, *todo.ListId, member, user.WorkspaceId); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "member has no access to the list"))
+			return
+		}
+	}
+
+	rotation, err := h.Storage.SetRoster(ctx, &todo, rosterDto.Members, user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to set roster")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rotation))
+}
+
+func (h *Handler) ClearRoster(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if err = h.Storage.ClearRoster(ctx, &todo, user.Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to clear roster")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// Skip passes a member of the roster over for their next turns, one unless
+// turns is given.
+func (h *Handler) Skip(ctx *gin.Context) {
+	var skipDto SkipDto
+	if err := ctx.ShouldBindJSON(&skipDto); err != nil {
+		h.Log.Errorf("failed to bind skip. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	turns := 1
+	if skipDto.Turns != nil {
+		turns = *skipDto.Turns
+	}
+	if turns < 1 || turns > MaxTurns {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail",
+			fmt.Sprintf("turns must be between 1 and %d", MaxTurns)))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	rotation, err := h.Storage.Skip(ctx, &todo, skipDto.UserId, turns, user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to skip member")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rotation))
+}
+
+// Swap trades the turns of two members of the roster.
+func (h *Handler) Swap(ctx *gin.Context) {
+	var swapDto SwapDto
+	if err := ctx.ShouldBindJSON(&swapDto); err != nil {
+		h.Log.Errorf("failed to bind swap. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if swapDto.UserId == swapDto.WithUserId {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "a member cannot swap with themselves"))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	todo, err := h.editableTodo(ctx, ctx.Param(Id), user)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	rotation, err := h.Storage.Swap(ctx, &todo, swapDto.UserId, swapDto.WithUserId, user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to swap members")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", rotation))
+}
+
+// parseTurns reads the number of upcoming turns asked for.
+func parseTurns(ctx *gin.Context) (int, bool) {
+	turns, err := strconv.Atoi(ctx.DefaultQuery(Turns, strconv.Itoa(DefaultTurns)))
+	if err != nil || turns < 1 || turns > MaxTurns {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail",
+			fmt.Sprintf("turns must be between 1 and %d", MaxTurns)))
+		return 0, false
+	}
+	return turns, true
+}
+
+func newRotation(todo Todo, members []RotationMember) Rotation {
+	return Rotation{TodoId: todo.Id, Title: todo.Title, AssigneeId: todo.AssigneeId, Members: members,
+		Upcoming: upcoming(members, todo.AssigneeId, DefaultTurns)}
+}
+
+// nextMember returns the index of the member whose turn follows current's,
+// the first member's when current is not on the roster. Members with skips
+// left are passed over, which uses one of their skips.
+func nextMember(members []RotationMember, current *string) int {
+	start := 0
+	for i, member := range members {
+		if current != nil && member.UserId == *current {
+			start = i + 1
+			break
+		}
+	}
+	for k := 0; ; k++ {
+		i := (start + k) % len(members)
+		if members[i].Skips == 0 {
+			return i
+		}
+		members[i].Skips--
+	}
+}
+
+// upcoming returns the members of the next turns after current's, leaving
+// the roster unchanged.
+func upcoming(members []RotationMember, current *string, turns int) []string {
+	next := make([]string, 0, turns)
+	if len(members) == 0 {
+		return next
+	}
+	roster := append([]RotationMember(nil), members...)
+	for len(next) < turns {
+		i := nextMember(roster, current)
+		current = &roster[i].UserId
+		next = append(next, *current)
+	}
+	return next
+}
+
+func sameAssignee(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
@@ -0,0 +1,82 @@
+package todo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func user(id string) *string {
+	return &id
+}
+
+func TestNextMember(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []RotationMember
+		current *string
+		want    int
+		// after is the roster once the skips passed over are used
+		after []RotationMember
+	}{
+		{name: "next on the roster", members: []RotationMember{{"a", 0}, {"b", 0}, {"c", 0}}, current: user("a"), want: 1},
+		{name: "wraps around", members: []RotationMember{{"a", 0}, {"b", 0}, {"c", 0}}, current: user("c"), want: 0},
+		{name: "unassigned", members: []RotationMember{{"a", 0}, {"b", 0}}, current: nil, want: 0},
+		{name: "not on the roster", members: []RotationMember{{"a", 0}, {"b", 0}}, current: user("x"), want: 0},
+		{
+			name: "skips a member", members: []RotationMember{{"a", 0}, {"b", 1}, {"c", 0}}, current: user("a"), want: 2,
+			after: []RotationMember{{"a", 0}, {"b", 0}, {"c", 0}},
+		},
+		{
+			name: "skips the first member when unassigned", members: []RotationMember{{"a", 2}, {"b", 0}}, current: nil, want: 1,
+			after: []RotationMember{{"a", 1}, {"b", 0}},
+		},
+		{
+			name: "everyone skips", members: []RotationMember{{"a", 1}, {"b", 1}}, current: user("a"), want: 1,
+			after: []RotationMember{{"a", 0}, {"b", 0}},
+		},
+		{
+			name: "alone with skips", members: []RotationMember{{"a", 2}}, current: user("a"), want: 0,
+			after: []RotationMember{{"a", 0}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			after := test.after
+			if after == nil {
+				after = append([]RotationMember(nil), test.members...)
+			}
+			if got := nextMember(test.members, test.current); got != test.want {
+				t.Errorf("nextMember = %d, want %d", got, test.want)
+			}
+			if !reflect.DeepEqual(test.members, after) {
+				t.Errorf("roster = %v, want %v", test.members, after)
+			}
+		})
+	}
+}
+
+func TestUpcoming(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []RotationMember
+		current *string
+		want    []string
+	}{
+		{name: "empty roster", members: []RotationMember{}, current: user("a"), want: []string{}},
+		{name: "in order", members: []RotationMember{{"a", 0}, {"b", 0}, {"c", 0}}, current: user("a"), want: []string{"b", "c", "a", "b"}},
+		{name: "unassigned", members: []RotationMember{{"a", 0}, {"b", 0}}, current: nil, want: []string{"a", "b", "a", "b"}},
+		{name: "skip used once", members: []RotationMember{{"a", 0}, {"b", 1}, {"c", 0}}, current: user("a"), want: []string{"c", "a", "b", "c"}},
+		{name: "alone", members: []RotationMember{{"a", 1}}, current: user("a"), want: []string{"a", "a", "a", "a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			members := append(make([]RotationMember, 0, len(test.members)), test.members...)
+			if got := upcoming(test.members, test.current, 4); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("upcoming = %v, want %v", got, test.want)
+			}
+			if !reflect.DeepEqual(test.members, members) {
+				t.Errorf("roster changed to %v", test.members)
+			}
+		})
+	}
+}
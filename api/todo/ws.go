@@ -0,0 +1,195 @@
+package todo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	"todoproject/api/activity"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	CommandCreate = "create"
+	CommandUpdate = "update"
+	CommandDelete = "delete"
+)
+
+const (
+	MessageAck   = "ack"
+	MessageError = "error"
+)
+
+const (
+	wsWriteWait      = 10 * time.Second
+	wsPongWait       = 60 * time.Second
+	wsPingInterval   = wsPongWait * 9 / 10
+	wsMaxMessageSize = 64 << 10
+	wsSendBuffer     = 16
+)
+
+var WsUrl = "/ws"
+
+// The default origin check is kept: the handshake can be authenticated by the
+// token cookie, so cross-site pages must not be able to open the socket.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// ServeWs upgrades to a WebSocket on which the client sends todo commands and
+// receives the todo changes made by its other sessions. The route sits behind
+// IsLogin, so the handshake is authenticated and scoped to a workspace exactly
+// like the REST endpoints.
+func (h *Handler) ServeWs(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+
+	session, err := util.GenerateRandomToken(16)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, apperror.NewJsonMessage("fail", "failed to open session"))
+		return
+	}
+
+	conn, err := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// the upgrader has already answered the handshake
+		h.Log.Errorf("failed to upgrade websocket. due to error: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	live := h.activity.Subscribe()
+	defer h.activity.Unsubscribe(live)
+
+	out := make(chan WsMessage, wsSendBuffer)
+	done := make(chan struct{})
+	go h.writeWs(ctx, conn, user, session, out, live, done)
+
+	conn.SetReadLimit(wsMaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	commandCtx := activity.WithOrigin(ctx.Request.Context(), session)
+	for {
+		var command WsCommand
+		if err := conn.ReadJSON(&command); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				h.Log.Errorf("failed to read websocket of user_id=(%s). due to error: %v", user.Id, err)
+			}
+			break
+		}
+		select {
+		case out <- h.handleCommand(commandCtx, user, command):
+		case <-done:
+			return
+		}
+	}
+	close(out)
+	<-done
+}
+
+// handleCommand applies a command and builds its reply. Creates are idempotent:
+// retrying one whose todo the user already created acknowledges the existing
+// todo, the id of a todo of someone else is not found.
+func (h *Handler) handleCommand(ctx context.Context, user users.User, command WsCommand) WsMessage {
+	var todo Todo
+	var err error
+	switch command.Type {
+	case CommandCreate:
+		todo, err = h.createTodo(ctx, user, command.Todo)
+		if errors.Is(err, apperror.ErrConflict) {
+			todo, _, err = h.Storage.GetTodoById(ctx, *command.Todo.Id, user.Id, user.WorkspaceId)
+		}
+	case CommandUpdate:
+		if command.Todo.Id == nil {
+			return wsError(command, http.StatusBadRequest, "todo id is required")
+		}
+		todo, err = h.updateTodo(ctx, user, Todo{
+			Id: *command.Todo.Id, Title: command.Todo.Title, DueAt: command.Todo.DueAt, Tags: command.Todo.Tags,
+			Priority: command.Todo.Priority, Recurrence: command.Todo.Recurrence,
+			EstimatedMinutes: command.Todo.EstimatedMinutes, Contexts: command.Todo.Contexts, NextAction: command.Todo.NextAction,
+		})
+	case CommandDelete:
+		if command.Todo.Id == nil {
+			return wsError(command, http.StatusBadRequest, "todo id is required")
+		}
+		todo.Id = *command.Todo.Id
+		err = h.deleteTodo(ctx, user, todo.Id)
+	default:
+		return wsError(command, http.StatusBadRequest, "unknown command type")
+	}
+	if err != nil {
+		h.Log.Errorf("failed to %s todo over websocket. due to error: %v", command.Type, err)
+		message := "failed to " + command.Type + " todo"
+		if status := apperror.StatusCode(err); status != http.StatusBadRequest {
+			message = err.Error()
+		}
+		return wsError(command, apperror.StatusCode(err), message)
+	}
+	return WsMessage{Type: MessageAck, ClientId: command.ClientId, Todo: &todo}
+}
+
+// writeWs is the only writer of the connection. It sends the replies, the
+// changes of other sessions the user can see and the keep-alive pings, and
+// closes done when it stops.
+func (h *Handler) writeWs(ctx context.Context, conn *websocket.Conn, user users.User, session string,
+	out <-chan WsMessage, live <-chan activity.Activity, done chan<- struct{}) {
+	defer close(done)
+	defer conn.Close()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var message WsMessage
+		select {
+		case reply, ok := <-out:
+			if !ok {
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			message = reply
+		case a, ok := <-live:
+			if !ok {
+				// dropped by the broker for being too slow, the client resyncs on reconnect
+				conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			if a.TodoId == nil || a.Origin == session {
+				continue
+			}
+			visible, err := h.activity.CanSee(ctx, a, user.Id, user.WorkspaceId)
+			if err != nil {
+				return
+			}
+			if !visible {
+				continue
+			}
+			message = WsMessage{Type: "todo." + a.Kind, Activity: &a}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			continue
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := conn.WriteJSON(message); err != nil {
+			return
+		}
+	}
+}
+
+func wsError(command WsCommand, status int, message string) WsMessage {
+	return WsMessage{Type: MessageError, ClientId: command.ClientId, Status: status, Message: message}
+}
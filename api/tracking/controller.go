@@ -0,0 +1,216 @@
+package tracking
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id     = "id"
+	From   = "from"
+	To     = "to"
+	ListId = "list_id"
+)
+
+const (
+	DateLayout = "2006-01-02"
+	// DefaultDays is the length of the report range when from is not given.
+	DefaultDays = 30
+	MaxDays     = 366
+	// MaxEntryDuration bounds manual entries, longer work is several entries.
+	MaxEntryDuration = 24 * time.Hour
+)
+
+var (
+	TimerUrl       = "/timer"
+	StartTimerUrl  = fmt.Sprintf("/todo/:%s/timer/start", Id)
+	StopTimerUrl   = fmt.Sprintf("/todo/:%s/timer/stop", Id)
+	TodoEntriesUrl = fmt.Sprintf("/todo/:%s/time-entries", Id)
+	EntryUrl       = fmt.Sprintf("/time-entries/:%s", Id)
+	ReportUrl      = "/time-report"
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitTrackingHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(TimerUrl, h.GetRunning)
+		api.POST(StartTimerUrl, h.Start)
+		api.POST(StopTimerUrl, h.Stop)
+		api.GET(TodoEntriesUrl, h.GetByTodo)
+		api.POST(TodoEntriesUrl, h.Create)
+		api.PUT(EntryUrl, h.Update)
+		api.DELETE(EntryUrl, h.Delete)
+		api.GET(ReportUrl, h.GetReport)
+	}
+}
+
+func (h *Handler) GetRunning(ctx *gin.Context) {
+	entry, err := h.Storage.GetRunning(ctx, users.GetCurrentUser(ctx).Id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get timer"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", entry))
+}
+
+// Start starts a timer on the todo. A user has a single running timer, the
+// one running on another todo is stopped first.
+func (h *Handler) Start(ctx *gin.Context) {
+	var timerDto StartTimerDto
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&timerDto); err != nil {
+			h.Log.Errorf("failed to bind timer. due to error: %v", err)
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+			return
+		}
+	}
+
+	user := users.GetCurrentUser(ctx)
+	started, err := h.Storage.Start(ctx, ctx.Param(Id), user.Id, user.WorkspaceId, timerDto.Note)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to start timer")
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", started))
+}
+
+func (h *Handler) Stop(ctx *gin.Context) {
+	entry, err := h.Storage.Stop(ctx, ctx.Param(Id), users.GetCurrentUser(ctx).Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "no timer running on this todo")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", entry))
+}
+
+func (h *Handler) GetByTodo(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	entries, err := h.Storage.GetByTodo(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get time entries"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", entries))
+}
+
+// Create adds a manual entry for time that was not tracked with the timer.
+func (h *Handler) Create(ctx *gin.Context) {
+	var entryDto EntryDto
+	if !h.bindEntry(ctx, &entryDto) {
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	entry := Entry{TodoId: ctx.Param(Id), UserId: user.Id, WorkspaceId: user.WorkspaceId,
+		StartedAt: entryDto.StartedAt, EndedAt: &entryDto.EndedAt, Note: entryDto.Note}
+	if err := h.Storage.Create(ctx, &entry); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to create time entry")
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", entry))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var entryDto EntryDto
+	if !h.bindEntry(ctx, &entryDto) {
+		return
+	}
+
+	entry := Entry{Id: ctx.Param(Id), UserId: users.GetCurrentUser(ctx).Id,
+		StartedAt: entryDto.StartedAt, EndedAt: &entryDto.EndedAt, Note: entryDto.Note}
+	if err := h.Storage.Update(ctx, &entry); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to update time entry")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", entry))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), users.GetCurrentUser(ctx).Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete time entry")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// GetReport sums the time the user tracked in the workspace by day, list, tag
+// and todo, from and to being local dates in the user's time zone. The range
+// defaults to the last 30 days.
+func (h *Handler) GetReport(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	location := user.Location()
+	query := ReportQuery{UserId: user.Id, WorkspaceId: user.WorkspaceId, Timezone: location.String()}
+
+	now := time.Now().In(location)
+	query.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if to, ok := ctx.GetQuery(To); ok {
+		date, err := time.ParseInLocation(DateLayout, to, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid to date"))
+			return
+		}
+		query.To = date
+	}
+	query.From = query.To.AddDate(0, 0, 1-DefaultDays)
+	if from, ok := ctx.GetQuery(From); ok {
+		date, err := time.ParseInLocation(DateLayout, from, location)
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid from date"))
+			return
+		}
+		query.From = date
+	}
+	if query.From.After(query.To) || query.From.AddDate(0, 0, MaxDays).Before(query.To) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid date range"))
+		return
+	}
+	if listId, ok := ctx.GetQuery(ListId); ok {
+		query.ListId = &listId
+	}
+
+	report, err := h.Storage.GetReport(ctx, query)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get time report"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", report))
+}
+
+func (h *Handler) bindEntry(ctx *gin.Context, entryDto *EntryDto) bool {
+	if err := ctx.ShouldBindJSON(entryDto); err != nil {
+		h.Log.Errorf("failed to bind time entry. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return false
+	}
+	duration := entryDto.EndedAt.Sub(entryDto.StartedAt)
+	if duration <= 0 || duration > MaxEntryDuration {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "an entry must last between a second and 24 hours"))
+		return false
+	}
+	if entryDto.EndedAt.After(time.Now().Add(time.Minute)) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "an entry cannot end in the future"))
+		return false
+	}
+	if len([]rune(entryDto.Note)) > 500 {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "note is too long"))
+		return false
+	}
+	return true
+}
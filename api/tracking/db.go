@@ -0,0 +1,330 @@
+package tracking
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// uniqueViolation is the Postgres error code of a duplicate key, raised when
+// two timers are started at once for the same user.
+const uniqueViolation = "23505"
+
+const entryColumns = `e.id, e.todo_id, e.user_id, e.workspace_id, e.started_at, e.ended_at,
+	extract(epoch FROM COALESCE(e.ended_at, now()) - e.started_at)::bigint, e.note, e.created_at`
+
+// Time can be tracked on every todo the user can see in the workspace, see
+// todo_role() in pgconsole/create_role_functions.sql. Entries can only be
+// changed by the user who tracked them.
+var QueryStart = `INSERT INTO time_entries AS e (todo_id, user_id, workspace_id, started_at, note)
+	SELECT t.id, $2, t.workspace_id, now(), $4 FROM todo t
+	WHERE t.id = $1 AND t.workspace_id IS NOT DISTINCT FROM $3 AND todo_role(t.list_id, t.user_id, $2) IS NOT NULL
+	RETURNING ` + entryColumns
+var QueryStopRunning = `UPDATE time_entries e SET ended_at = now() WHERE e.user_id = $1 AND e.ended_at IS NULL
+	RETURNING ` + entryColumns
+var QueryStop = `UPDATE time_entries e SET ended_at = now() WHERE e.user_id = $2 AND e.todo_id = $1 AND e.ended_at IS NULL
+	RETURNING ` + entryColumns
+var QueryGetRunning = `SELECT ` + entryColumns + ` FROM time_entries e WHERE e.user_id = $1 AND e.ended_at IS NULL`
+var QueryGetByTodo = `SELECT ` + entryColumns + ` FROM time_entries e JOIN todo t ON t.id = e.todo_id
+	WHERE e.todo_id = $1 AND t.workspace_id IS NOT DISTINCT FROM $3 AND todo_role(t.list_id, t.user_id, $2) IS NOT NULL
+	ORDER BY e.started_at`
+var QueryCreate = `INSERT INTO time_entries AS e (todo_id, user_id, workspace_id, started_at, ended_at, note)
+	SELECT t.id, $2, t.workspace_id, $4, $5, $6 FROM todo t
+	WHERE t.id = $1 AND t.workspace_id IS NOT DISTINCT FROM $3 AND todo_role(t.list_id, t.user_id, $2) IS NOT NULL
+	RETURNING ` + entryColumns
+var QueryUpdate = `UPDATE time_entries e SET started_at = $3, ended_at = $4, note = $5
+	WHERE e.id = $1 AND e.user_id = $2 AND e.ended_at IS NOT NULL
+	RETURNING ` + entryColumns
+var QueryDelete = `DELETE FROM time_entries WHERE id = $1 AND user_id = $2`
+
+// reportScope selects the entries of the user in the workspace started on the
+// local dates $4 to $5 of the time zone $3, optionally on a single list.
+// Running timers count up to now.
+const reportScope = `WITH scope AS (
+	SELECT e.todo_id, t.title, t.list_id, t.tags, t.estimated_minutes,
+		(e.started_at AT TIME ZONE $3)::date AS day,
+		extract(epoch FROM COALESCE(e.ended_at, now()) - e.started_at)::bigint AS seconds
+	FROM time_entries e JOIN todo t ON t.id = e.todo_id
+	WHERE e.user_id = $1 AND e.workspace_id IS NOT DISTINCT FROM $2
+	AND (e.started_at AT TIME ZONE $3)::date BETWEEN $4::date AND $5::date
+	AND ($6::uuid IS NULL OR t.list_id = $6))`
+
+var QueryReportTotal = reportScope + `
+	SELECT COALESCE(sum(seconds), 0)::bigint FROM scope`
+var QueryReportByDay = reportScope + `
+	SELECT to_char(d.day, 'YYYY-MM-DD'), COALESCE(sum(s.seconds), 0)::bigint
+	FROM (SELECT g::date AS day FROM generate_series($4::date::timestamp, $5::date::timestamp, interval '1 day') g) d
+	LEFT JOIN scope s ON s.day = d.day
+	GROUP BY d.day ORDER BY d.day`
+var QueryReportByList = reportScope + `
+	SELECT s.list_id, l.title, sum(s.seconds)::bigint FROM scope s LEFT JOIN lists l ON l.id = s.list_id
+	GROUP BY s.list_id, l.title ORDER BY 3 DESC`
+var QueryReportByTag = reportScope + `
+	SELECT tag, sum(s.seconds)::bigint FROM scope s CROSS JOIN LATERAL unnest(s.tags) AS tag
+	GROUP BY tag ORDER BY 2 DESC, tag`
+var QueryReportByTodo = reportScope + `
+	SELECT s.todo_id, s.title, s.list_id, sum(s.seconds)::bigint, s.estimated_minutes FROM scope s
+	GROUP BY s.todo_id, s.title, s.list_id, s.estimated_minutes ORDER BY 4 DESC`
+
+// QuerySnapshot makes the queries of a report read the same snapshot.
+var QuerySnapshot = `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+// Start stops the timer the user has running, on any todo, and starts one on
+// the todo. Todos the user cannot see are apperror.ErrNotFound.
+func (s *Storage) Start(ctx context.Context, todoId, userId string, workspaceId *string, note string) (TimerStarted, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return TimerStarted{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var started TimerStarted
+	var stopped Entry
+	if err = scanEntry(tx.QueryRow(ctx, QueryStopRunning, userId), &stopped); err == nil {
+		started.Stopped = &stopped
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to stop running timer of user_id=(%s). due to error: %v", userId, err)
+		return TimerStarted{}, err
+	}
+
+	if err = scanEntry(tx.QueryRow(ctx, QueryStart, todoId, userId, workspaceId, note), &started.Entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return TimerStarted{}, apperror.ErrNotFound
+		}
+		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == uniqueViolation {
+			return TimerStarted{}, apperror.ErrConflict
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to start timer on todo id=(%s). due to error: %v", todoId, err)
+		return TimerStarted{}, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return TimerStarted{}, err
+	}
+	return started, nil
+}
+
+// Stop stops the user's timer on the todo, apperror.ErrNotFound when none runs.
+func (s *Storage) Stop(ctx context.Context, todoId, userId string) (entry Entry, err error) {
+	if err = scanEntry(s.db.QueryRow(ctx, QueryStop, todoId, userId), &entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Entry{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to stop timer on todo id=(%s). due to error: %v", todoId, err)
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// GetRunning returns the timer the user has running, nil when there is none.
+func (s *Storage) GetRunning(ctx context.Context, userId string) (*Entry, error) {
+	var entry Entry
+	if err := scanEntry(s.db.QueryRow(ctx, QueryGetRunning, userId), &entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get running timer of user_id=(%s). due to error: %v", userId, err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetByTodo returns the entries of every user on a todo the user can see.
+func (s *Storage) GetByTodo(ctx context.Context, todoId, userId string, workspaceId *string) ([]Entry, error) {
+	rows, err := s.db.Query(ctx, QueryGetByTodo, todoId, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query time entries. due to error: %v", err)
+		return nil, err
+	}
+	entries := make([]Entry, 0)
+	for rows.Next() {
+		var entry Entry
+		if errScan := scanEntry(rows, &entry); errScan != nil {
+			s.log.Errorf("failed to scan time entry. due to error: %v", errScan)
+			return nil, errScan
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Create adds a finished entry, todos the user cannot see are apperror.ErrNotFound.
+func (s *Storage) Create(ctx context.Context, entry *Entry) error {
+	row := s.db.QueryRow(ctx, QueryCreate, entry.TodoId, entry.UserId, entry.WorkspaceId, entry.StartedAt, entry.EndedAt, entry.Note)
+	if err := scanEntry(row, entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create time entry on todo id=(%s). due to error: %v", entry.TodoId, err)
+		return err
+	}
+	return nil
+}
+
+// Update changes a finished entry of the user. Running timers are stopped
+// rather than edited, they are reported as apperror.ErrNotFound.
+func (s *Storage) Update(ctx context.Context, entry *Entry) error {
+	row := s.db.QueryRow(ctx, QueryUpdate, entry.Id, entry.UserId, entry.StartedAt, entry.EndedAt, entry.Note)
+	if err := scanEntry(row, entry); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update time entry id=(%s). due to error: %v", entry.Id, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete time entry id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// GetReport aggregates the tracked time with one query per breakdown, all in
+// a single read-only transaction.
+func (s *Storage) GetReport(ctx context.Context, query ReportQuery) (Report, error) {
+	report := Report{
+		From:     query.From.Format(DateLayout),
+		To:       query.To.Format(DateLayout),
+		Timezone: query.Timezone,
+		ByDay:    make([]DaySeconds, 0),
+		ByList:   make([]ListTime, 0),
+		ByTag:    make([]TagTime, 0),
+		ByTodo:   make([]TodoTime, 0),
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return Report{}, err
+	}
+	defer tx.Rollback(ctx)
+	if _, err = tx.Exec(ctx, QuerySnapshot); err != nil {
+		s.TraceQueryError(err)
+		return Report{}, err
+	}
+
+	args := []interface{}{query.UserId, query.WorkspaceId, query.Timezone,
+		query.From.Format(DateLayout), query.To.Format(DateLayout), query.ListId}
+
+	if err = tx.QueryRow(ctx, QueryReportTotal, args...).Scan(&report.Seconds); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get time report of user_id=(%s). due to error: %v", query.UserId, err)
+		return Report{}, err
+	}
+	err = s.queryRows(ctx, tx, QueryReportByDay, args, func(rows pgx.Rows) error {
+		var day DaySeconds
+		if err := rows.Scan(&day.Date, &day.Seconds); err != nil {
+			return err
+		}
+		report.ByDay = append(report.ByDay, day)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	err = s.queryRows(ctx, tx, QueryReportByList, args, func(rows pgx.Rows) error {
+		var list ListTime
+		if err := rows.Scan(&list.ListId, &list.Title, &list.Seconds); err != nil {
+			return err
+		}
+		report.ByList = append(report.ByList, list)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	err = s.queryRows(ctx, tx, QueryReportByTag, args, func(rows pgx.Rows) error {
+		var tag TagTime
+		if err := rows.Scan(&tag.Tag, &tag.Seconds); err != nil {
+			return err
+		}
+		report.ByTag = append(report.ByTag, tag)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+	err = s.queryRows(ctx, tx, QueryReportByTodo, args, func(rows pgx.Rows) error {
+		var todo TodoTime
+		if err := rows.Scan(&todo.TodoId, &todo.Title, &todo.ListId, &todo.Seconds, &todo.EstimatedMinutes); err != nil {
+			return err
+		}
+		report.ByTodo = append(report.ByTodo, todo)
+		return nil
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	return report, nil
+}
+
+func (s *Storage) queryRows(ctx context.Context, tx pgx.Tx, query string, args []interface{}, scan func(pgx.Rows) error) error {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query time report. due to error: %v", err)
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if errScan := scan(rows); errScan != nil {
+			s.log.Errorf("failed to scan time report. due to error: %v", errScan)
+			return errScan
+		}
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+func scanEntry(row pgx.Row, entry *Entry) error {
+	return row.Scan(&entry.Id, &entry.TodoId, &entry.UserId, &entry.WorkspaceId, &entry.StartedAt, &entry.EndedAt,
+		&entry.Seconds, &entry.Note, &entry.CreatedAt)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
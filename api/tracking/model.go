@@ -0,0 +1,82 @@
+package tracking
+
+import "time"
+
+// Entry is time spent by a user on a todo. A running timer is an entry
+// without an end, Seconds then counts up to now.
+type Entry struct {
+	Id          string     `json:"id"`
+	TodoId      string     `json:"todo_id"`
+	UserId      string     `json:"user_id"`
+	WorkspaceId *string    `json:"workspace_id"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at"`
+	Seconds     int64      `json:"seconds"`
+	Note        string     `json:"note"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type StartTimerDto struct {
+	Note string `json:"note"`
+}
+
+// TimerStarted is the started entry with the one it stopped, if the user had
+// a timer running on another todo.
+type TimerStarted struct {
+	Entry   Entry  `json:"entry"`
+	Stopped *Entry `json:"stopped"`
+}
+
+type EntryDto struct {
+	StartedAt time.Time `json:"started_at" binding:"required"`
+	EndedAt   time.Time `json:"ended_at" binding:"required"`
+	Note      string    `json:"note"`
+}
+
+// ReportQuery selects the entries of the user started between From and To,
+// local dates of the time zone, both included.
+type ReportQuery struct {
+	UserId      string
+	WorkspaceId *string
+	ListId      *string
+	Timezone    string
+	From        time.Time
+	To          time.Time
+}
+
+type Report struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Timezone string       `json:"timezone"`
+	Seconds  int64        `json:"seconds"`
+	ByDay    []DaySeconds `json:"by_day"`
+	ByList   []ListTime   `json:"by_list"`
+	ByTag    []TagTime    `json:"by_tag"`
+	ByTodo   []TodoTime   `json:"by_todo"`
+}
+
+// DaySeconds is the time of the entries started on the day.
+type DaySeconds struct {
+	Date    string `json:"date"`
+	Seconds int64  `json:"seconds"`
+}
+
+type ListTime struct {
+	ListId  *string `json:"list_id"`
+	Title   *string `json:"title"`
+	Seconds int64   `json:"seconds"`
+}
+
+type TagTime struct {
+	Tag     string `json:"tag"`
+	Seconds int64  `json:"seconds"`
+}
+
+// TodoTime compares the tracked time of a todo with its estimate.
+type TodoTime struct {
+	TodoId           string  `json:"todo_id"`
+	Title            string  `json:"title"`
+	ListId           *string `json:"list_id"`
+	Seconds          int64   `json:"seconds"`
+	EstimatedMinutes *int    `json:"estimated_minutes"`
+}
@@ -0,0 +1,14 @@
+package tracking
+
+import "context"
+
+type Repository interface {
+	Start(ctx context.Context, todoId, userId string, workspaceId *string, note string) (TimerStarted, error)
+	Stop(ctx context.Context, todoId, userId string) (Entry, error)
+	GetRunning(ctx context.Context, userId string) (*Entry, error)
+	GetByTodo(ctx context.Context, todoId, userId string, workspaceId *string) ([]Entry, error)
+	Create(ctx context.Context, entry *Entry) error
+	Update(ctx context.Context, entry *Entry) error
+	Delete(ctx context.Context, id, userId string) error
+	GetReport(ctx context.Context, query ReportQuery) (Report, error)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 	"todoproject/api/util"
 	"todoproject/apperror"
 	"todoproject/db"
@@ -118,6 +119,12 @@ func (h *Handler) Update(ctx *gin.Context) {
 		h.log.Errorf("failed to bind json users. due to error: %v. method = (Update)", errBind)
 		return
 	}
+	// users only ever update themselves, whatever id the body holds
+	user.Id = GetCurrentUser(ctx).Id
+	if _, errZone := time.LoadLocation(user.Timezone); errZone != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "unknown timezone"))
+		return
+	}
 	errUpdate := h.storage.Update(ctx, user)
 	if errUpdate != nil {
 		h.log.Errorf("failed to update users. due to error: %v", errUpdate)
@@ -192,6 +199,15 @@ func (h *Handler) IsLogin() gin.HandlerFunc {
 			return
 		}
 
+		if workspaceId := ctx.GetHeader(util.WorkspaceHeader); workspaceId != "" {
+			role, errRole := h.storage.GetWorkspaceRole(ctx, workspaceId, user.Id)
+			if errRole != nil {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, gin.H{"status": "fail", "message": "workspace not found"})
+				return
+			}
+			user.WorkspaceId, user.WorkspaceRole = &workspaceId, role
+		}
+
 		ctx.Set(util.CURRENT_USER, user)
 		ctx.Next()
 
@@ -210,3 +226,7 @@ func (h *Handler) SetTokenCookie(ctx *gin.Context, token string, isSignIn bool)
 		ctx.SetCookie(util.Token, util.EmptyStr, -1, util.HomePath, util.DOMAIN, false, true)
 	}
 }
+
+func GetCurrentUser(ctx *gin.Context) User {
+	return ctx.MustGet(util.CURRENT_USER).(User)
+}
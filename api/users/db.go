@@ -7,12 +7,15 @@ import (
 	"todoproject/db"
 )
 
-var QueryGetAll = `SELECT * FROM users;`
-var QueryGetById = `SELECT * FROM users WHERE id = $1`
-var QueryGetByNameAndPassword = `SELECT * FROM users WHERE name = $1 AND password_hash=$2`
+const userColumns = `id, name, password_hash, timezone`
+
+var QueryGetAll = `SELECT ` + userColumns + ` FROM users;`
+var QueryGetById = `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+var QueryGetByNameAndPassword = `SELECT ` + userColumns + ` FROM users WHERE name = $1 AND password_hash=$2`
 var QueryCreate = `INSERT INTO users (name, password_hash) VALUES ($1, $2) RETURNING id`
-var QueryUpdate = `UPDATE users SET name = $1 WHERE id = $2`
+var QueryUpdate = `UPDATE users SET name = $1, timezone = COALESCE(NULLIF($3, ''), timezone) WHERE id = $2`
 var QueryDelete = `DELETE FROM users WHERE id = $1`
+var QueryGetWorkspaceRole = `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
 
 type Storage struct {
 	db  db.Client
@@ -33,7 +36,7 @@ func (s *Storage) GetAll(ctx context.Context) ([]User, error) {
 	users := make([]User, 0)
 	for rows.Next() {
 		var user User
-		errScan := rows.Scan(&user.Id, &user.Name, &user.Password)
+		errScan := rows.Scan(&user.Id, &user.Name, &user.Password, &user.Timezone)
 		if errScan != nil {
 			s.log.Errorf("failed to scan users. due to error: %v", errScan)
 			return nil, errScan
@@ -48,7 +51,7 @@ func (s *Storage) GetAll(ctx context.Context) ([]User, error) {
 }
 
 func (s *Storage) GetById(ctx context.Context, id string) (user User, errQuery error) {
-	if errQuery := s.db.QueryRow(ctx, QueryGetById, id).Scan(&user.Id, &user.Name, &user.Password); errQuery != nil {
+	if errQuery := s.db.QueryRow(ctx, QueryGetById, id).Scan(&user.Id, &user.Name, &user.Password, &user.Timezone); errQuery != nil {
 		s.TraceQueryError(errQuery)
 		s.log.Errorf("failed to query get by id=(%s), due to error: %v", id, errQuery)
 		return User{}, errQuery
@@ -65,7 +68,7 @@ func (s *Storage) Create(ctx context.Context, user User) error {
 }
 
 func (s *Storage) Update(ctx context.Context, user User) error {
-	_, errUpdate := s.db.Exec(ctx, QueryUpdate, user.Name, user.Id)
+	_, errUpdate := s.db.Exec(ctx, QueryUpdate, user.Name, user.Id, user.Timezone)
 	if errUpdate != nil {
 		s.TraceQueryError(errUpdate)
 		s.log.Errorf("failed to update users=(%v). due to error: %v", user, errUpdate)
@@ -86,7 +89,7 @@ func (s *Storage) Delete(ctx context.Context, id string) error {
 
 func (s *Storage) GetByNameAndPassword(ctx context.Context, name, password string) (User, error) {
 	var user User
-	err := s.db.QueryRow(ctx, QueryGetByNameAndPassword, name, password).Scan(&user.Id, &user.Name, &user.Password)
+	err := s.db.QueryRow(ctx, QueryGetByNameAndPassword, name, password).Scan(&user.Id, &user.Name, &user.Password, &user.Timezone)
 	if err != nil {
 		s.TraceQueryError(err)
 		return User{}, err
@@ -94,6 +97,14 @@ func (s *Storage) GetByNameAndPassword(ctx context.Context, name, password strin
 	return user, nil
 }
 
+func (s *Storage) GetWorkspaceRole(ctx context.Context, workspaceId, userId string) (role string, err error) {
+	if err = s.db.QueryRow(ctx, QueryGetWorkspaceRole, workspaceId, userId).Scan(&role); err != nil {
+		s.TraceQueryError(err)
+		return "", err
+	}
+	return role, nil
+}
+
 func (s *Storage) TraceQueryError(err error) {
 	if pgErr, ok := err.(*pgconn.PgError); ok {
 		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
@@ -1,15 +1,36 @@
 package users
 
+import "time"
+
 type User struct {
 	Id       string `json:"id"`
 	Name     string `json:"name"`
 	Password string `json:"password,omitempty"`
+	// Timezone is an IANA zone name, dates the user types are read in it.
+	Timezone string `json:"timezone,omitempty"`
+
+	// WorkspaceId and WorkspaceRole describe the workspace selected for the
+	// current request. A nil WorkspaceId means the user's personal space.
+	WorkspaceId   *string `json:"-"`
+	WorkspaceRole string  `json:"-"`
 }
 
 func (u *User) CleanPassword() {
 	u.Password = ""
 }
 
+// Location returns the user's time zone, UTC when unset or unknown.
+func (u User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
 type CreateDtoUser struct {
 	Name     string `json:"name"`
 	Password string `json:"password"`
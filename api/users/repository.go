@@ -11,4 +11,5 @@ type Repository interface {
 	Create(ctx context.Context, user User) error
 	Update(ctx context.Context, user User) error
 	Delete(ctx context.Context, id string) error
+	GetWorkspaceRole(ctx context.Context, workspaceId, userId string) (string, error)
 }
@@ -1,7 +1,9 @@
 package util
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -30,6 +32,9 @@ const (
 	DOMAIN       = "sbashirov0.fvds.ru"
 )
 
+// WorkspaceHeader selects the active workspace of a request.
+const WorkspaceHeader = "X-Workspace-Id"
+
 func ConfigPath(path, key string) string {
 	return fmt.Sprintf("%s.%s", path, key)
 }
@@ -40,6 +45,15 @@ func GeneratePasswordHash(password string) string {
 	return fmt.Sprintf("%x", hash.Sum([]byte(viper.GetString("secret.salt"))))
 }
 
+// GenerateRandomToken returns a hex encoded random token of n bytes, suitable for links shared outside the API.
+func GenerateRandomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generating random token failed: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
 func GenerateToken(ttl time.Duration, payload interface{}, secretJWTKey string) (string, error) {
 
 	jwtGen := jwt.New(jwt.SigningMethodHS256)
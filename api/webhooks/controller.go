@@ -0,0 +1,213 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id         = "id"
+	DeliveryId = "delivery_id"
+	Limit      = "limit"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 200
+	secretBytes            = 32
+)
+
+var (
+	RelativeWebhookUrl = "/webhooks"
+	GetByIdUrl         = fmt.Sprintf("/webhooks/:%s", Id)
+	DeliveriesUrl      = fmt.Sprintf("/webhooks/:%s/deliveries", Id)
+	RedeliverUrl       = fmt.Sprintf("/webhooks/:%s/deliveries/:%s/redeliver", Id, DeliveryId)
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitWebhookHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeWebhookUrl, h.GetAll)
+		api.POST(RelativeWebhookUrl, h.Create)
+		api.GET(GetByIdUrl, h.GetById)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.GET(DeliveriesUrl, h.GetDeliveries)
+		api.POST(RedeliverUrl, h.Redeliver)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	webhooks, err := h.Storage.GetAll(ctx, user.Id, user.WorkspaceId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get webhooks"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", webhooks))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	webhook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get webhook")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", webhook))
+}
+
+// Create registers an endpoint. Its signing secret is only ever returned here.
+func (h *Handler) Create(ctx *gin.Context) {
+	var webhookDto CreateWebhookDto
+	if err := ctx.ShouldBindJSON(&webhookDto); err != nil {
+		h.Log.Errorf("failed to bind webhook. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !h.validEndpoint(ctx, webhookDto.Url, webhookDto.Events) {
+		return
+	}
+
+	secret, err := util.GenerateRandomToken(secretBytes)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, apperror.NewJsonMessage("fail", "failed to create webhook"))
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	webhook := Webhook{UserId: user.Id, WorkspaceId: user.WorkspaceId, Url: webhookDto.Url, Events: events(webhookDto.Events), Secret: secret}
+	if err = h.Storage.Create(ctx, &webhook); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create webhook"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", webhook))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var webhookDto UpdateWebhookDto
+	if err := ctx.ShouldBindJSON(&webhookDto); err != nil {
+		h.Log.Errorf("failed to bind webhook. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !h.validEndpoint(ctx, webhookDto.Url, webhookDto.Events) {
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	webhook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get webhook")
+		return
+	}
+	webhook.Url, webhook.Events, webhook.Active = webhookDto.Url, events(webhookDto.Events), webhookDto.Active
+	if err = h.Storage.Update(ctx, &webhook); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to update webhook"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", webhook))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	if err := h.Storage.Delete(ctx, ctx.Param(Id), user.Id, user.WorkspaceId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to delete webhook")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+// GetDeliveries returns the most recent deliveries of the webhook, newest first.
+func (h *Handler) GetDeliveries(ctx *gin.Context) {
+	limit := defaultDeliveriesLimit
+	if value, ok := ctx.GetQuery(Limit); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid limit"))
+			return
+		}
+		limit = parsed
+		if limit > maxDeliveriesLimit {
+			limit = maxDeliveriesLimit
+		}
+	}
+
+	user := users.GetCurrentUser(ctx)
+	webhook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get webhook")
+		return
+	}
+	deliveries, err := h.Storage.GetDeliveries(ctx, webhook.Id, limit)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get deliveries"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", deliveries))
+}
+
+// Redeliver queues a copy of a delivery, which is sent even if the original
+// succeeded.
+func (h *Handler) Redeliver(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	webhook, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get webhook")
+		return
+	}
+	delivery, err := h.Storage.Redeliver(ctx, webhook.Id, ctx.Param(DeliveryId))
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to redeliver")
+		return
+	}
+	ctx.JSON(http.StatusAccepted, apperror.NewJsonMessage("success", delivery))
+}
+
+func (h *Handler) validEndpoint(ctx *gin.Context, rawUrl string, events []string) bool {
+	endpoint, err := url.Parse(rawUrl)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "url must be an absolute http or https url"))
+		return false
+	}
+	if err = checkHost(ctx, endpoint.Hostname()); err != nil {
+		message := "url host cannot be resolved"
+		if errors.Is(err, ErrBlockedAddress) {
+			message = "url must not point to a loopback, link-local or private address"
+		}
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return false
+	}
+	for _, event := range events {
+		if !Events[event] {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", fmt.Sprintf("unknown event %q", event)))
+			return false
+		}
+	}
+	return true
+}
+
+func events(events []string) []string {
+	if events == nil {
+		return make([]string, 0)
+	}
+	return events
+}
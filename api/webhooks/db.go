@@ -0,0 +1,269 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"time"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+// DisableAfterFailures is the number of consecutive failed attempts after
+// which a webhook is disabled until its owner turns it on again.
+const DisableAfterFailures = 20
+
+const webhookColumns = `w.id, w.user_id, w.workspace_id, w.url, w.events, w.active, w.failure_count, w.disabled_at, w.created_at`
+const deliveryColumns = `d.id, d.webhook_id, d.activity_id, d.event, d.payload, d.status, d.attempts, d.next_attempt_at,
+	d.response_status, d.last_error, d.created_at, d.delivered_at`
+
+// Deliveries are queued by a trigger on activity, see
+// pgconsole/create_webhook_deliveries_table.sql.
+var QueryGetAll = `SELECT ` + webhookColumns + ` FROM webhooks w
+	WHERE w.user_id = $1 AND w.workspace_id IS NOT DISTINCT FROM $2 ORDER BY w.created_at`
+var QueryGetById = `SELECT ` + webhookColumns + ` FROM webhooks w
+	WHERE w.id = $1 AND w.user_id = $2 AND w.workspace_id IS NOT DISTINCT FROM $3`
+var QueryCreate = `INSERT INTO webhooks (user_id, workspace_id, url, secret, events) VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, active, failure_count, disabled_at, created_at`
+var QueryUpdate = `UPDATE webhooks w SET url = $2, events = $3, active = $4,
+	failure_count = CASE WHEN $4 AND NOT w.active THEN 0 ELSE w.failure_count END,
+	disabled_at = CASE WHEN $4 THEN NULL ELSE w.disabled_at END
+	WHERE w.id = $1 RETURNING ` + webhookColumns
+var QueryDelete = `DELETE FROM webhooks WHERE id = $1 AND user_id = $2 AND workspace_id IS NOT DISTINCT FROM $3`
+var QueryGetDeliveries = `SELECT ` + deliveryColumns + ` FROM webhook_deliveries d
+	WHERE d.webhook_id = $1 ORDER BY d.created_at DESC LIMIT $2`
+var QueryRedeliver = `INSERT INTO webhook_deliveries AS d (webhook_id, activity_id, event, payload)
+	SELECT webhook_id, activity_id, event, payload FROM webhook_deliveries WHERE id = $2 AND webhook_id = $1
+	RETURNING ` + deliveryColumns
+
+// QueryClaimDue leases due deliveries by pushing their next attempt past the
+// lease, so that concurrent dispatchers, on this or another instance, skip them
+// while they are being sent.
+var QueryClaimDue = `UPDATE webhook_deliveries d SET attempts = d.attempts + 1,
+	next_attempt_at = now() + make_interval(secs => $2)
+	FROM webhooks w
+	WHERE w.id = d.webhook_id AND d.id IN (
+		SELECT p.id FROM webhook_deliveries p JOIN webhooks pw ON pw.id = p.webhook_id
+		WHERE p.status = 'pending' AND p.next_attempt_at <= now() AND pw.active
+		ORDER BY p.next_attempt_at LIMIT $1 FOR UPDATE OF p SKIP LOCKED)
+	RETURNING ` + deliveryColumns + `, w.url, w.secret`
+var QuerySucceeded = `UPDATE webhook_deliveries SET status = 'succeeded', response_status = $2, last_error = NULL,
+	delivered_at = now() WHERE id = $1`
+var QueryFailed = `UPDATE webhook_deliveries SET status = CASE WHEN $4::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+	response_status = $2, last_error = $3, next_attempt_at = COALESCE($4, next_attempt_at) WHERE id = $1`
+var QueryResetFailures = `UPDATE webhooks SET failure_count = 0 WHERE id = $1`
+var QueryCountFailure = `UPDATE webhooks SET failure_count = failure_count + 1,
+	active = active AND failure_count + 1 < $2,
+	disabled_at = CASE WHEN active AND failure_count + 1 >= $2 THEN now() ELSE disabled_at END
+	WHERE id = $1 RETURNING active`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string, workspaceId *string) ([]Webhook, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query webhooks. due to error: %v", err)
+		return nil, err
+	}
+	webhooks := make([]Webhook, 0)
+	for rows.Next() {
+		webhook, errScan := scanWebhook(rows)
+		if errScan != nil {
+			s.log.Errorf("failed to scan webhook. due to error: %v", errScan)
+			return nil, errScan
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string, workspaceId *string) (Webhook, error) {
+	webhook, err := scanWebhook(s.db.QueryRow(ctx, QueryGetById, id, userId, workspaceId))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Webhook{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get webhook by id=(%s), due to error: %v", id, err)
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (s *Storage) Create(ctx context.Context, webhook *Webhook) error {
+	err := s.db.QueryRow(ctx, QueryCreate, webhook.UserId, webhook.WorkspaceId, webhook.Url, webhook.Secret, webhook.Events).
+		Scan(&webhook.Id, &webhook.Active, &webhook.FailureCount, &webhook.DisabledAt, &webhook.CreatedAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create webhook. due to error: %v", err)
+		return err
+	}
+	return nil
+}
+
+// Update changes the endpoint of the webhook. Turning a disabled webhook back
+// on resets its failure count.
+func (s *Storage) Update(ctx context.Context, webhook *Webhook) error {
+	updated, err := scanWebhook(s.db.QueryRow(ctx, QueryUpdate, webhook.Id, webhook.Url, webhook.Events, webhook.Active))
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to update webhook id=(%s). due to error: %v", webhook.Id, err)
+		return err
+	}
+	*webhook = updated
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id, userId string, workspaceId *string) error {
+	tag, err := s.db.Exec(ctx, QueryDelete, id, userId, workspaceId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to delete webhook id=(%s). due to error: %v", id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Storage) GetDeliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error) {
+	rows, err := s.db.Query(ctx, QueryGetDeliveries, webhookId, limit)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query deliveries of webhook id=(%s). due to error: %v", webhookId, err)
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0)
+	for rows.Next() {
+		var delivery Delivery
+		if errScan := rows.Scan(deliveryFields(&delivery)...); errScan != nil {
+			s.log.Errorf("failed to scan delivery. due to error: %v", errScan)
+			return nil, errScan
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a new delivery with the payload of an earlier one.
+func (s *Storage) Redeliver(ctx context.Context, webhookId, deliveryId string) (delivery Delivery, err error) {
+	if err = s.db.QueryRow(ctx, QueryRedeliver, webhookId, deliveryId).Scan(deliveryFields(&delivery)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Delivery{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to redeliver delivery id=(%s). due to error: %v", deliveryId, err)
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// ClaimDue leases up to limit due deliveries of active webhooks and counts
+// the attempt. A delivery whose result is never recorded is retried once the
+// lease expires.
+func (s *Storage) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Job, error) {
+	rows, err := s.db.Query(ctx, QueryClaimDue, limit, lease.Seconds())
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to claim deliveries. due to error: %v", err)
+		return nil, err
+	}
+	jobs := make([]Job, 0)
+	for rows.Next() {
+		var job Job
+		if errScan := rows.Scan(append(deliveryFields(&job.Delivery), &job.Url, &job.Secret)...); errScan != nil {
+			s.log.Errorf("failed to scan delivery. due to error: %v", errScan)
+			return nil, errScan
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *Storage) RecordSuccess(ctx context.Context, delivery Delivery, responseStatus int) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QuerySucceeded, delivery.Id, responseStatus); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, QueryResetFailures, delivery.WebhookId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RecordFailure schedules the next attempt at retryAt, or fails the delivery
+// for good when retryAt is nil, and counts the failure against the webhook,
+// disabling it after DisableAfterFailures consecutive failures.
+func (s *Storage) RecordFailure(ctx context.Context, delivery Delivery, responseStatus *int, message string, retryAt *time.Time) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = tx.Exec(ctx, QueryFailed, delivery.Id, responseStatus, message, retryAt); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	var active bool
+	if err = tx.QueryRow(ctx, QueryCountFailure, delivery.WebhookId, DisableAfterFailures).Scan(&active); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if !active {
+		s.log.Infof("webhook id=(%s) is disabled after %d consecutive failures", delivery.WebhookId, DisableAfterFailures)
+	}
+	return tx.Commit(ctx)
+}
+
+func scanWebhook(row pgx.Row) (webhook Webhook, err error) {
+	err = row.Scan(&webhook.Id, &webhook.UserId, &webhook.WorkspaceId, &webhook.Url, &webhook.Events,
+		&webhook.Active, &webhook.FailureCount, &webhook.DisabledAt, &webhook.CreatedAt)
+	return webhook, err
+}
+
+func deliveryFields(d *Delivery) []interface{} {
+	return []interface{}{&d.Id, &d.WebhookId, &d.ActivityId, &d.Event, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt}
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
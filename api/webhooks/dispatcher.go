@@ -0,0 +1,148 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SignatureHeader = "X-Todo-Signature"
+	TimestampHeader = "X-Todo-Timestamp"
+	EventHeader     = "X-Todo-Event"
+	DeliveryHeader  = "X-Todo-Delivery"
+)
+
+// MaxAttempts is the number of attempts after which a delivery fails for good.
+const MaxAttempts = 10
+
+const (
+	dispatchInterval = 5 * time.Second
+	dispatchBatch    = 20
+	deliveryTimeout  = 10 * time.Second
+	// deliveryLease outlasts sending a whole batch to endpoints that all time
+	// out, so that no delivery is claimed again while it is still being sent.
+	deliveryLease  = dispatchBatch*deliveryTimeout + time.Minute
+	baseRetryDelay = 30 * time.Second
+	maxRetryDelay  = 6 * time.Hour
+	maxErrorLength = 500
+)
+
+// Dispatcher sends the queued deliveries. Deliveries are claimed with a lease
+// in Postgres, so every instance can run one.
+type Dispatcher struct {
+	storage *Storage
+	client  *http.Client
+	log     *logrus.Logger
+}
+
+func NewDispatcher(storage *Storage, log *logrus.Logger) *Dispatcher {
+	client := &http.Client{
+		Timeout:   deliveryTimeout,
+		Transport: newTransport(),
+		// a redirect is answered as is and counts as a failure
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &Dispatcher{storage: storage, client: client, log: log}
+}
+
+// Run sends the due deliveries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			jobs, err := d.storage.ClaimDue(ctx, dispatchBatch, deliveryLease)
+			if err != nil || len(jobs) == 0 {
+				break
+			}
+			for _, job := range jobs {
+				d.send(ctx, job)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, job Job) {
+	delivery := job.Delivery
+	timestamp := time.Now().Unix()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, job.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		d.fail(ctx, delivery, nil, err.Error())
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "todoproject-webhooks")
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(DeliveryHeader, delivery.Id)
+	request.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(SignatureHeader, Sign(job.Secret, timestamp, delivery.Payload))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		d.fail(ctx, delivery, nil, err.Error())
+		return
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		d.fail(ctx, delivery, &response.StatusCode, fmt.Sprintf("endpoint answered %s", response.Status))
+		return
+	}
+	if err = d.storage.RecordSuccess(ctx, delivery, response.StatusCode); err != nil {
+		d.log.Errorf("failed to record delivery id=(%s). due to error: %v", delivery.Id, err)
+	}
+}
+
+func (d *Dispatcher) fail(ctx context.Context, delivery Delivery, responseStatus *int, message string) {
+	if len(message) > maxErrorLength {
+		message = message[:maxErrorLength]
+	}
+	var retryAt *time.Time
+	if delivery.Attempts < MaxAttempts {
+		next := time.Now().Add(retryDelay(delivery.Attempts))
+		retryAt = &next
+	}
+	if err := d.storage.RecordFailure(ctx, delivery, responseStatus, message, retryAt); err != nil {
+		d.log.Errorf("failed to record delivery id=(%s). due to error: %v", delivery.Id, err)
+	}
+}
+
+// retryDelay doubles the delay after every attempt: 30s, 1m, 2m... up to 6h.
+func retryDelay(attempts int) time.Duration {
+	delay := baseRetryDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// Sign returns the signature header of a delivery: the hex HMAC-SHA256, keyed
+// with the webhook secret, of the timestamp header, a dot and the raw body.
+// Receivers should recompute it and reject stale timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
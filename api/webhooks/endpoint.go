@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrBlockedAddress is returned for endpoints on a loopback, link-local,
+// private or unspecified address. Webhooks must not reach into the network
+// the server runs in.
+var ErrBlockedAddress = errors.New("address is not allowed for webhooks")
+
+const resolveTimeout = 5 * time.Second
+
+// blockedNetworks are the ranges refused on top of those net.IP classifies:
+// "this network" and the carrier-grade NAT range.
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// allowedIP reports whether webhooks may be sent to the address.
+func allowedIP(ip net.IP) bool {
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resolves the host of an endpoint and refuses it with
+// ErrBlockedAddress when any of its addresses is not allowed.
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !allowedIP(ip) {
+			return ErrBlockedAddress
+		}
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !allowedIP(addr.IP) {
+			return ErrBlockedAddress
+		}
+	}
+	return nil
+}
+
+// controlDial refuses connections to addresses that are not allowed. It runs
+// on the resolved address, so a host whose DNS changed after it was checked
+// cannot reach the internal network either.
+func controlDial(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !allowedIP(net.ParseIP(host)) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+// newTransport dials directly, never through a proxy from the environment,
+// and only to allowed addresses.
+func newTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: deliveryTimeout, Control: controlDial}
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: deliveryTimeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := allowedIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("allowedIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	for _, host := range []string{"127.0.0.1", "169.254.169.254", "::1", "localhost"} {
+		if err := checkHost(context.Background(), host); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("checkHost(%q) = %v, want %v", host, err, ErrBlockedAddress)
+		}
+	}
+	if err := checkHost(context.Background(), "93.184.216.34"); err != nil {
+		t.Errorf("checkHost(public address) = %v, want nil", err)
+	}
+}
+
+// The dispatcher refuses to connect to a local endpoint even though it was
+// never checked at registration, as when its DNS changed afterwards.
+func TestDispatcherRefusesLocalEndpoints(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { reached = true }))
+	defer server.Close()
+
+	client := &http.Client{Transport: newTransport()}
+	_, err := client.Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get() error = %v, want %v", err, ErrBlockedAddress)
+	}
+	if reached {
+		t.Error("the local endpoint was reached")
+	}
+}
@@ -0,0 +1,75 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Events a webhook can subscribe to, named after the entity and the activity
+// kind. A webhook without events receives all of them.
+var Events = map[string]bool{
+	"todo.created":    true,
+	"todo.updated":    true,
+	"todo.deleted":    true,
+	"todo.assigned":   true,
+	"todo.unassigned": true,
+	"todo.completed":  true,
+	"todo.reopened":   true,
+	"todo.moved":      true,
+	"list.created":    true,
+	"list.updated":    true,
+	"list.deleted":    true,
+	"list.shared":     true,
+}
+
+type Webhook struct {
+	Id           string     `json:"id"`
+	UserId       string     `json:"user_id"`
+	WorkspaceId  *string    `json:"workspace_id"`
+	Url          string     `json:"url"`
+	Events       []string   `json:"events"`
+	Secret       string     `json:"secret,omitempty"`
+	Active       bool       `json:"active"`
+	FailureCount int        `json:"failure_count"`
+	DisabledAt   *time.Time `json:"disabled_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type Delivery struct {
+	Id             string          `json:"id"`
+	WebhookId      string          `json:"webhook_id"`
+	ActivityId     *int64          `json:"activity_id"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// Job is a delivery claimed by the dispatcher with what it needs to send it.
+type Job struct {
+	Delivery Delivery
+	Url      string
+	Secret   string
+}
+
+type CreateWebhookDto struct {
+	Url    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+}
+
+type UpdateWebhookDto struct {
+	Url    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+	Active bool     `json:"active"`
+}
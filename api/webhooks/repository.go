@@ -0,0 +1,19 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string, workspaceId *string) ([]Webhook, error)
+	GetById(ctx context.Context, id, userId string, workspaceId *string) (Webhook, error)
+	Create(ctx context.Context, webhook *Webhook) error
+	Update(ctx context.Context, webhook *Webhook) error
+	Delete(ctx context.Context, id, userId string, workspaceId *string) error
+	GetDeliveries(ctx context.Context, webhookId string, limit int) ([]Delivery, error)
+	Redeliver(ctx context.Context, webhookId, deliveryId string) (Delivery, error)
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Job, error)
+	RecordSuccess(ctx context.Context, delivery Delivery, responseStatus int) error
+	RecordFailure(ctx context.Context, delivery Delivery, responseStatus *int, message string, retryAt *time.Time) error
+}
@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"todoproject/api/lists"
+	"todoproject/api/todo"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id    = "id"
+	Force = "force"
+)
+
+const (
+	MaxStatuses   = 20
+	MaxNameLength = 50
+)
+
+var (
+	WorkflowUrl   = fmt.Sprintf("/lists/:%s/workflow", Id)
+	BoardUrl      = fmt.Sprintf("/lists/:%s/board", Id)
+	TransitionUrl = fmt.Sprintf("/todo/:%s/transition", Id)
+)
+
+type Handler struct {
+	Storage     *Storage
+	listStorage *lists.Storage
+	todoStorage *todo.Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, listStorage *lists.Storage, todoStorage *todo.Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, listStorage: listStorage, todoStorage: todoStorage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitWorkflowHandler(e *gin.Engine) {
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(WorkflowUrl, h.Get)
+		api.PUT(WorkflowUrl, h.Replace)
+		api.GET(BoardUrl, h.GetBoard)
+		api.POST(TransitionUrl, h.Transition)
+	}
+}
+
+func (h *Handler) Get(ctx *gin.Context) {
+	listId, ok := h.authorize(ctx, func(role lists.Role) bool { return true })
+	if !ok {
+		return
+	}
+	workflow, err := h.Storage.Get(ctx, listId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get workflow")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workflow))
+}
+
+// Replace sets the ordered statuses of the list, one of them terminal, and
+// its allowed transitions. It needs the manage permission on the list.
+func (h *Handler) Replace(ctx *gin.Context) {
+	var workflowDto WorkflowDto
+	if err := ctx.ShouldBindJSON(&workflowDto); err != nil {
+		h.Log.Errorf("failed to bind workflow. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if message := validateWorkflow(workflowDto); message != "" {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", message))
+		return
+	}
+
+	listId, ok := h.authorize(ctx, lists.Role.CanManage)
+	if !ok {
+		return
+	}
+	workflow := Workflow{ListId: listId}
+	if err := h.Storage.Replace(ctx, &workflow, workflowDto, users.GetCurrentUser(ctx).Id); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to replace workflow")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workflow))
+}
+
+// GetBoard returns the todos of the list grouped by status, in the order of
+// the workflow.
+func (h *Handler) GetBoard(ctx *gin.Context) {
+	listId, ok := h.authorize(ctx, func(role lists.Role) bool { return true })
+	if !ok {
+		return
+	}
+	workflow, err := h.Storage.Get(ctx, listId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get workflow")
+		return
+	}
+	user := users.GetCurrentUser(ctx)
+	todos, err := h.todoStorage.GetAllTodoByUserId(ctx, user.Id, user.WorkspaceId, &listId)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get todos"))
+		return
+	}
+	sort.SliceStable(todos, func(i, j int) bool { return todos[i].CreatedAt.Before(todos[j].CreatedAt) })
+
+	board := Board{ListId: listId, Columns: make([]Column, 0, len(workflow.Statuses)), NoStatus: make([]todo.Todo, 0)}
+	columns := make(map[string]int, len(workflow.Statuses))
+	for i, status := range workflow.Statuses {
+		board.Columns = append(board.Columns, Column{Status: status, Todos: make([]todo.Todo, 0)})
+		columns[status.Id] = i
+	}
+	for _, t := range todos {
+		if t.StatusId == nil {
+			board.NoStatus = append(board.NoStatus, t)
+			continue
+		}
+		if i, ok := columns[*t.StatusId]; ok {
+			board.Columns[i].Todos = append(board.Columns[i].Todos, t)
+		}
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", board))
+}
+
+// Transition moves the todo to another status of its list. Lists enforcing
+// transitions refuse moves they do not list with 409, and so does a move to
+// the terminal status while the todo is blocked unless force=true is given.
+func (h *Handler) Transition(ctx *gin.Context) {
+	var moveDto MoveTodoDto
+	if err := ctx.ShouldBindJSON(&moveDto); err != nil {
+		h.Log.Errorf("failed to bind transition. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	t, role, err := h.todoStorage.GetTodoById(ctx, ctx.Param(Id), user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get todo")
+		return
+	}
+	if !role.CanEdit() {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return
+	}
+	if t.ListId == nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "only todos on a list have a status"))
+		return
+	}
+
+	status, err := h.Storage.GetStatus(ctx, moveDto.StatusId)
+	if err != nil || status.ListId != *t.ListId {
+		ctx.AbortWithStatusJSON(http.StatusNotFound, apperror.NewJsonMessage("fail", "status not found on the list"))
+		return
+	}
+	if status.Terminal && t.IsBlocked && ctx.Query(Force) != "true" {
+		ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", gin.H{
+			"message": "todo is blocked by open todos, complete them first or force it", "blocked_by": t.BlockedBy,
+		}))
+		return
+	}
+
+	if err = h.todoStorage.Move(ctx, &t, status.Id, user.Id); err != nil {
+		if errors.Is(err, apperror.ErrConflict) {
+			ctx.AbortWithStatusJSON(http.StatusConflict, apperror.NewJsonMessage("fail", "the list does not allow this transition"))
+			return
+		}
+		apperror.AbortWithError(ctx, err, "failed to move todo")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", t))
+}
+
+// authorize checks the user's role on the list of the request. Lists the user
+// cannot see are 404.
+func (h *Handler) authorize(ctx *gin.Context, allowed func(lists.Role) bool) (string, bool) {
+	user := users.GetCurrentUser(ctx)
+	listId := ctx.Param(Id)
+	role, err := h.listStorage.GetRole(ctx, listId, user.Id, user.WorkspaceId)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get list")
+		return "", false
+	}
+	if !allowed(role) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return "", false
+	}
+	return listId, true
+}
+
+// validateWorkflow returns why the workflow cannot be saved, or an empty string.
+func validateWorkflow(dto WorkflowDto) string {
+	if len(dto.Statuses) > MaxStatuses {
+		return fmt.Sprintf("a list cannot have more than %d statuses", MaxStatuses)
+	}
+	names := make(map[string]bool, len(dto.Statuses))
+	ids := make(map[string]bool, len(dto.Statuses))
+	terminals := 0
+	for _, status := range dto.Statuses {
+		if status.Name == "" || len([]rune(status.Name)) > MaxNameLength {
+			return "status names must have between 1 and 50 characters"
+		}
+		if names[status.Name] {
+			return "status names must be unique"
+		}
+		names[status.Name] = true
+		if status.Id != nil {
+			if ids[*status.Id] {
+				return "a status is listed twice"
+			}
+			ids[*status.Id] = true
+		}
+		if status.Terminal {
+			terminals++
+		}
+	}
+	if len(dto.Statuses) > 0 && terminals != 1 {
+		return "exactly one status must be terminal"
+	}
+	for _, transition := range dto.Transitions {
+		if !names[transition.From] || !names[transition.To] {
+			return "transitions must refer to statuses by name"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,215 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/api/todo"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+const statusColumns = `s.id, s.list_id, s.name, s.position, s.terminal`
+
+// Permissions on the list are checked by the caller.
+var QueryGetStatuses = `SELECT ` + statusColumns + ` FROM list_statuses s WHERE s.list_id = $1 ORDER BY s.position`
+var QueryGetStatus = `SELECT ` + statusColumns + ` FROM list_statuses s WHERE s.id = $1`
+var QueryGetTransitions = `SELECT t.from_status_id, t.to_status_id FROM list_status_transitions t
+	JOIN list_statuses s ON s.id = t.from_status_id WHERE s.list_id = $1`
+var QueryGetEnforced = `SELECT enforce_transitions FROM lists WHERE id = $1`
+
+var QueryLockList = `SELECT id FROM lists WHERE id = $1 FOR UPDATE`
+var QueryClearTerminal = `UPDATE list_statuses SET terminal = false WHERE list_id = $1`
+var QueryUpdateStatus = `UPDATE list_statuses SET name = $3, position = $4, terminal = $5 WHERE id = $1 AND list_id = $2`
+var QueryCreateStatus = `INSERT INTO list_statuses (list_id, name, position, terminal) VALUES ($1, $2, $3, $4) RETURNING id`
+var QueryDeleteStatuses = `DELETE FROM list_statuses WHERE list_id = $1 AND NOT (id = ANY($2))`
+var QueryDeleteTransitions = `DELETE FROM list_status_transitions t USING list_statuses s
+	WHERE s.id = t.from_status_id AND s.list_id = $1`
+var QueryCreateTransition = `INSERT INTO list_status_transitions (from_status_id, to_status_id) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING`
+var QuerySetEnforced = `UPDATE lists SET enforce_transitions = $2 WHERE id = $1`
+
+// QueryPlaceTodos gives the todos of the list without a status the terminal
+// status when they are completed and the first open one otherwise.
+var QueryPlaceTodos = `UPDATE todo t SET status_id = (SELECT s.id FROM list_statuses s WHERE s.list_id = t.list_id
+		ORDER BY s.terminal = (t.completed_at IS NOT NULL) DESC, s.position LIMIT 1)
+	WHERE t.list_id = $1 AND t.status_id IS NULL`
+
+type Storage struct {
+	db    db.Client
+	todos *todo.Storage
+	log   *logrus.Logger
+}
+
+func NewStorage(db db.Client, todos *todo.Storage, log *logrus.Logger) *Storage {
+	return &Storage{db: db, todos: todos, log: log}
+}
+
+func (s *Storage) Get(ctx context.Context, listId string) (Workflow, error) {
+	workflow := Workflow{ListId: listId, Statuses: make([]Status, 0), Transitions: make([]Transition, 0)}
+
+	if err := s.db.QueryRow(ctx, QueryGetEnforced, listId).Scan(&workflow.EnforceTransitions); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Workflow{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get workflow of list id=(%s). due to error: %v", listId, err)
+		return Workflow{}, err
+	}
+
+	rows, err := s.db.Query(ctx, QueryGetStatuses, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query statuses. due to error: %v", err)
+		return Workflow{}, err
+	}
+	for rows.Next() {
+		var status Status
+		if errScan := scanStatus(rows, &status); errScan != nil {
+			s.log.Errorf("failed to scan status. due to error: %v", errScan)
+			return Workflow{}, errScan
+		}
+		workflow.Statuses = append(workflow.Statuses, status)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return Workflow{}, err
+	}
+
+	rows, err = s.db.Query(ctx, QueryGetTransitions, listId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query transitions. due to error: %v", err)
+		return Workflow{}, err
+	}
+	for rows.Next() {
+		var transition Transition
+		if errScan := rows.Scan(&transition.FromId, &transition.ToId); errScan != nil {
+			s.log.Errorf("failed to scan transition. due to error: %v", errScan)
+			return Workflow{}, errScan
+		}
+		workflow.Transitions = append(workflow.Transitions, transition)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return Workflow{}, err
+	}
+	return workflow, nil
+}
+
+func (s *Storage) GetStatus(ctx context.Context, id string) (status Status, err error) {
+	if err = scanStatus(s.db.QueryRow(ctx, QueryGetStatus, id), &status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Status{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to get status id=(%s). due to error: %v", id, err)
+		return Status{}, err
+	}
+	return status, nil
+}
+
+// Replace sets the workflow of the list in one transaction. Statuses missing
+// from the dto are deleted, their todos and the ones of a list getting its
+// first workflow are placed by their completion, and todos whose status
+// changed terminal state are completed or reopened accordingly, as if the user
+// moved them. The dto must have been validated. The workflow is reloaded on
+// success.
+func (s *Storage) Replace(ctx context.Context, workflow *Workflow, dto WorkflowDto, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	listId := workflow.ListId
+	if _, err = tx.Exec(ctx, QueryLockList, listId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, QueryClearTerminal, listId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+
+	ids := make([]string, 0, len(dto.Statuses))
+	idsByName := make(map[string]string, len(dto.Statuses))
+	for position, status := range dto.Statuses {
+		var id string
+		if status.Id != nil {
+			id = *status.Id
+			tag, err := tx.Exec(ctx, QueryUpdateStatus, id, listId, status.Name, position, status.Terminal)
+			if err != nil {
+				s.TraceQueryError(err)
+				s.log.Errorf("failed to update status id=(%s). due to error: %v", id, err)
+				return err
+			}
+			if tag.RowsAffected() == 0 {
+				return apperror.ErrNotFound
+			}
+		} else if err = tx.QueryRow(ctx, QueryCreateStatus, listId, status.Name, position, status.Terminal).Scan(&id); err != nil {
+			s.TraceQueryError(err)
+			s.log.Errorf("failed to create status of list id=(%s). due to error: %v", listId, err)
+			return err
+		}
+		ids = append(ids, id)
+		idsByName[status.Name] = id
+	}
+	if _, err = tx.Exec(ctx, QueryDeleteStatuses, listId, ids); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, QueryDeleteTransitions, listId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	for _, transition := range dto.Transitions {
+		if _, err = tx.Exec(ctx, QueryCreateTransition, idsByName[transition.From], idsByName[transition.To]); err != nil {
+			s.TraceQueryError(err)
+			return err
+		}
+	}
+	if _, err = tx.Exec(ctx, QuerySetEnforced, listId, dto.EnforceTransitions && len(dto.Statuses) > 0); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, QueryPlaceTodos, listId); err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to place todos of list id=(%s). due to error: %v", listId, err)
+		return err
+	}
+	activities, err := s.todos.SyncCompletion(ctx, tx, listId, userId)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	s.todos.Committed(ctx, activities...)
+	replaced, err := s.Get(ctx, listId)
+	if err != nil {
+		return err
+	}
+	*workflow = replaced
+	return nil
+}
+
+func scanStatus(row pgx.Row, status *Status) error {
+	return row.Scan(&status.Id, &status.ListId, &status.Name, &status.Position, &status.Terminal)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
@@ -0,0 +1,64 @@
+package workflow
+
+import "todoproject/api/todo"
+
+// Status is a column of the board of a list. Todos in the terminal status are
+// completed.
+type Status struct {
+	Id       string `json:"id"`
+	ListId   string `json:"list_id"`
+	Name     string `json:"name"`
+	Position int    `json:"position"`
+	Terminal bool   `json:"terminal"`
+}
+
+type Transition struct {
+	FromId string `json:"from_id"`
+	ToId   string `json:"to_id"`
+}
+
+// Workflow is the ordered statuses of a list. When EnforceTransitions is set,
+// todos only move along the listed transitions.
+type Workflow struct {
+	ListId             string       `json:"list_id"`
+	Statuses           []Status     `json:"statuses"`
+	Transitions        []Transition `json:"transitions"`
+	EnforceTransitions bool         `json:"enforce_transitions"`
+}
+
+// WorkflowDto replaces the workflow of a list. Statuses are given in order,
+// existing ones by id, and transitions refer to statuses by name. An empty
+// list of statuses removes the workflow.
+type WorkflowDto struct {
+	Statuses           []StatusDto     `json:"statuses"`
+	Transitions        []TransitionDto `json:"transitions"`
+	EnforceTransitions bool            `json:"enforce_transitions"`
+}
+
+type StatusDto struct {
+	Id       *string `json:"id"`
+	Name     string  `json:"name"`
+	Terminal bool    `json:"terminal"`
+}
+
+type TransitionDto struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type MoveTodoDto struct {
+	StatusId string `json:"status_id" binding:"required"`
+}
+
+// Board groups the todos of a list by status. NoStatus holds the todos of a
+// list without a workflow.
+type Board struct {
+	ListId   string      `json:"list_id"`
+	Columns  []Column    `json:"columns"`
+	NoStatus []todo.Todo `json:"no_status"`
+}
+
+type Column struct {
+	Status Status      `json:"status"`
+	Todos  []todo.Todo `json:"todos"`
+}
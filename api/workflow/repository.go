@@ -0,0 +1,9 @@
+package workflow
+
+import "context"
+
+type Repository interface {
+	Get(ctx context.Context, listId string) (Workflow, error)
+	GetStatus(ctx context.Context, id string) (Status, error)
+	Replace(ctx context.Context, workflow *Workflow, dto WorkflowDto, userId string) error
+}
@@ -0,0 +1,319 @@
+package workspaces
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"todoproject/api/users"
+	"todoproject/api/util"
+	"todoproject/apperror"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	Id          = "id"
+	UserId      = "user_id"
+	Token       = "token"
+	WorkspaceId = "workspace_id"
+	Path        = "path"
+)
+
+const DefaultInviteTTL = 7 * 24 * time.Hour
+
+var (
+	RelativeWorkspaceUrl = "/workspaces"
+	GetByIdUrl           = fmt.Sprintf("/workspaces/:%s", Id)
+	MembersUrl           = fmt.Sprintf("/workspaces/:%s/members", Id)
+	MemberUrl            = fmt.Sprintf("/workspaces/:%s/members/:%s", Id, UserId)
+	InvitesUrl           = fmt.Sprintf("/workspaces/:%s/invites", Id)
+	InviteUrl            = fmt.Sprintf("/workspaces/:%s/invites/:%s", Id, Token)
+	JoinUrl              = fmt.Sprintf("/workspaces/join/:%s", Token)
+	PrefixUrl            = fmt.Sprintf("/w/:%s/*%s", WorkspaceId, Path)
+)
+
+type Handler struct {
+	Storage     *Storage
+	userHandler *users.Handler
+	Log         *logrus.Logger
+}
+
+func NewHandler(storage *Storage, userHandler *users.Handler, log *logrus.Logger) *Handler {
+	return &Handler{Storage: storage, userHandler: userHandler, Log: log}
+}
+
+func (h *Handler) InitWorkspaceHandler(e *gin.Engine) {
+	e.Any(util.ApiV1+PrefixUrl, h.WorkspacePrefix(e))
+
+	api := e.Group(util.ApiV1, h.userHandler.IsLogin())
+	{
+		api.GET(RelativeWorkspaceUrl, h.GetAll)
+		api.GET(GetByIdUrl, h.GetById)
+		api.POST(RelativeWorkspaceUrl, h.Create)
+		api.PUT(GetByIdUrl, h.Update)
+		api.DELETE(GetByIdUrl, h.Delete)
+		api.GET(MembersUrl, h.GetMembers)
+		api.PUT(MemberUrl, h.ChangeRole)
+		api.DELETE(MemberUrl, h.RemoveMember)
+		api.GET(InvitesUrl, h.GetInvites)
+		api.POST(InvitesUrl, h.CreateInvite)
+		api.DELETE(InviteUrl, h.RevokeInvite)
+		api.POST(JoinUrl, h.Join)
+	}
+}
+
+// WorkspacePrefix serves /api/w/<workspace_id>/... by selecting the workspace
+// through util.WorkspaceHeader and routing the rest of the path as usual.
+func (h *Handler) WorkspacePrefix(e *gin.Engine) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request.Header.Set(util.WorkspaceHeader, ctx.Param(WorkspaceId))
+		ctx.Request.URL.Path = util.ApiV1 + ctx.Param(Path)
+		e.HandleContext(ctx)
+	}
+}
+
+func (h *Handler) GetAll(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	workspaces, err := h.Storage.GetAll(ctx, user.Id)
+	if err != nil {
+		h.Log.Errorf("failed to get workspaces. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get workspaces"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workspaces))
+}
+
+func (h *Handler) GetById(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	workspace, err := h.Storage.GetById(ctx, ctx.Param(Id), user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get workspace")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workspace))
+}
+
+func (h *Handler) Create(ctx *gin.Context) {
+	var workspaceDto CreateWorkspaceDto
+	if err := ctx.ShouldBindJSON(&workspaceDto); err != nil {
+		h.Log.Errorf("failed to bind workspace. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if workspaceDto.DefaultVisibility != "" && !IsVisibility(workspaceDto.DefaultVisibility) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid default visibility"))
+		return
+	}
+
+	user := users.GetCurrentUser(ctx)
+	workspace := Workspace{Name: workspaceDto.Name, DefaultVisibility: workspaceDto.DefaultVisibility}
+	if err := h.Storage.Create(ctx, &workspace, user.Id); err != nil {
+		h.Log.Errorf("failed to create workspace. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create workspace"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", workspace))
+}
+
+func (h *Handler) Update(ctx *gin.Context) {
+	var workspaceDto UpdateWorkspaceDto
+	if err := ctx.ShouldBindJSON(&workspaceDto); err != nil {
+		h.Log.Errorf("failed to bind workspace. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if workspaceDto.DefaultVisibility != "" && !IsVisibility(workspaceDto.DefaultVisibility) {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "invalid default visibility"))
+		return
+	}
+
+	id := ctx.Param(Id)
+	role, ok := h.authorize(ctx, id, Role.CanManage)
+	if !ok {
+		return
+	}
+
+	workspace := Workspace{Id: id, Name: workspaceDto.Name, DefaultVisibility: workspaceDto.DefaultVisibility, Role: role}
+	if err := h.Storage.Update(ctx, &workspace); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to update workspace"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workspace))
+}
+
+func (h *Handler) Delete(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, func(role Role) bool { return role == RoleOwner }); !ok {
+		return
+	}
+
+	if err := h.Storage.Delete(ctx, id); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to delete workspace"))
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "deleted"))
+}
+
+func (h *Handler) GetMembers(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, func(Role) bool { return true }); !ok {
+		return
+	}
+
+	members, err := h.Storage.GetMembers(ctx, id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get members"))
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", members))
+}
+
+func (h *Handler) ChangeRole(ctx *gin.Context) {
+	var roleDto ChangeRoleDto
+	if err := ctx.ShouldBindJSON(&roleDto); err != nil {
+		h.Log.Errorf("failed to bind role. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !roleDto.Role.IsAssignable() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "role must be one of admin, member or guest"))
+		return
+	}
+
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	if err := h.Storage.ChangeRole(ctx, id, ctx.Param(UserId), roleDto.Role); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to change role")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", roleDto.Role))
+}
+
+// RemoveMember lets admins remove anyone but the owner, and every member leave the workspace.
+func (h *Handler) RemoveMember(ctx *gin.Context) {
+	id, userId := ctx.Param(Id), ctx.Param(UserId)
+
+	user := users.GetCurrentUser(ctx)
+	allowed := Role.CanManage
+	if user.Id == userId {
+		allowed = func(Role) bool { return true }
+	}
+	if _, ok := h.authorize(ctx, id, allowed); !ok {
+		return
+	}
+
+	if err := h.Storage.RemoveMember(ctx, id, userId); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to remove member")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "removed"))
+}
+
+func (h *Handler) GetInvites(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	invites, err := h.Storage.GetInvites(ctx, id)
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to get invites"))
+		return
+	}
+	for i := range invites {
+		invites[i].Link = InviteLink(invites[i].Token)
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", invites))
+}
+
+func (h *Handler) CreateInvite(ctx *gin.Context) {
+	var inviteDto CreateInviteDto
+	if err := ctx.ShouldBindJSON(&inviteDto); err != nil {
+		h.Log.Errorf("failed to bind invite. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", err.Error()))
+		return
+	}
+	if !inviteDto.Role.IsAssignable() {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "role must be one of admin, member or guest"))
+		return
+	}
+
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	token, err := util.GenerateRandomToken(24)
+	if err != nil {
+		h.Log.Errorf("failed to generate invite token. due to error: %v", err)
+		ctx.AbortWithStatusJSON(http.StatusInternalServerError, apperror.NewJsonMessage("fail", "failed to create invite"))
+		return
+	}
+
+	ttl := DefaultInviteTTL
+	if inviteDto.ExpiresInHours > 0 {
+		ttl = time.Duration(inviteDto.ExpiresInHours) * time.Hour
+	}
+
+	user := users.GetCurrentUser(ctx)
+	invite := Invite{
+		Token:       token,
+		WorkspaceId: id,
+		Role:        inviteDto.Role,
+		CreatedBy:   user.Id,
+		ExpiresAt:   time.Now().UTC().Add(ttl),
+		Link:        InviteLink(token),
+	}
+	if err := h.Storage.CreateInvite(ctx, &invite); err != nil {
+		ctx.AbortWithStatusJSON(http.StatusBadRequest, apperror.NewJsonMessage("fail", "failed to create invite"))
+		return
+	}
+	ctx.JSON(http.StatusCreated, apperror.NewJsonMessage("success", invite))
+}
+
+func (h *Handler) RevokeInvite(ctx *gin.Context) {
+	id := ctx.Param(Id)
+	if _, ok := h.authorize(ctx, id, Role.CanManage); !ok {
+		return
+	}
+
+	if err := h.Storage.RevokeInvite(ctx, id, ctx.Param(Token)); err != nil {
+		apperror.AbortWithError(ctx, err, "failed to revoke invite")
+		return
+	}
+	ctx.JSON(http.StatusNoContent, apperror.NewJsonMessage("success", "revoked"))
+}
+
+func (h *Handler) Join(ctx *gin.Context) {
+	user := users.GetCurrentUser(ctx)
+	workspace, err := h.Storage.Join(ctx, ctx.Param(Token), user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to join workspace")
+		return
+	}
+	ctx.JSON(http.StatusOK, apperror.NewJsonMessage("success", workspace))
+}
+
+// authorize checks the current user's role in the workspace. Non members
+// get 404 so that the existence of the workspace is not leaked.
+func (h *Handler) authorize(ctx *gin.Context, id string, allowed func(Role) bool) (Role, bool) {
+	user := users.GetCurrentUser(ctx)
+	role, err := h.Storage.GetRole(ctx, id, user.Id)
+	if err != nil {
+		apperror.AbortWithError(ctx, err, "failed to get workspace")
+		return "", false
+	}
+	if !allowed(role) {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, apperror.NewJsonMessage("fail", "not enough permissions"))
+		return role, false
+	}
+	return role, true
+}
+
+func InviteLink(token string) string {
+	return fmt.Sprintf("https://%s%s/workspaces/join/%s", util.DOMAIN, util.ApiV1, token)
+}
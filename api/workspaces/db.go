@@ -0,0 +1,289 @@
+package workspaces
+
+import (
+	"context"
+	"errors"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"todoproject/apperror"
+	"todoproject/db"
+)
+
+var QueryGetAll = `SELECT w.id, w.name, w.default_visibility, m.role FROM workspaces w
+	JOIN workspace_members m ON m.workspace_id = w.id
+	WHERE m.user_id = $1`
+var QueryGetById = `SELECT w.id, w.name, w.default_visibility, m.role FROM workspaces w
+	JOIN workspace_members m ON m.workspace_id = w.id
+	WHERE w.id = $1 AND m.user_id = $2`
+var QueryGetRole = `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+var QueryCreate = `INSERT INTO workspaces (name, default_visibility) VALUES ($1, COALESCE(NULLIF($2, ''), 'private'))
+	RETURNING id, default_visibility`
+var QueryCreateMember = `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+var QueryUpdate = `UPDATE workspaces SET name = $1, default_visibility = COALESCE(NULLIF($2, ''), default_visibility)
+	WHERE id = $3 RETURNING default_visibility`
+var QueryDelete = `DELETE FROM workspaces WHERE id = $1`
+var QueryGetMembers = `SELECT workspace_id, user_id, role FROM workspace_members WHERE workspace_id = $1`
+var QueryChangeRole = `UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2 AND role <> 'owner'`
+var QueryRemoveMember = `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2 AND role <> 'owner'`
+var QueryRemoveListMembers = `DELETE FROM list_members m USING lists l
+	WHERE l.id = m.list_id AND l.workspace_id = $1 AND m.user_id = $2 AND m.role <> 'owner'`
+var QueryGetInvites = `SELECT token, workspace_id, role, created_by, expires_at FROM workspace_invites
+	WHERE workspace_id = $1 AND expires_at > now()`
+var QueryCreateInvite = `INSERT INTO workspace_invites (token, workspace_id, role, created_by, expires_at)
+	VALUES ($1, $2, $3, $4, $5)`
+var QueryRevokeInvite = `DELETE FROM workspace_invites WHERE workspace_id = $1 AND token = $2`
+var QueryGetInvite = `SELECT i.workspace_id, i.role, w.name, w.default_visibility FROM workspace_invites i
+	JOIN workspaces w ON w.id = i.workspace_id
+	WHERE i.token = $1 AND i.expires_at > now()`
+
+type Storage struct {
+	db  db.Client
+	log *logrus.Logger
+}
+
+func NewStorage(db db.Client, log *logrus.Logger) *Storage {
+	return &Storage{db: db, log: log}
+}
+
+func (s *Storage) GetAll(ctx context.Context, userId string) ([]Workspace, error) {
+	rows, err := s.db.Query(ctx, QueryGetAll, userId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to query workspaces. due to error: %v", err)
+		return nil, err
+	}
+	workspaces := make([]Workspace, 0)
+	for rows.Next() {
+		var workspace Workspace
+		errScan := rows.Scan(&workspace.Id, &workspace.Name, &workspace.DefaultVisibility, &workspace.Role)
+		if errScan != nil {
+			s.log.Errorf("failed to scan workspace. due to error: %v", errScan)
+			return nil, errScan
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+func (s *Storage) GetById(ctx context.Context, id, userId string) (workspace Workspace, err error) {
+	errQuery := s.db.QueryRow(ctx, QueryGetById, id, userId).
+		Scan(&workspace.Id, &workspace.Name, &workspace.DefaultVisibility, &workspace.Role)
+	if errQuery != nil {
+		if errors.Is(errQuery, pgx.ErrNoRows) {
+			return Workspace{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(errQuery)
+		s.log.Errorf("failed to get workspace by id=(%s), due to error: %v", id, errQuery)
+		return Workspace{}, errQuery
+	}
+	return workspace, nil
+}
+
+// GetRole returns the role of a workspace member, non members get apperror.ErrNotFound.
+func (s *Storage) GetRole(ctx context.Context, id, userId string) (role Role, err error) {
+	errQuery := s.db.QueryRow(ctx, QueryGetRole, id, userId).Scan(&role)
+	if errQuery != nil {
+		if errors.Is(errQuery, pgx.ErrNoRows) {
+			return "", apperror.ErrNotFound
+		}
+		s.TraceQueryError(errQuery)
+		s.log.Errorf("failed to get role in workspace id=(%s), due to error: %v", id, errQuery)
+		return "", errQuery
+	}
+	return role, nil
+}
+
+func (s *Storage) Create(ctx context.Context, workspace *Workspace, ownerId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, QueryCreate, workspace.Name, workspace.DefaultVisibility).
+		Scan(&workspace.Id, &workspace.DefaultVisibility)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if _, err = tx.Exec(ctx, QueryCreateMember, workspace.Id, ownerId, RoleOwner); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	workspace.Role = RoleOwner
+	return tx.Commit(ctx)
+}
+
+func (s *Storage) Update(ctx context.Context, workspace *Workspace) error {
+	errUpdate := s.db.QueryRow(ctx, QueryUpdate, workspace.Name, workspace.DefaultVisibility, workspace.Id).
+		Scan(&workspace.DefaultVisibility)
+	if errUpdate != nil {
+		s.TraceQueryError(errUpdate)
+		s.log.Errorf("failed to update workspace id=(%s). due to error: %v", workspace.Id, errUpdate)
+		return errUpdate
+	}
+	return nil
+}
+
+func (s *Storage) Delete(ctx context.Context, id string) error {
+	_, errDelete := s.db.Exec(ctx, QueryDelete, id)
+	if errDelete != nil {
+		s.TraceQueryError(errDelete)
+		s.log.Errorf("failed to delete workspace id=(%s). due to error: %v", id, errDelete)
+		return errDelete
+	}
+	return nil
+}
+
+func (s *Storage) GetMembers(ctx context.Context, id string) ([]Member, error) {
+	rows, err := s.db.Query(ctx, QueryGetMembers, id)
+	if err != nil {
+		s.TraceQueryError(err)
+		return nil, err
+	}
+	members := make([]Member, 0)
+	for rows.Next() {
+		var member Member
+		errScan := rows.Scan(&member.WorkspaceId, &member.UserId, &member.Role)
+		if errScan != nil {
+			s.log.Errorf("failed to scan workspace member. due to error: %v", errScan)
+			return nil, errScan
+		}
+		members = append(members, member)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *Storage) ChangeRole(ctx context.Context, id, userId string, role Role) error {
+	tag, err := s.db.Exec(ctx, QueryChangeRole, id, userId, role)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to change role of user_id=(%s) in workspace id=(%s). due to error: %v", userId, id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// RemoveMember removes the user from the workspace together with the
+// memberships the user holds on its lists.
+func (s *Storage) RemoveMember(ctx context.Context, id, userId string) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, QueryRemoveMember, id, userId)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to remove user_id=(%s) from workspace id=(%s). due to error: %v", userId, id, err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	if _, err = tx.Exec(ctx, QueryRemoveListMembers, id, userId); err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *Storage) GetInvites(ctx context.Context, id string) ([]Invite, error) {
+	rows, err := s.db.Query(ctx, QueryGetInvites, id)
+	if err != nil {
+		s.TraceQueryError(err)
+		return nil, err
+	}
+	invites := make([]Invite, 0)
+	for rows.Next() {
+		var invite Invite
+		errScan := rows.Scan(&invite.Token, &invite.WorkspaceId, &invite.Role, &invite.CreatedBy, &invite.ExpiresAt)
+		if errScan != nil {
+			s.log.Errorf("failed to scan workspace invite. due to error: %v", errScan)
+			return nil, errScan
+		}
+		invites = append(invites, invite)
+	}
+	if err = rows.Err(); err != nil {
+		s.log.Errorf("failed to next row. due to error: %v", err)
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (s *Storage) CreateInvite(ctx context.Context, invite *Invite) error {
+	_, err := s.db.Exec(ctx, QueryCreateInvite, invite.Token, invite.WorkspaceId, invite.Role, invite.CreatedBy, invite.ExpiresAt)
+	if err != nil {
+		s.TraceQueryError(err)
+		s.log.Errorf("failed to create invite to workspace id=(%s). due to error: %v", invite.WorkspaceId, err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) RevokeInvite(ctx context.Context, id, token string) error {
+	tag, err := s.db.Exec(ctx, QueryRevokeInvite, id, token)
+	if err != nil {
+		s.TraceQueryError(err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperror.ErrNotFound
+	}
+	return nil
+}
+
+// Join adds the user to the workspace of a valid invite link. Users who are
+// already members keep their current role.
+func (s *Storage) Join(ctx context.Context, token, userId string) (workspace Workspace, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		s.TraceQueryError(err)
+		return Workspace{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, QueryGetInvite, token).
+		Scan(&workspace.Id, &workspace.Role, &workspace.Name, &workspace.DefaultVisibility)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Workspace{}, apperror.ErrNotFound
+		}
+		s.TraceQueryError(err)
+		return Workspace{}, err
+	}
+	if _, err = tx.Exec(ctx, QueryCreateMember, workspace.Id, userId, workspace.Role); err != nil {
+		s.TraceQueryError(err)
+		return Workspace{}, err
+	}
+	if err = tx.QueryRow(ctx, QueryGetRole, workspace.Id, userId).Scan(&workspace.Role); err != nil {
+		s.TraceQueryError(err)
+		return Workspace{}, err
+	}
+	return workspace, tx.Commit(ctx)
+}
+
+func (s *Storage) TraceQueryError(err error) {
+	if pgErr, ok := err.(*pgconn.PgError); ok {
+		s.log.Errorf("SQL Error: %s, Detail: %s, Where: %s, Code: %s",
+			pgErr.Message, pgErr.Detail, pgErr.Where, pgErr.Code)
+	} else {
+		s.log.Error(err)
+	}
+}
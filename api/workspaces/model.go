@@ -0,0 +1,71 @@
+package workspaces
+
+import "time"
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleGuest  Role = "guest"
+)
+
+// IsAssignable reports whether the role can be granted through an invite or a role change.
+func (r Role) IsAssignable() bool {
+	return r == RoleAdmin || r == RoleMember || r == RoleGuest
+}
+
+func (r Role) CanManage() bool {
+	return r == RoleOwner || r == RoleAdmin
+}
+
+const (
+	VisibilityPrivate   = "private"
+	VisibilityWorkspace = "workspace"
+)
+
+func IsVisibility(visibility string) bool {
+	return visibility == VisibilityPrivate || visibility == VisibilityWorkspace
+}
+
+type Workspace struct {
+	Id                string `json:"id"`
+	Name              string `json:"name"`
+	DefaultVisibility string `json:"default_visibility"`
+	Role              Role   `json:"role,omitempty"`
+}
+
+type Member struct {
+	WorkspaceId string `json:"workspace_id"`
+	UserId      string `json:"user_id"`
+	Role        Role   `json:"role"`
+}
+
+type Invite struct {
+	Token       string    `json:"token"`
+	WorkspaceId string    `json:"workspace_id"`
+	Role        Role      `json:"role"`
+	CreatedBy   string    `json:"created_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Link        string    `json:"link,omitempty"`
+}
+
+type CreateWorkspaceDto struct {
+	Name              string `json:"name" binding:"required"`
+	DefaultVisibility string `json:"default_visibility"`
+}
+
+type UpdateWorkspaceDto struct {
+	Name              string `json:"name" binding:"required"`
+	DefaultVisibility string `json:"default_visibility"`
+}
+
+type CreateInviteDto struct {
+	Role           Role `json:"role" binding:"required"`
+	ExpiresInHours int  `json:"expires_in_hours"`
+}
+
+type ChangeRoleDto struct {
+	Role Role `json:"role" binding:"required"`
+}
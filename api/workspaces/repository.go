@@ -0,0 +1,19 @@
+package workspaces
+
+import "context"
+
+type Repository interface {
+	GetAll(ctx context.Context, userId string) ([]Workspace, error)
+	GetById(ctx context.Context, id, userId string) (Workspace, error)
+	GetRole(ctx context.Context, id, userId string) (Role, error)
+	Create(ctx context.Context, workspace *Workspace, ownerId string) error
+	Update(ctx context.Context, workspace *Workspace) error
+	Delete(ctx context.Context, id string) error
+	GetMembers(ctx context.Context, id string) ([]Member, error)
+	ChangeRole(ctx context.Context, id, userId string, role Role) error
+	RemoveMember(ctx context.Context, id, userId string) error
+	GetInvites(ctx context.Context, id string) ([]Invite, error)
+	CreateInvite(ctx context.Context, invite *Invite) error
+	RevokeInvite(ctx context.Context, id, token string) error
+	Join(ctx context.Context, token, userId string) (Workspace, error)
+}
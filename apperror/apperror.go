@@ -1,6 +1,17 @@
 package apperror
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	ErrNotFound  = NewAppError("not found")
+	ErrForbidden = NewAppError("not enough permissions")
+	ErrConflict  = NewAppError("already exists")
+)
 
 type AppError struct {
 	Message string
@@ -20,3 +31,27 @@ func NewJsonMessage(status string, obj interface{}) gin.H {
 		"message": obj,
 	}
 }
+
+// StatusCode maps the shared errors to their HTTP status, anything else is a bad request.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// AbortWithError aborts with the status of err. Shared errors are reported as
+// is, any other error is replaced by message so that internals are not leaked.
+func AbortWithError(ctx *gin.Context, err error, message string) {
+	status := StatusCode(err)
+	if status != http.StatusBadRequest {
+		message = err.Error()
+	}
+	ctx.AbortWithStatusJSON(status, NewJsonMessage("fail", message))
+}
@@ -1,14 +1,32 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"runtime"
 	"strings"
 	"time"
+	"todoproject/api/activity"
+	"todoproject/api/automation"
+	"todoproject/api/customfields"
+	"todoproject/api/delta"
+	"todoproject/api/goals"
+	"todoproject/api/habits"
+	"todoproject/api/inbound"
+	"todoproject/api/lists"
+	"todoproject/api/notifications"
+	"todoproject/api/schedule"
+	"todoproject/api/smartlists"
+	"todoproject/api/stats"
+	"todoproject/api/templates"
 	"todoproject/api/todo"
+	"todoproject/api/tracking"
 	"todoproject/api/users"
 	"todoproject/api/util"
+	"todoproject/api/webhooks"
+	"todoproject/api/workflow"
+	"todoproject/api/workspaces"
 	"todoproject/db"
 
 	"github.com/gin-contrib/cors"
@@ -68,12 +86,125 @@ func runApp() {
 	userHandler := users.NewHandler(storageUsers, config, logger)
 	userHandler.InitUserHandler(server)
 
+	// init storage workspaces
+	storageWorkspaces := workspaces.NewStorage(client, logger)
+	// init workspaces controller
+	workspacesHandler := workspaces.NewHandler(storageWorkspaces, userHandler, logger)
+	workspacesHandler.InitWorkspaceHandler(server)
+
+	// init storage activity
+	broker := activity.NewBroker()
+	storageActivity := activity.NewStorage(client, broker, logger)
+	// init activity controller
+	activityHandler := activity.NewHandler(storageActivity, broker, userHandler, logger)
+	activityHandler.InitActivityHandler(server)
+	// fan out the activities of the other instances
+	listener := activity.NewListener(config, storageActivity, broker, logger)
+	go listener.Run(context.Background())
+
+	// init storage lists
+	storageLists := lists.NewStorage(client, storageActivity, logger)
+	// init lists controller
+	listsHandler := lists.NewHandler(storageLists, userHandler, logger)
+	listsHandler.InitListHandler(server)
+
+	// init storage notifications
+	storageNotifications := notifications.NewStorage(client, logger)
+	// init notifications controller
+	notificationsHandler := notifications.NewHandler(storageNotifications, userHandler, logger)
+	notificationsHandler.InitNotificationHandler(server)
+
 	// init storage todos
-	storageTodos := todo.NewStorage(client, logger)
+	storageTodos := todo.NewStorage(client, storageNotifications, storageActivity, logger)
 	// init storage controller
-	todosHandler := todo.NewHandler(storageTodos, userHandler, logger)
+	todosHandler := todo.NewHandler(storageTodos, storageLists, storageActivity, userHandler, logger)
 	todosHandler.InitTodoHandler(server)
 
+	// init storage sync
+	storageSync := delta.NewStorage(client, logger)
+	// init sync controller
+	syncHandler := delta.NewHandler(storageSync, storageTodos, storageLists, userHandler, logger)
+	syncHandler.InitSyncHandler(server)
+
+	// init storage webhooks
+	storageWebhooks := webhooks.NewStorage(client, logger)
+	// init webhooks controller
+	webhooksHandler := webhooks.NewHandler(storageWebhooks, userHandler, logger)
+	webhooksHandler.InitWebhookHandler(server)
+	// send the queued deliveries
+	dispatcher := webhooks.NewDispatcher(storageWebhooks, logger)
+	go dispatcher.Run(context.Background())
+
+	// init storage inbound hooks
+	storageInbound := inbound.NewStorage(client, logger)
+	// init inbound hooks controller
+	inboundHandler := inbound.NewHandler(storageInbound, storageTodos, storageLists, userHandler, logger)
+	inboundHandler.InitInboundHandler(server)
+
+	// init storage stats
+	storageStats := stats.NewStorage(client, logger)
+	// init stats controller
+	statsHandler := stats.NewHandler(storageStats, userHandler, logger)
+	statsHandler.InitStatsHandler(server)
+
+	// init storage templates
+	storageTemplates := templates.NewStorage(client, storageLists, storageTodos, logger)
+	// init templates controller
+	templatesHandler := templates.NewHandler(storageTemplates, storageLists, storageTodos, userHandler, logger)
+	templatesHandler.InitTemplateHandler(server)
+
+	// init storage time tracking
+	storageTracking := tracking.NewStorage(client, logger)
+	// init time tracking controller
+	trackingHandler := tracking.NewHandler(storageTracking, userHandler, logger)
+	trackingHandler.InitTrackingHandler(server)
+
+	// init storage workflows
+	storageWorkflow := workflow.NewStorage(client, storageTodos, logger)
+	// init workflows controller
+	workflowHandler := workflow.NewHandler(storageWorkflow, storageLists, storageTodos, userHandler, logger)
+	workflowHandler.InitWorkflowHandler(server)
+
+	// init storage smart lists
+	storageSmartLists := smartlists.NewStorage(client, logger)
+	// init smart lists controller
+	smartListsHandler := smartlists.NewHandler(storageSmartLists, storageLists, storageTodos, userHandler, logger)
+	smartListsHandler.InitSmartListHandler(server)
+
+	// init storage custom fields
+	storageCustomFields := customfields.NewStorage(client, logger)
+	// init custom fields controller
+	customFieldsHandler := customfields.NewHandler(storageCustomFields, storageLists, storageTodos, userHandler, logger)
+	customFieldsHandler.InitCustomFieldHandler(server)
+
+	// init storage habits
+	storageHabits := habits.NewStorage(client, logger)
+	// init habits controller
+	habitsHandler := habits.NewHandler(storageHabits, userHandler, logger)
+	habitsHandler.InitHabitHandler(server)
+
+	// init storage goals
+	storageGoals := goals.NewStorage(client, logger)
+	// init goals controller
+	goalsHandler := goals.NewHandler(storageGoals, storageLists, storageTodos, userHandler, logger)
+	goalsHandler.InitGoalHandler(server)
+
+	// init storage schedule
+	storageSchedule := schedule.NewStorage(client, logger)
+	// init schedule controller
+	scheduleHandler := schedule.NewHandler(storageSchedule, storageTodos, userHandler, logger)
+	scheduleHandler.InitScheduleHandler(server)
+
+	// init storage automation rules
+	storageAutomation := automation.NewStorage(client, logger)
+	// init automation rules controller
+	automationHandler := automation.NewHandler(storageAutomation, storageLists, userHandler, logger)
+	automationHandler.InitRuleHandler(server)
+	// run the rules on the changes to todos and on overdue todos
+	engine := automation.NewEngine(storageAutomation, storageTodos, storageLists, logger)
+	storageTodos.Observe(engine)
+	go engine.Run(context.Background())
+
 	log.Fatalln(server.Run(viper.GetString(util.ConfigPath(util.Server, "port"))))
 }
 
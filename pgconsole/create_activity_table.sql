@@ -0,0 +1,31 @@
+create table activity (
+    id bigserial primary key,
+    workspace_id uuid,
+    list_id uuid,
+    todo_id uuid,
+    actor_id uuid NOT NULL,
+    kind varchar(50) NOT NULL,
+    payload jsonb NOT NULL default '{}',
+    created_at timestamptz NOT NULL default now(),
+    -- Instance that recorded the activity, which publishes it to its own
+    -- subscribers itself.
+    instance varchar(16),
+    -- Live session that caused the activity, not echoed back to it.
+    origin varchar(64),
+    -- Transaction that recorded the activity. Readers follow activities by
+    -- (xact_id, id) up to the oldest transaction in progress, so that one
+    -- committing after a younger one is not skipped.
+    xact_id bigint NOT NULL default pg_current_xact_id()::text::bigint,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    -- The history of a deleted list stays, see activity_visible().
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete set null,
+    constraint actor_fk foreign key (actor_id) references public.users(id) on delete cascade
+);
+
+create index activity_feed_idx on activity (workspace_id, id desc);
+
+create index activity_list_idx on activity (list_id);
+
+create index activity_todo_idx on activity (todo_id, created_at);
+
+create index activity_position_idx on activity (xact_id, id)
\ No newline at end of file
@@ -0,0 +1,48 @@
+-- Rules run with the permissions of their owner, on the todos of the
+-- workspace they can edit, or only on those of the list when one is set.
+create table automation_rules (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    list_id uuid,
+    name varchar(100) NOT NULL,
+    trigger varchar(20) NOT NULL check (trigger in ('created', 'completed', 'overdue', 'tagged')),
+    trigger_tag varchar(50),
+    conditions jsonb NOT NULL default '[]',
+    actions jsonb NOT NULL default '[]',
+    active boolean NOT NULL default true,
+    created_at timestamptz NOT NULL default now(),
+    updated_at timestamptz NOT NULL default now(),
+    constraint trigger_tag_check check ((trigger = 'tagged') = (trigger_tag IS NOT NULL)),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade
+);
+
+create index automation_rules_trigger_idx on automation_rules (workspace_id, trigger) where active;
+
+-- The execution log of the rules. Todos are not referenced, so the log of a
+-- todo outlives it.
+create table automation_executions (
+    id bigserial primary key,
+    rule_id uuid NOT NULL,
+    todo_id uuid,
+    activity_id bigint,
+    status varchar(10) NOT NULL check (status in ('succeeded', 'failed', 'skipped')),
+    message text,
+    created_at timestamptz NOT NULL default now(),
+    constraint rule_fk foreign key (rule_id) references public.automation_rules(id) on delete cascade
+);
+
+create index automation_executions_rule_idx on automation_executions (rule_id, id desc);
+
+-- An overdue rule fires once per todo and due date, on the instance that
+-- claims it first.
+create table automation_overdue (
+    rule_id uuid NOT NULL,
+    todo_id uuid NOT NULL,
+    due_at timestamptz NOT NULL,
+    primary key (rule_id, todo_id, due_at),
+    constraint rule_fk foreign key (rule_id) references public.automation_rules(id) on delete cascade,
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade
+)
\ No newline at end of file
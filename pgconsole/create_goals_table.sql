@@ -0,0 +1,33 @@
+create table goals (
+    id uuid primary key default gen_random_uuid(),
+    owner_id uuid NOT NULL,
+    workspace_id uuid,
+    -- Sub-goals roll up into their parent with their weight.
+    parent_id uuid,
+    title varchar(100) NOT NULL,
+    description varchar(2000) NOT NULL default '',
+    target_date date,
+    weight double precision NOT NULL default 1 check (weight > 0),
+    created_at timestamptz NOT NULL default now(),
+    updated_at timestamptz NOT NULL default now(),
+    constraint owner_fk foreign key (owner_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint parent_fk foreign key (parent_id) references public.goals(id) on delete set null
+);
+
+-- A link ties a goal to either a todo, done or not, or a list, done in the
+-- share of its todos that are completed.
+create table goal_links (
+    id uuid primary key default gen_random_uuid(),
+    goal_id uuid NOT NULL,
+    todo_id uuid,
+    list_id uuid,
+    weight double precision NOT NULL default 1 check (weight > 0),
+    created_at timestamptz NOT NULL default now(),
+    constraint goal_fk foreign key (goal_id) references public.goals(id) on delete cascade,
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade,
+    constraint goal_links_target check ((todo_id IS NULL) <> (list_id IS NULL)),
+    constraint goal_links_todo unique (goal_id, todo_id),
+    constraint goal_links_list unique (goal_id, list_id)
+)
\ No newline at end of file
@@ -0,0 +1,21 @@
+create table habits (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    name varchar(100) NOT NULL,
+    schedule varchar(10) NOT NULL check (schedule in ('daily', 'weekly')),
+    -- How many days of a week a weekly habit is due, 7 for daily habits.
+    times_per_week integer NOT NULL check (times_per_week between 1 and 7),
+    created_at timestamptz NOT NULL default now(),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
+);
+
+-- A check-in marks a day of the user's calendar as done, at most once.
+create table habit_checkins (
+    habit_id uuid NOT NULL,
+    day date NOT NULL,
+    created_at timestamptz NOT NULL default now(),
+    primary key (habit_id, day),
+    constraint habit_fk foreign key (habit_id) references public.habits(id) on delete cascade
+)
\ No newline at end of file
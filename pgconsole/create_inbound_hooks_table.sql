@@ -0,0 +1,17 @@
+create table inbound_hooks (
+    id uuid primary key default gen_random_uuid(),
+    token varchar(64) NOT NULL unique,
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    list_id uuid,
+    name varchar(100) NOT NULL,
+    template jsonb NOT NULL default '{}',
+    window_started_at timestamptz NOT NULL default now(),
+    window_count integer NOT NULL default 0,
+    last_used_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz NOT NULL default now(),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,25 @@
+create table list_fields (
+    id uuid primary key default gen_random_uuid(),
+    list_id uuid NOT NULL,
+    name varchar(50) NOT NULL,
+    type varchar(20) NOT NULL check (type in ('text', 'number', 'date', 'single_select', 'multi_select', 'checkbox')),
+    options varchar(50)[] NOT NULL default '{}',
+    position integer NOT NULL,
+    created_at timestamptz NOT NULL default now(),
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade,
+    constraint list_fields_name unique (list_id, name)
+);
+
+-- Values are JSON typed after their field: a string for text, date (as
+-- 2006-01-02) and single-select, a number, an array of strings for
+-- multi-select and a boolean for checkboxes.
+create table todo_field_values (
+    todo_id uuid NOT NULL,
+    field_id uuid NOT NULL,
+    value jsonb NOT NULL,
+    primary key (todo_id, field_id),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint field_fk foreign key (field_id) references public.list_fields(id) on delete cascade
+);
+
+create index todo_field_values_field_idx on todo_field_values (field_id, value)
\ No newline at end of file
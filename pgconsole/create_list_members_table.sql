@@ -0,0 +1,9 @@
+create table list_members (
+    list_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role varchar(10) NOT NULL check (role in ('owner', 'admin', 'editor', 'viewer')),
+    accepted boolean NOT NULL default false,
+    primary key (list_id, user_id),
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+)
\ No newline at end of file
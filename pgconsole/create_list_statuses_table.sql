@@ -0,0 +1,20 @@
+create table list_statuses (
+    id uuid primary key default gen_random_uuid(),
+    list_id uuid NOT NULL,
+    name varchar(50) NOT NULL,
+    position integer NOT NULL,
+    terminal boolean NOT NULL default false,
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade,
+    constraint list_statuses_name unique (list_id, name) deferrable initially deferred
+);
+
+-- A list has at most one terminal status.
+create unique index list_statuses_terminal_idx on list_statuses (list_id) where terminal;
+
+create table list_status_transitions (
+    from_status_id uuid NOT NULL,
+    to_status_id uuid NOT NULL,
+    primary key (from_status_id, to_status_id),
+    constraint from_status_fk foreign key (from_status_id) references public.list_statuses(id) on delete cascade,
+    constraint to_status_fk foreign key (to_status_id) references public.list_statuses(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,12 @@
+create table list_templates (
+    id uuid primary key default gen_random_uuid(),
+    owner_id uuid NOT NULL,
+    workspace_id uuid,
+    title varchar(100) not null,
+    description varchar(1000) NOT NULL default '',
+    items jsonb NOT NULL default '[]',
+    created_at timestamptz NOT NULL default now(),
+    updated_at timestamptz NOT NULL default now(),
+    constraint owner_fk foreign key (owner_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
+)
\ No newline at end of file
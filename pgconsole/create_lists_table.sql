@@ -0,0 +1,10 @@
+create table lists (
+    id uuid primary key default gen_random_uuid(),
+    title varchar(100) not null,
+    owner_id uuid NOT NULL,
+    workspace_id uuid,
+    visibility varchar(10) NOT NULL default 'private' check (visibility in ('private', 'workspace')),
+    enforce_transitions boolean NOT NULL default false,
+    constraint owner_fk foreign key (owner_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,11 @@
+create table notifications (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    kind varchar(50) NOT NULL,
+    payload jsonb NOT NULL default '{}',
+    read boolean NOT NULL default false,
+    created_at timestamptz NOT NULL default now(),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,49 @@
+-- list_role resolves the role a user holds on a list: an accepted list membership
+-- wins, otherwise lists shared with the whole workspace are readable by every
+-- workspace member except guests. Returns null when the user has no access.
+create or replace function list_role(p_list_id uuid, p_user_id uuid) returns varchar as $$
+    select coalesce(
+        (select m.role from list_members m
+            where m.list_id = p_list_id and m.user_id = p_user_id and m.accepted),
+        (select 'viewer' from lists l
+            join workspace_members w on w.workspace_id = l.workspace_id
+            where l.id = p_list_id and l.visibility = 'workspace'
+              and w.user_id = p_user_id and w.role <> 'guest')
+    )
+$$ language sql stable;
+
+-- todo_role resolves the role a user holds on a todo: todos without a list
+-- belong to their author only, the others inherit the role on their list.
+create or replace function todo_role(p_list_id uuid, p_author_id uuid, p_user_id uuid) returns varchar as $$
+    select case
+        when p_list_id is not null then list_role(p_list_id, p_user_id)
+        when p_author_id = p_user_id then 'owner'
+    end
+$$ language sql stable;
+
+-- activity_visible tells whether a user sees an activity: those on a list follow
+-- list_role(), the others stay with their actor. Once a list is deleted its
+-- history is left to the actors, except for the list's own activity that the
+-- workspace keeps seeing when the list was shared with it.
+create or replace function activity_visible(p_list_id uuid, p_todo_id uuid, p_workspace_id uuid,
+                                            p_actor_id uuid, p_payload jsonb, p_user_id uuid) returns boolean as $$
+    select case
+        when p_list_id is not null then list_role(p_list_id, p_user_id) is not null
+        when p_actor_id = p_user_id then true
+        else p_todo_id is null and p_payload->>'visibility' = 'workspace'
+            and exists (select 1 from workspace_members w
+                where w.workspace_id = p_workspace_id and w.user_id = p_user_id and w.role <> 'guest')
+    end
+$$ language sql stable;
+
+-- list_audience returns every user list_role() grants access to the list,
+-- keep both functions in line.
+create or replace function list_audience(p_list_id uuid) returns uuid[] as $$
+    select array(
+        select m.user_id from list_members m where m.list_id = p_list_id and m.accepted
+        union
+        select w.user_id from lists l
+            join workspace_members w on w.workspace_id = l.workspace_id
+            where l.id = p_list_id and l.visibility = 'workspace' and w.role <> 'guest'
+    )
+$$ language sql stable
\ No newline at end of file
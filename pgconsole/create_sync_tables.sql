@@ -0,0 +1,21 @@
+create table sync_sequences (
+    user_id uuid primary key,
+    seq bigint NOT NULL default 0,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+);
+
+-- One row per user and entity holding the sequence of its latest change for that
+-- user. Rows of deleted entities, or entities the user lost access to, stay as
+-- tombstones and therefore have no foreign key on the entity or its workspace.
+create table sync_changes (
+    user_id uuid NOT NULL,
+    entity varchar(10) NOT NULL check (entity in ('todo', 'list')),
+    entity_id uuid NOT NULL,
+    workspace_id uuid,
+    seq bigint NOT NULL,
+    deleted boolean NOT NULL default false,
+    primary key (user_id, entity, entity_id),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+);
+
+create index sync_changes_seq_idx on sync_changes (user_id, seq)
\ No newline at end of file
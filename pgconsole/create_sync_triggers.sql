@@ -0,0 +1,134 @@
+-- Changes are recorded by triggers so that cascades, e.g. the todos of a deleted
+-- list, produce tombstones as well. Requires create_role_functions.sql.
+
+-- sync_touch bumps the sequence of every user and records the change of the
+-- entity under the new value. The per-user sequence row stays locked until the
+-- transaction ends, so a user's changes commit in sequence order and a sync
+-- token never skips a change that was still in flight.
+create or replace function sync_touch(p_entity varchar, p_id uuid, p_workspace_id uuid, p_deleted boolean, p_users uuid[])
+returns void as $$
+    with seqs as (
+        insert into sync_sequences as s (user_id, seq)
+        select distinct u, 1 from unnest(p_users) u where u is not null order by 1
+        on conflict (user_id) do update set seq = s.seq + 1
+        returning s.user_id, s.seq
+    )
+    insert into sync_changes as c (user_id, entity, entity_id, workspace_id, seq, deleted)
+    select user_id, p_entity, p_id, p_workspace_id, seq, p_deleted from seqs
+    on conflict (user_id, entity, entity_id) do update
+        set workspace_id = excluded.workspace_id, seq = excluded.seq, deleted = excluded.deleted
+$$ language sql;
+
+create or replace function todo_audience(p_list_id uuid, p_author_id uuid) returns uuid[] as $$
+    select case when p_list_id is null then array[p_author_id] else list_audience(p_list_id) end
+$$ language sql stable;
+
+-- sync_refresh_list sends a list and its todos to a user who gained access to
+-- it, or tombstones them for a user who lost it.
+create or replace function sync_refresh_list(p_list_id uuid, p_user_id uuid) returns void as $$
+declare
+    v_workspace_id uuid;
+    v_deleted boolean;
+    v_todo_id uuid;
+begin
+    select workspace_id into v_workspace_id from lists where id = p_list_id;
+    if not found then
+        -- the list is being deleted, its trigger already wrote the tombstones
+        return;
+    end if;
+    v_deleted := list_role(p_list_id, p_user_id) is null;
+    for v_todo_id in select id from todo where list_id = p_list_id loop
+        perform sync_touch('todo', v_todo_id, v_workspace_id, v_deleted, array[p_user_id]);
+    end loop;
+    perform sync_touch('list', p_list_id, v_workspace_id, v_deleted, array[p_user_id]);
+end
+$$ language plpgsql;
+
+create or replace function sync_todo_changed() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        perform sync_touch('todo', old.id, old.workspace_id, true, todo_audience(old.list_id, old.user_id));
+        return old;
+    end if;
+    if tg_op = 'UPDATE' and old.list_id is distinct from new.list_id then
+        perform sync_touch('todo', old.id, old.workspace_id, true, array(
+            select unnest(todo_audience(old.list_id, old.user_id))
+            except select unnest(todo_audience(new.list_id, new.user_id))));
+    end if;
+    perform sync_touch('todo', new.id, new.workspace_id, false, todo_audience(new.list_id, new.user_id));
+    return new;
+end
+$$ language plpgsql;
+
+create trigger todo_sync after insert or update on todo
+    for each row execute function sync_todo_changed();
+create trigger todo_sync_delete before delete on todo
+    for each row execute function sync_todo_changed();
+
+create or replace function sync_list_changed() returns trigger as $$
+declare
+    v_audience uuid[];
+    v_id uuid;
+begin
+    if tg_op = 'DELETE' then
+        v_audience := list_audience(old.id);
+        for v_id in select id from todo where list_id = old.id loop
+            perform sync_touch('todo', v_id, old.workspace_id, true, v_audience);
+        end loop;
+        perform sync_touch('list', old.id, old.workspace_id, true, v_audience);
+        return old;
+    end if;
+    if tg_op = 'UPDATE' and old.visibility <> new.visibility then
+        for v_id in select user_id from workspace_members where workspace_id = new.workspace_id loop
+            perform sync_refresh_list(new.id, v_id);
+        end loop;
+    end if;
+    perform sync_touch('list', new.id, new.workspace_id, false, list_audience(new.id));
+    return new;
+end
+$$ language plpgsql;
+
+create trigger lists_sync after insert or update on lists
+    for each row execute function sync_list_changed();
+create trigger lists_sync_delete before delete on lists
+    for each row execute function sync_list_changed();
+
+-- Pending invites are invisible, only accepted memberships change what a user syncs.
+create or replace function sync_list_member_changed() returns trigger as $$
+begin
+    if tg_op = 'DELETE' then
+        if old.accepted then
+            perform sync_refresh_list(old.list_id, old.user_id);
+        end if;
+    elsif new.accepted then
+        perform sync_refresh_list(new.list_id, new.user_id);
+    end if;
+    return null;
+end
+$$ language plpgsql;
+
+create trigger list_members_sync after insert or update or delete on list_members
+    for each row execute function sync_list_member_changed();
+
+-- Joining, leaving or becoming a guest of a workspace changes access to the
+-- lists shared with the whole workspace.
+create or replace function sync_workspace_member_changed() returns trigger as $$
+declare
+    v_member workspace_members%rowtype;
+    v_list_id uuid;
+begin
+    if tg_op = 'DELETE' then
+        v_member := old;
+    else
+        v_member := new;
+    end if;
+    for v_list_id in select id from lists
+        where workspace_id = v_member.workspace_id and visibility = 'workspace' loop
+        perform sync_refresh_list(v_list_id, v_member.user_id);
+    end loop;
+    return null;
+end
+$$ language plpgsql;
+
+create trigger workspace_members_sync after insert or update or delete on workspace_members
+    for each row execute function sync_workspace_member_changed()
\ No newline at end of file
@@ -0,0 +1,18 @@
+create table time_blocks (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    -- A block without a todo is busy time, named by its title.
+    todo_id uuid,
+    title varchar(100),
+    starts_at timestamptz NOT NULL,
+    ends_at timestamptz NOT NULL,
+    created_at timestamptz NOT NULL default now(),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint time_blocks_range check (ends_at > starts_at),
+    constraint time_blocks_subject check (todo_id IS NOT NULL OR title IS NOT NULL)
+);
+
+create index time_blocks_user_idx on time_blocks (user_id, starts_at)
\ No newline at end of file
@@ -0,0 +1,18 @@
+create table time_entries (
+    id uuid primary key default gen_random_uuid(),
+    todo_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    started_at timestamptz NOT NULL,
+    ended_at timestamptz,
+    note varchar(500) NOT NULL default '',
+    created_at timestamptz NOT NULL default now(),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint time_entries_range check (ended_at IS NULL OR ended_at >= started_at)
+);
+
+-- A user has at most one running timer.
+create unique index time_entries_running_idx on time_entries (user_id) where ended_at is null;
+create index time_entries_user_started_idx on time_entries (user_id, started_at)
\ No newline at end of file
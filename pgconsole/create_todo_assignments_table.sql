@@ -0,0 +1,10 @@
+create table todo_assignments (
+    id uuid primary key default gen_random_uuid(),
+    todo_id uuid NOT NULL,
+    assignee_id uuid,
+    assigned_by uuid NOT NULL,
+    created_at timestamptz NOT NULL default now(),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint assignee_fk foreign key (assignee_id) references public.users(id) on delete set null,
+    constraint assigned_by_fk foreign key (assigned_by) references public.users(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,13 @@
+create table todo_dependencies (
+    todo_id uuid NOT NULL,
+    blocked_by_id uuid NOT NULL,
+    created_by uuid,
+    created_at timestamptz NOT NULL default now(),
+    primary key (todo_id, blocked_by_id),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint blocked_by_fk foreign key (blocked_by_id) references public.todo(id) on delete cascade,
+    constraint created_by_fk foreign key (created_by) references public.users(id) on delete set null,
+    constraint todo_dependencies_self check (todo_id <> blocked_by_id)
+);
+
+create index todo_dependencies_blocked_by_idx on todo_dependencies (blocked_by_id)
\ No newline at end of file
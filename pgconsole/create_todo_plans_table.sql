@@ -0,0 +1,16 @@
+-- Plans are personal: a todo snoozed or picked for the day by one member of a
+-- list is unaffected for the others.
+create table todo_plans (
+    todo_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    snoozed_until timestamptz,
+    -- The day of the user's calendar the todo was picked for, the pick lapses
+    -- at the user's next midnight.
+    my_day date,
+    picked_at timestamptz,
+    primary key (todo_id, user_id),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+);
+
+create index todo_plans_my_day_idx on todo_plans (user_id, my_day)
\ No newline at end of file
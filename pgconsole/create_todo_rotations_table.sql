@@ -0,0 +1,12 @@
+-- The roster of a rotating todo, in turn order. Completing the todo passes
+-- it to the next member, a member with skips left is passed over once per
+-- skip. The current turn is the todo's assignee.
+create table todo_rotation_members (
+    todo_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    position integer NOT NULL,
+    skips integer NOT NULL default 0 check (skips >= 0),
+    primary key (todo_id, user_id),
+    constraint todo_fk foreign key (todo_id) references public.todo(id) on delete cascade,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+)
\ No newline at end of file
@@ -2,5 +2,29 @@ create table todo (
     id uuid primary key default gen_random_uuid(),
     title varchar(100) not null,
     user_id uuid NOT NULL,
-    constraint user_fk foreign key (user_id) references public.users(id)
+    list_id uuid,
+    assignee_id uuid,
+    workspace_id uuid,
+    due_at timestamptz,
+    tags varchar(50)[] NOT NULL default '{}',
+    priority varchar(10) check (priority in ('low', 'medium', 'high')),
+    recurrence varchar(100),
+    created_at timestamptz NOT NULL default now(),
+    completed_at timestamptz,
+    parent_id uuid,
+    estimated_minutes integer check (estimated_minutes > 0),
+    status_id uuid,
+    -- GTD: the @contexts the todo can be done in, whether it is a next action,
+    -- and who it waits on with when to follow up.
+    contexts varchar(50)[] NOT NULL default '{}',
+    next_action boolean NOT NULL default false,
+    waiting_for varchar(100),
+    follow_up_at timestamptz,
+    constraint follow_up_check check (follow_up_at IS NULL OR waiting_for IS NOT NULL),
+    constraint user_fk foreign key (user_id) references public.users(id),
+    constraint list_fk foreign key (list_id) references public.lists(id) on delete cascade,
+    constraint status_fk foreign key (status_id) references public.list_statuses(id) on delete set null,
+    constraint parent_fk foreign key (parent_id) references public.todo(id) on delete cascade,
+    constraint assignee_fk foreign key (assignee_id) references public.users(id) on delete set null,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
 )
\ No newline at end of file
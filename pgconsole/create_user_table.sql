@@ -1,5 +1,6 @@
 create table users (
     id uuid primary key default gen_random_uuid(),
     name varchar(100) NOT NULL,
-    password_hash varchar(100) NOT NULL
+    password_hash varchar(100) NOT NULL,
+    timezone varchar(64) NOT NULL default 'UTC'
 )
\ No newline at end of file
@@ -0,0 +1,39 @@
+create table webhook_deliveries (
+    id uuid primary key default gen_random_uuid(),
+    webhook_id uuid NOT NULL,
+    activity_id bigint,
+    event varchar(50) NOT NULL,
+    payload jsonb NOT NULL,
+    status varchar(10) NOT NULL default 'pending' check (status in ('pending', 'succeeded', 'failed')),
+    attempts integer NOT NULL default 0,
+    next_attempt_at timestamptz NOT NULL default now(),
+    response_status integer,
+    last_error text,
+    created_at timestamptz NOT NULL default now(),
+    delivered_at timestamptz,
+    constraint webhook_fk foreign key (webhook_id) references public.webhooks(id) on delete cascade
+);
+
+create index webhook_deliveries_due_idx on webhook_deliveries (next_attempt_at) where status = 'pending';
+create index webhook_deliveries_webhook_idx on webhook_deliveries (webhook_id, created_at desc);
+
+-- Deliveries are queued in the transaction that records the activity, for every
+-- active webhook of the workspace whose owner can see it. Requires
+-- create_role_functions.sql.
+create or replace function webhooks_enqueue() returns trigger as $$
+declare
+    v_event varchar := case when new.todo_id is not null then 'todo.' else 'list.' end || new.kind;
+begin
+    insert into webhook_deliveries (webhook_id, activity_id, event, payload)
+    select w.id, new.id, v_event, jsonb_build_object('event', v_event, 'activity', to_jsonb(new) - 'instance' - 'origin' - 'xact_id')
+    from webhooks w
+    where w.active and w.workspace_id is not distinct from new.workspace_id
+      and (cardinality(w.events) = 0 or v_event = any(w.events))
+      and case when new.list_id is null then new.actor_id = w.user_id
+               else list_role(new.list_id, w.user_id) is not null end;
+    return null;
+end
+$$ language plpgsql;
+
+create trigger activity_webhooks after insert on activity
+    for each row execute function webhooks_enqueue()
\ No newline at end of file
@@ -0,0 +1,14 @@
+create table webhooks (
+    id uuid primary key default gen_random_uuid(),
+    user_id uuid NOT NULL,
+    workspace_id uuid,
+    url varchar(2048) NOT NULL,
+    secret varchar(64) NOT NULL,
+    events varchar(50)[] NOT NULL default '{}',
+    active boolean NOT NULL default true,
+    failure_count integer NOT NULL default 0,
+    disabled_at timestamptz,
+    created_at timestamptz NOT NULL default now(),
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade
+)
\ No newline at end of file
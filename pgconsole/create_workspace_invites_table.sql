@@ -0,0 +1,9 @@
+create table workspace_invites (
+    token varchar(64) primary key,
+    workspace_id uuid NOT NULL,
+    role varchar(10) NOT NULL check (role in ('admin', 'member', 'guest')),
+    created_by uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint created_by_fk foreign key (created_by) references public.users(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,8 @@
+create table workspace_members (
+    workspace_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role varchar(10) NOT NULL check (role in ('owner', 'admin', 'member', 'guest')),
+    primary key (workspace_id, user_id),
+    constraint workspace_fk foreign key (workspace_id) references public.workspaces(id) on delete cascade,
+    constraint user_fk foreign key (user_id) references public.users(id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,5 @@
+create table workspaces (
+    id uuid primary key default gen_random_uuid(),
+    name varchar(100) NOT NULL,
+    default_visibility varchar(10) NOT NULL default 'private' check (default_visibility in ('private', 'workspace'))
+)
\ No newline at end of file
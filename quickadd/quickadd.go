@@ -0,0 +1,615 @@
+// Package quickadd parses the one-line todos typed in a quick-add box, such as
+// "Pay rent tomorrow 9am #home !high every month", into a title, a due date,
+// tags, a priority and a recurrence. It only understands English.
+package quickadd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Kinds of the recognized spans.
+const (
+	KindDate       = "date"
+	KindTime       = "time"
+	KindTag        = "tag"
+	KindPriority   = "priority"
+	KindRecurrence = "recurrence"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// Span is a recognized part of the input. Start and End are offsets in
+// characters, not bytes, End being exclusive.
+type Span struct {
+	Kind  string `json:"kind"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+type Recurrence struct {
+	Frequency string         `json:"frequency"`
+	Interval  int            `json:"interval"`
+	Weekdays  []time.Weekday `json:"weekdays,omitempty"`
+}
+
+// RRule returns the recurrence as an iCalendar RRULE.
+func (r Recurrence) RRule() string {
+	rule := fmt.Sprintf("FREQ=%s;INTERVAL=%d", strings.ToUpper(r.Frequency), r.Interval)
+	if len(r.Weekdays) > 0 {
+		days := make([]string, 0, len(r.Weekdays))
+		for _, day := range r.Weekdays {
+			days = append(days, rruleDays[day])
+		}
+		rule += ";BYDAY=" + strings.Join(days, ",")
+	}
+	return rule
+}
+
+type Result struct {
+	Title string     `json:"title"`
+	Due   *time.Time `json:"due"`
+	// AllDay is set when a date was given without a time, Due is then
+	// midnight of that day.
+	AllDay     bool        `json:"all_day"`
+	Tags       []string    `json:"tags"`
+	Priority   string      `json:"priority,omitempty"`
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	Spans      []Span      `json:"spans"`
+}
+
+var rruleDays = map[time.Weekday]string{
+	time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE", time.Thursday: "TH",
+	time.Friday: "FR", time.Saturday: "SA", time.Sunday: "SU",
+}
+
+var weekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday, "thursday": time.Thursday,
+	"friday": time.Friday, "saturday": time.Saturday, "sunday": time.Sunday,
+}
+
+// shortWeekdays are also common words, they are only read as days after a
+// word that announces one, as in "on sat" or "every mon".
+var shortWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "fri": time.Friday,
+	"sat": time.Saturday, "sun": time.Sunday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January, "february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March, "april": time.April, "apr": time.April, "may": time.May,
+	"june": time.June, "jun": time.June, "july": time.July, "jul": time.July, "august": time.August,
+	"aug": time.August, "september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October, "november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var numbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+var priorities = map[string]string{
+	"!high": PriorityHigh, "!1": PriorityHigh, "!!!": PriorityHigh,
+	"!medium": PriorityMedium, "!med": PriorityMedium, "!2": PriorityMedium, "!!": PriorityMedium,
+	"!low": PriorityLow, "!3": PriorityLow,
+}
+
+// prepositions may precede a date or a time and then belong to its span.
+var prepositions = map[string]bool{"on": true, "at": true, "by": true, "due": true}
+
+var (
+	tagPattern      = regexp.MustCompile(`^#([\p{L}\p{N}_\-/]+)$`)
+	clockPattern    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	isoDatePattern  = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	dayOfMonth      = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+	yearPattern     = regexp.MustCompile(`^\d{4}$`)
+	meridiemPattern = regexp.MustCompile(`^(am|pm)$`)
+)
+
+type token struct {
+	text  string
+	word  string
+	start int
+	end   int
+}
+
+type parser struct {
+	tokens []token
+	used   []bool
+	now    time.Time
+	result Result
+
+	date    *time.Time
+	exact   *time.Time
+	hour    int
+	minute  int
+	hasTime bool
+}
+
+// Parse parses the input relative to now, whose location is the time zone
+// the dates are read in. Words that are not recognized make up the title.
+func Parse(input string, now time.Time) Result {
+	p := &parser{tokens: tokenize(input), now: now}
+	p.used = make([]bool, len(p.tokens))
+	p.result.Tags = make([]string, 0)
+	p.result.Spans = make([]Span, 0)
+
+	for i := 0; i < len(p.tokens); i++ {
+		if n, kind := p.match(i); n > 0 {
+			p.consume(i, n, kind)
+			i += n - 1
+			continue
+		}
+		if prepositions[p.tokens[i].word] && i+1 < len(p.tokens) {
+			if n, kind := p.matchWhen(i+1, true); n > 0 {
+				p.consume(i, n+1, kind)
+				i += n
+			}
+		}
+	}
+
+	p.resolveDue()
+
+	words := make([]string, 0, len(p.tokens))
+	for i, t := range p.tokens {
+		if !p.used[i] {
+			words = append(words, t.text)
+		}
+	}
+	p.result.Title = strings.Join(words, " ")
+	return p.result
+}
+
+func (p *parser) consume(i, n int, kind string) {
+	first, last := p.tokens[i], p.tokens[i+n-1]
+	parts := make([]string, 0, n)
+	for j := i; j < i+n; j++ {
+		p.used[j] = true
+		parts = append(parts, p.tokens[j].text)
+	}
+	p.result.Spans = append(p.result.Spans, Span{Kind: kind, Start: first.start, End: last.end, Text: strings.Join(parts, " ")})
+}
+
+// match tries every pattern at token i and returns the number of tokens it
+// consumed with the kind of the span.
+func (p *parser) match(i int) (int, string) {
+	word := p.tokens[i].word
+	if m := tagPattern.FindStringSubmatch(p.tokens[i].text); m != nil {
+		p.result.Tags = appendUnique(p.result.Tags, m[1])
+		return 1, KindTag
+	}
+	if priority, ok := priorities[word]; ok && p.result.Priority == "" {
+		p.result.Priority = priority
+		return 1, KindPriority
+	}
+	if p.result.Recurrence == nil {
+		if n := p.matchRecurrence(i); n > 0 {
+			return n, KindRecurrence
+		}
+	}
+	return p.matchWhen(i, false)
+}
+
+// matchWhen tries the date and time patterns. announced is set after a
+// preposition, which allows the ambiguous forms.
+func (p *parser) matchWhen(i int, announced bool) (int, string) {
+	if p.date == nil && p.exact == nil {
+		if n := p.matchDate(i, announced); n > 0 {
+			return n, KindDate
+		}
+	}
+	if !p.hasTime && p.exact == nil {
+		if n := p.matchTime(i, announced); n > 0 {
+			return n, KindTime
+		}
+	}
+	return 0, ""
+}
+
+func (p *parser) matchDate(i int, announced bool) int {
+	word := p.tokens[i].word
+	next := p.word(i + 1)
+	today := startOfDay(p.now)
+
+	switch word {
+	case "today":
+		p.setDate(today)
+		return 1
+	case "tonight":
+		p.setDate(today)
+		if !p.hasTime {
+			p.hour, p.minute, p.hasTime = 20, 0, true
+		}
+		return 1
+	case "tomorrow", "tmr", "tmrw":
+		p.setDate(today.AddDate(0, 0, 1))
+		return 1
+	case "day":
+		if next == "after" && p.word(i+2) == "tomorrow" {
+			p.setDate(today.AddDate(0, 0, 2))
+			return 3
+		}
+	case "in":
+		return p.matchIn(i)
+	case "next", "this":
+		switch next {
+		case "week":
+			if word == "next" {
+				p.setDate(nextWeekday(today, time.Monday, false))
+				return 2
+			}
+		case "month":
+			if word == "next" {
+				p.setDate(time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()))
+				return 2
+			}
+		case "year":
+			if word == "next" {
+				p.setDate(time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, today.Location()))
+				return 2
+			}
+		}
+		if day, ok := weekday(next, true); ok {
+			p.setDate(nextWeekday(today, day, word == "this"))
+			return 2
+		}
+	}
+
+	if day, ok := weekday(word, announced); ok {
+		p.setDate(nextWeekday(today, day, true))
+		return 1
+	}
+	if m := isoDatePattern.FindStringSubmatch(word); m != nil {
+		if date, ok := makeDate(atoi(m[1]), atoi(m[2]), atoi(m[3]), today.Location()); ok {
+			p.setDate(date)
+			return 1
+		}
+	}
+	return p.matchMonthDay(i)
+}
+
+// matchMonthDay reads "may 5", "may 5th, 2025", "5 may" and "5th of may 2025".
+func (p *parser) matchMonthDay(i int) int {
+	word := p.tokens[i].word
+	var month time.Month
+	var day, n int
+
+	if m, ok := months[word]; ok {
+		d := dayOfMonth.FindStringSubmatch(p.word(i + 1))
+		if d == nil {
+			return 0
+		}
+		month, day, n = m, atoi(d[1]), 2
+	} else if d := dayOfMonth.FindStringSubmatch(word); d != nil {
+		j := i + 1
+		if p.word(j) == "of" {
+			j++
+		}
+		m, ok := months[p.word(j)]
+		if !ok {
+			return 0
+		}
+		month, day, n = m, atoi(d[1]), j-i+1
+	} else {
+		return 0
+	}
+
+	today := startOfDay(p.now)
+	year, explicitYear := today.Year(), false
+	if yearPattern.MatchString(p.word(i + n)) {
+		year, explicitYear = atoi(p.word(i+n)), true
+		n++
+	}
+	date, ok := makeDate(year, int(month), day, today.Location())
+	if !ok {
+		return 0
+	}
+	if !explicitYear && date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	p.setDate(date)
+	return n
+}
+
+// matchIn reads "in 3 days", "in an hour" or "in two weeks".
+func (p *parser) matchIn(i int) int {
+	count, ok := number(p.word(i + 1))
+	if !ok {
+		return 0
+	}
+	switch unit := strings.TrimSuffix(p.word(i+2), "s"); unit {
+	case "minute", "min":
+		exact := p.now.Add(time.Duration(count) * time.Minute)
+		p.exact = &exact
+	case "hour", "hr":
+		exact := p.now.Add(time.Duration(count) * time.Hour)
+		p.exact = &exact
+	case "day":
+		p.setDate(startOfDay(p.now).AddDate(0, 0, count))
+	case "week":
+		p.setDate(startOfDay(p.now).AddDate(0, 0, 7*count))
+	case "month":
+		p.setDate(startOfDay(p.now).AddDate(0, count, 0))
+	case "year":
+		p.setDate(startOfDay(p.now).AddDate(count, 0, 0))
+	default:
+		return 0
+	}
+	return 3
+}
+
+// matchTime reads "9am", "9:30 pm", "14:00", "noon" and "midnight". A bare
+// hour such as "9" is only a time after a preposition.
+func (p *parser) matchTime(i int, announced bool) int {
+	word := p.tokens[i].word
+	switch word {
+	case "noon", "midday":
+		p.hour, p.minute, p.hasTime = 12, 0, true
+		return 1
+	case "midnight":
+		p.hour, p.minute, p.hasTime = 0, 0, true
+		return 1
+	}
+
+	m := clockPattern.FindStringSubmatch(word)
+	if m == nil {
+		return 0
+	}
+	hour, minute, meridiem, n := atoi(m[1]), 0, m[3], 1
+	if m[2] != "" {
+		minute = atoi(m[2])
+	}
+	if meridiem == "" && meridiemPattern.MatchString(p.word(i+1)) {
+		meridiem, n = p.word(i+1), 2
+	}
+	if meridiem == "" && m[2] == "" {
+		if !announced {
+			return 0
+		}
+		// "at 5" is rarely five in the morning
+		if hour >= 1 && hour <= 7 {
+			hour += 12
+		}
+	}
+	switch meridiem {
+	case "am":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		if hour == 12 {
+			hour = 0
+		}
+	case "pm":
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		if hour != 12 {
+			hour += 12
+		}
+	}
+	if hour > 23 || minute > 59 {
+		return 0
+	}
+	p.hour, p.minute, p.hasTime = hour, minute, true
+	return n
+}
+
+// matchRecurrence reads "daily", "every day", "every other week",
+// "every 3 months", "every monday" and "every weekday".
+func (p *parser) matchRecurrence(i int) int {
+	switch p.tokens[i].word {
+	case "daily":
+		p.setRecurrence(FrequencyDaily, 1, nil)
+		return 1
+	case "weekly":
+		p.setRecurrence(FrequencyWeekly, 1, nil)
+		return 1
+	case "monthly":
+		p.setRecurrence(FrequencyMonthly, 1, nil)
+		return 1
+	case "yearly", "annually":
+		p.setRecurrence(FrequencyYearly, 1, nil)
+		return 1
+	case "every":
+	default:
+		return 0
+	}
+
+	next := p.word(i + 1)
+	if next == "weekday" {
+		p.setRecurrence(FrequencyWeekly, 1, []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday})
+		return 2
+	}
+	if day, ok := weekday(next, true); ok {
+		p.setRecurrence(FrequencyWeekly, 1, []time.Weekday{day})
+		return 2
+	}
+
+	interval, n := 1, 1
+	if next == "other" {
+		interval, n = 2, 2
+	} else if count, ok := number(next); ok && count > 1 {
+		interval, n = count, 2
+	}
+	frequency, ok := frequencies[strings.TrimSuffix(p.word(i+n), "s")]
+	if !ok {
+		return 0
+	}
+	p.setRecurrence(frequency, interval, nil)
+	return n + 1
+}
+
+var frequencies = map[string]string{
+	"day": FrequencyDaily, "week": FrequencyWeekly, "month": FrequencyMonthly, "year": FrequencyYearly,
+}
+
+func (p *parser) setRecurrence(frequency string, interval int, days []time.Weekday) {
+	p.result.Recurrence = &Recurrence{Frequency: frequency, Interval: interval, Weekdays: days}
+}
+
+func (p *parser) setDate(date time.Time) {
+	p.date = &date
+}
+
+// resolveDue combines the date and the time. A time alone is today, or
+// tomorrow once passed, and a weekly recurrence alone starts on its next day.
+func (p *parser) resolveDue() {
+	if p.exact != nil {
+		due := p.exact.Truncate(time.Minute)
+		p.result.Due = &due
+		return
+	}
+
+	today := startOfDay(p.now)
+	date := p.date
+	if date == nil && p.hasTime {
+		at := time.Date(today.Year(), today.Month(), today.Day(), p.hour, p.minute, 0, 0, today.Location())
+		if !at.After(p.now) {
+			at = at.AddDate(0, 0, 1)
+		}
+		p.result.Due = &at
+		return
+	}
+	if date == nil && p.result.Recurrence != nil && len(p.result.Recurrence.Weekdays) > 0 {
+		first := nextWeekday(today, p.result.Recurrence.Weekdays[0], true)
+		for _, day := range p.result.Recurrence.Weekdays[1:] {
+			if candidate := nextWeekday(today, day, true); candidate.Before(first) {
+				first = candidate
+			}
+		}
+		date = &first
+	}
+	if date == nil {
+		return
+	}
+
+	if p.hasTime {
+		due := time.Date(date.Year(), date.Month(), date.Day(), p.hour, p.minute, 0, 0, date.Location())
+		p.result.Due = &due
+		return
+	}
+	due := *date
+	p.result.Due, p.result.AllDay = &due, true
+}
+
+func (p *parser) word(i int) string {
+	if i < 0 || i >= len(p.tokens) || p.used[i] {
+		return ""
+	}
+	return p.tokens[i].word
+}
+
+// tokenize splits the input on white space, keeping the character offsets of
+// every token. The lowercase word drops trailing punctuation for matching.
+func tokenize(input string) []token {
+	tokens := make([]token, 0)
+	start, offset := -1, 0
+	var builder strings.Builder
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		text := builder.String()
+		word := strings.TrimRightFunc(strings.ToLower(text), func(r rune) bool {
+			return r == ',' || r == '.' || r == ';'
+		})
+		tokens = append(tokens, token{text: text, word: word, start: start, end: start + utf8.RuneCountInString(text)})
+		builder.Reset()
+		start = -1
+	}
+	for _, r := range input {
+		if unicode.IsSpace(r) {
+			flush()
+		} else {
+			if start < 0 {
+				start = offset
+			}
+			builder.WriteRune(r)
+		}
+		offset++
+	}
+	flush()
+	return tokens
+}
+
+func weekday(word string, allowShort bool) (time.Weekday, bool) {
+	if day, ok := weekdays[word]; ok {
+		return day, true
+	}
+	if allowShort {
+		day, ok := shortWeekdays[word]
+		return day, ok
+	}
+	return 0, false
+}
+
+// nextWeekday returns the next given day after today, or today itself when
+// includeToday is set and today is that day.
+func nextWeekday(today time.Time, day time.Weekday, includeToday bool) time.Time {
+	days := (int(day) - int(today.Weekday()) + 7) % 7
+	if days == 0 && !includeToday {
+		days = 7
+	}
+	return today.AddDate(0, 0, days)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// makeDate builds a date and rejects the ones time.Date would normalize,
+// such as February 30.
+func makeDate(year, month, day int, location *time.Location) (time.Time, bool) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+	return date, date.Year() == year && int(date.Month()) == month && date.Day() == day
+}
+
+func number(word string) (int, bool) {
+	if n, ok := numbers[word]; ok {
+		return n, true
+	}
+	if word == "" || len(word) > 3 {
+		return 0, false
+	}
+	for _, r := range word {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	return atoi(word), true
+}
+
+func atoi(s string) int {
+	n := 0
+	for _, r := range s {
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
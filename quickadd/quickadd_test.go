@@ -0,0 +1,109 @@
+package quickadd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// now is a Wednesday morning, away from UTC to catch dates read in the wrong
+// location.
+var now = time.Date(2025, time.June, 11, 10, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		title    string
+		due      string
+		allDay   bool
+		tags     []string
+		priority string
+		rrule    string
+	}{
+		{
+			input: "Pay rent tomorrow 9am #home !high every month",
+			title: "Pay rent", due: "2025-06-12 09:00", tags: []string{"home"}, priority: PriorityHigh,
+			rrule: "FREQ=MONTHLY;INTERVAL=1",
+		},
+		{input: "Call mom next friday", title: "Call mom", due: "2025-06-13 00:00", allDay: true},
+		{input: "Call mom this friday", title: "Call mom", due: "2025-06-13 00:00", allDay: true},
+		{input: "Review next wednesday", title: "Review", due: "2025-06-18 00:00", allDay: true},
+		{input: "Deploy in 2 hours", title: "Deploy", due: "2025-06-11 12:00"},
+		{input: "Stretch in 30 minutes", title: "Stretch", due: "2025-06-11 10:30"},
+		{input: "Water plants in three days", title: "Water plants", due: "2025-06-14 00:00", allDay: true},
+		{input: "Renew passport dec 31st", title: "Renew passport", due: "2025-12-31 00:00", allDay: true},
+		{input: "File taxes 15th of april", title: "File taxes", due: "2026-04-15 00:00", allDay: true},
+		{input: "Book flights 2025-07-01 at 18:30", title: "Book flights", due: "2025-07-01 18:30"},
+		{input: "Ask if we may leave early", title: "Ask if we may leave early"},
+		{input: "Dentist may 5 at 3pm", title: "Dentist", due: "2026-05-05 15:00"},
+		{input: "Party may 20th, 2025", title: "Party", due: "2025-05-20 00:00", allDay: true},
+		{input: "Lunch with the team at noon", title: "Lunch with the team", due: "2025-06-11 12:00"},
+		{input: "Take out trash at 5", title: "Take out trash", due: "2025-06-11 17:00"},
+		{input: "Walk dog at 8", title: "Walk dog", due: "2025-06-12 08:00"},
+		{input: "Wake up 7am", title: "Wake up", due: "2025-06-12 07:00"},
+		{input: "Meet Ana on sat", title: "Meet Ana", due: "2025-06-14 00:00", allDay: true},
+		{input: "Read 5 books", title: "Read 5 books"},
+		{
+			input: "Standup every weekday at 9:30", title: "Standup", due: "2025-06-12 09:30",
+			rrule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO,TU,WE,TH,FR",
+		},
+		{
+			input: "Gym every mon", title: "Gym", due: "2025-06-16 00:00", allDay: true,
+			rrule: "FREQ=WEEKLY;INTERVAL=1;BYDAY=MO",
+		},
+		{
+			input: "Water plants every other day #garden #home #garden !!", title: "Water plants",
+			tags: []string{"garden", "home"}, priority: PriorityMedium, rrule: "FREQ=DAILY;INTERVAL=2",
+		},
+		{input: "Backup every 3 months !low", title: "Backup", priority: PriorityLow, rrule: "FREQ=MONTHLY;INTERVAL=3"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			result := Parse(test.input, now)
+			if result.Title != test.title {
+				t.Errorf("title = %q, want %q", result.Title, test.title)
+			}
+			var due string
+			if result.Due != nil {
+				if result.Due.Location() != now.Location() {
+					t.Errorf("due location = %s, want %s", result.Due.Location(), now.Location())
+				}
+				due = result.Due.Format("2006-01-02 15:04")
+			}
+			if due != test.due {
+				t.Errorf("due = %q, want %q", due, test.due)
+			}
+			if result.AllDay != test.allDay {
+				t.Errorf("all day = %t, want %t", result.AllDay, test.allDay)
+			}
+			tags := test.tags
+			if tags == nil {
+				tags = []string{}
+			}
+			if !reflect.DeepEqual(result.Tags, tags) {
+				t.Errorf("tags = %v, want %v", result.Tags, tags)
+			}
+			if result.Priority != test.priority {
+				t.Errorf("priority = %q, want %q", result.Priority, test.priority)
+			}
+			var rrule string
+			if result.Recurrence != nil {
+				rrule = result.Recurrence.RRule()
+			}
+			if rrule != test.rrule {
+				t.Errorf("rrule = %q, want %q", rrule, test.rrule)
+			}
+		})
+	}
+}
+
+func TestParseSpans(t *testing.T) {
+	result := Parse("Café on friday #déjà", now)
+	want := []Span{
+		{Kind: KindDate, Start: 5, End: 14, Text: "on friday"},
+		{Kind: KindTag, Start: 15, End: 20, Text: "#déjà"},
+	}
+	if !reflect.DeepEqual(result.Spans, want) {
+		t.Errorf("spans = %+v, want %+v", result.Spans, want)
+	}
+}